@@ -0,0 +1,138 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// LoggerConfig is the configuration of a registered logger.
+type LoggerConfig struct {
+	Name   string `json:"name"`
+	Level  string `json:"level"`
+	Format string `json:"format"`
+}
+
+// Loggers returns the configuration of registered loggers sorted by name.
+// Loggers which are not a DefaultLogger are omitted.
+func Loggers() []LoggerConfig {
+	loggers.Lock()
+	defer loggers.Unlock()
+	result := []LoggerConfig{}
+	for name, logger := range loggers.loggers {
+		l, ok := logger.(*DefaultLogger)
+		if !ok {
+			continue
+		}
+		level := l.getLevel()
+		label, ok := LevelMap[level]
+		if !ok {
+			label = fmt.Sprintf("%d", level)
+		}
+		result = append(result, LoggerConfig{Name: name, Level: label, Format: formatKind(l.getFormatter())})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// formatKind returns the LOGXI_FORMAT kind of a formatter.
+func formatKind(formatter Formatter) string {
+	switch formatter.(type) {
+	case *HappyDevFormatter:
+		return FormatHappy
+	case *TextFormatter:
+		return FormatText
+	case *JSONFormatter:
+		return FormatJSON
+	case *GELFFormatter:
+		return FormatGELF
+	case *ECSFormatter:
+		return FormatECS
+	case *StackdriverFormatter:
+		return FormatStackdriver
+	}
+	return fmt.Sprintf("%T", formatter)
+}
+
+// setFormatByName sets the formatter of registered loggers whose name
+// matches pattern.
+func setFormatByName(pattern string, kind string) error {
+	loggers.Lock()
+	defer loggers.Unlock()
+	for name, logger := range loggers.loggers {
+		l, ok := logger.(*DefaultLogger)
+		if !ok || !matchName(pattern, name) {
+			continue
+		}
+		formatter, err := createFormatter(name, kind)
+		if err != nil {
+			return err
+		}
+		before := formatKind(l.getFormatter())
+		l.SetFormatter(formatter)
+		auditFormat(name, before, kind, 1)
+	}
+	return nil
+}
+
+// AdminHandler returns a handler which lists registered loggers with their
+// level and format as JSON. PUT requests change the level and format of
+// loggers matching a LOGXI pattern, see SetLevelByName. Mount it on an
+// admin or debug mux.
+//
+// Query parameters of PUT
+//
+//	name=dat*     logger name or pattern, required
+//	level=DBG     level, eg DBG or debug
+//	format=JSON   format, eg happy, text or JSON
+//
+// Example
+//
+//	mux.Handle("/debug/logxi", log.AdminHandler())
+//
+//	curl -X PUT 'localhost:6060/debug/logxi?name=models&level=DBG'
+func AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD":
+		case "PUT":
+			q := r.URL.Query()
+			name := q.Get("name")
+			if name == "" {
+				http.Error(w, "name is required", http.StatusBadRequest)
+				return
+			}
+			level := 0
+			if s := q.Get("level"); s != "" {
+				var ok bool
+				if level, ok = ParseLevel(s); !ok {
+					http.Error(w, fmt.Sprintf("unknown level %q", s), http.StatusBadRequest)
+					return
+				}
+			}
+			kind := q.Get("format")
+			if kind != "" && formatterCreators[kind] == nil {
+				http.Error(w, fmt.Sprintf("unknown format %q", kind), http.StatusBadRequest)
+				return
+			}
+			if kind != "" {
+				if err := setFormatByName(name, kind); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if level != 0 {
+				SetLevelByName(name, level)
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Loggers())
+	})
+}
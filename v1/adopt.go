@@ -0,0 +1,86 @@
+package log
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// modulePath is the import path of this copy of logxi, eg
+// "example.com/app/vendor/github.com/mgutz/logxi/v1" when vendored
+var modulePath = reflect.TypeOf(loggerMap{}).PkgPath()
+
+// envNamespace prefixes the environment variables read by a vendored copy,
+// eg "EXAMPLE_COM_APP" for a copy vendored by example.com/app
+var envNamespace = namespaceOf(modulePath)
+
+// ModulePath returns the import path of this copy of logxi. Copies vendored
+// at different versions have different paths and separate state.
+func ModulePath() string {
+	return modulePath
+}
+
+// namespaceOf returns the environment namespace of a vendored copy of
+// logxi, the path of the vendoring package in upper case with other
+// characters replaced by '_'. It is empty for a copy which isn't vendored.
+func namespaceOf(path string) string {
+	i := strings.Index(path, "/vendor/")
+	if i < 0 {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, path[:i])
+}
+
+// adoptedLogger holds the logger set by Adopt
+type adoptedLogger struct {
+	Logger
+}
+
+var adopted atomic.Value
+
+// Adopt makes every logger of this copy of logxi forward its entries to
+// logger, which is usually the DefaultLog of another copy vendored at a
+// different version. The adopting copy no longer writes, colors or filters
+// entries itself, so two copies don't fight over the terminal and the
+// environment. Entries carry the name of the original logger as "logger".
+// Adopt(nil) restores logging by this copy.
+//
+// Loggers disabled when they were created are NullLog and stay silent.
+//
+// Example
+//
+//	// in the application, which vendors a newer logxi than somelib
+//	somelib.LogxiAdopt(log.DefaultLog)
+//
+//	// in somelib, which vendors its own logxi
+//	func LogxiAdopt(logger log.Logger) { log.Adopt(logger) }
+func Adopt(logger Logger) {
+	switch logger.(type) {
+	case *DefaultLogger, *fieldLogger, *groupLogger:
+		panic("logxi: Adopt requires a logger from another copy of logxi")
+	}
+	adopted.Store(adoptedLogger{logger})
+}
+
+// adoptedBy returns the logger set by Adopt or nil.
+func adoptedBy() Logger {
+	if a, ok := adopted.Load().(adoptedLogger); ok {
+		return a.Logger
+	}
+	return nil
+}
+
+// adoptArgs prefixes args with the name of the logger forwarding them.
+func adoptArgs(name string, args []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(args)+2)
+	result = append(result, "logger", name)
+	return append(result, args...)
+}
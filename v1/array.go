@@ -0,0 +1,106 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Array is a slice of primitives logged as an array, eg [80,443], instead
+// of Go's %v rendering, eg [80 443]. Create arrays with Ints, Strings etc.
+// An array takes the place of a key-value pair.
+//
+// Example
+//
+//	logger.Info("listening", log.Ints("ports", ports), log.Strings("tags", tags))
+//	//=> {"_m": "listening", "ports": [80,443], "tags": ["api","edge"]}
+type Array struct {
+	key    string
+	values interface{}
+}
+
+// Ints creates an array of ints.
+func Ints(key string, values []int) *Array {
+	if values == nil {
+		values = []int{}
+	}
+	return &Array{key: key, values: values}
+}
+
+// Int64s creates an array of int64s.
+func Int64s(key string, values []int64) *Array {
+	if values == nil {
+		values = []int64{}
+	}
+	return &Array{key: key, values: values}
+}
+
+// Float64s creates an array of float64s.
+func Float64s(key string, values []float64) *Array {
+	if values == nil {
+		values = []float64{}
+	}
+	return &Array{key: key, values: values}
+}
+
+// Strings creates an array of strings.
+func Strings(key string, values []string) *Array {
+	if values == nil {
+		values = []string{}
+	}
+	return &Array{key: key, values: values}
+}
+
+// Bools creates an array of bools.
+func Bools(key string, values []bool) *Array {
+	if values == nil {
+		values = []bool{}
+	}
+	return &Array{key: key, values: values}
+}
+
+func (a *Array) pair() (string, interface{}) {
+	return a.key, a
+}
+
+// MarshalJSON marshals the values as a JSON array.
+func (a *Array) MarshalJSON() ([]byte, error) {
+	return []byte(a.json(cfg().floatFormat)), nil
+}
+
+// String returns the values as a JSON array so flat formats can be parsed.
+func (a *Array) String() string {
+	return a.json(cfg().floatFormat)
+}
+
+// json returns the values as a JSON array. Floats are rendered per ff like
+// scalar floats, since JSON has no NaN.
+func (a *Array) json(ff FloatFormat) string {
+	floats, ok := a.values.([]float64)
+	if !ok {
+		// ints, strings and bools always marshal
+		b, _ := json.Marshal(a.values)
+		return string(b)
+	}
+	var buf bytes.Buffer
+	buf.WriteRune('[')
+	for i, f := range floats {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		s, finite := ff.format(f, 64)
+		switch {
+		case finite:
+			buf.WriteString(s)
+		case ff.NaNString:
+			buf.WriteString(`"` + s + `"`)
+		default:
+			buf.WriteString("null")
+		}
+	}
+	buf.WriteRune(']')
+	return buf.String()
+}
+
+func (a *Array) happyValue() (string, string) {
+	return a.String(), ""
+}
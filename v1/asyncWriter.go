@@ -0,0 +1,283 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrFlushTimeout is returned when queued entries are not written in time.
+var ErrFlushTimeout = errors.New("logxi: timed out flushing queued entries")
+
+// ErrWriterClosed is returned when writing to a closed AsyncWriter or
+// FileWriter.
+var ErrWriterClosed = errors.New("logxi: writer is closed")
+
+type asyncOp struct {
+	p []byte
+	// level is the level p was logged at, if leveled
+	level   int
+	leveled bool
+	// done, if set, receives the result once p and every entry queued
+	// before it are written
+	done chan error
+}
+
+// AsyncWriter queues writes and writes them to a sink from a single
+// goroutine so callers don't wait on slow sinks. Writes block when the
+// queue is full.
+//
+// Loggers which must not lose entries, eg an audit logger, can share the
+// same AsyncWriter but write synchronously with SetBlocking.
+//
+// Entries are formatted, and timestamped, when they are logged so time
+// spent in the queue doesn't skew timelines. Use SetWriteTime to also log
+// the time entries are written. Levels are passed on to the sink, so a
+// FileWriter behind an AsyncWriter still syncs errors.
+//
+// Example
+//
+//	aw := log.NewAsyncWriter(os.Stdout, 1024)
+//	defer aw.Close()
+//	logger := log.NewLogger(aw, "api")
+//	audit := log.NewLogger(aw, "audit")
+//	audit.(*log.DefaultLogger).SetBlocking(true)
+type AsyncWriter struct {
+	writer io.Writer
+	queue  chan asyncOp
+
+	// closing holds off Close while writes are being queued
+	closing sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+
+	// writeTime is 1 when entries are stamped with the time they are
+	// written
+	writeTime int32
+
+	mu            sync.Mutex
+	failures      int
+	lastErr       error
+	lastErrorTime time.Time
+}
+
+// NewAsyncWriter creates a writer which queues up to size writes to writer.
+func NewAsyncWriter(writer io.Writer, size int) *AsyncWriter {
+	if size < 1 {
+		size = 1
+	}
+	aw := &AsyncWriter{
+		writer:  writer,
+		queue:   make(chan asyncOp, size),
+		stopped: make(chan struct{}),
+	}
+	go aw.run()
+	return aw
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.stopped)
+	for op := range aw.queue {
+		var err error
+		if op.p != nil {
+			if atomic.LoadInt32(&aw.writeTime) == 1 {
+				op.p = stampWriteTime(op.p, time.Now())
+			}
+			var n int
+			if lw, ok := unwrapWriter(aw.writer).(levelWriter); ok && op.leveled {
+				n, err = lw.WriteLevel(op.level, op.p)
+			} else {
+				n, err = aw.writer.Write(op.p)
+			}
+			if err == nil && n < len(op.p) {
+				err = io.ErrShortWrite
+			}
+			aw.mu.Lock()
+			if err != nil {
+				aw.failures++
+				aw.lastErr = err
+				aw.lastErrorTime = time.Now()
+			} else {
+				aw.failures = 0
+			}
+			aw.mu.Unlock()
+		}
+		if op.done != nil {
+			op.done <- err
+		}
+	}
+}
+
+func (aw *AsyncWriter) enqueue(op asyncOp) error {
+	aw.closing.RLock()
+	defer aw.closing.RUnlock()
+	if aw.closed {
+		return ErrWriterClosed
+	}
+	aw.queue <- op
+	return nil
+}
+
+// SetWriteTime adds the time each entry is written to the sink as
+// KeyMap.WriteTime, next to the time it was logged.
+func (aw *AsyncWriter) SetWriteTime(enable bool) {
+	if enable {
+		atomic.StoreInt32(&aw.writeTime, 1)
+	} else {
+		atomic.StoreInt32(&aw.writeTime, 0)
+	}
+}
+
+// stampWriteTime adds the write time to a formatted entry.
+func stampWriteTime(p []byte, t time.Time) []byte {
+	return appendField(p, KeyMap.WriteTime, t.Format(cfg().timeFormat))
+}
+
+// appendField adds a string field to a formatted entry. JSON objects get
+// another field, other formats a trailing key-value pair.
+func appendField(p []byte, key string, value string) []byte {
+	entry := bytes.TrimRight(p, "\n")
+	if len(entry) == 0 {
+		return p
+	}
+	result := make([]byte, 0, len(p)+len(key)+len(value)+8)
+	if entry[len(entry)-1] == '}' {
+		result = append(result, entry[:len(entry)-1]...)
+		result = append(result, `,"`...)
+		result = append(result, key...)
+		result = append(result, `":"`...)
+		result = append(result, value...)
+		result = append(result, `"}`...)
+	} else {
+		result = append(result, entry...)
+		result = append(result, cfg().pairSeparator()...)
+		result = append(result, key...)
+		result = append(result, cfg().assignment()...)
+		result = append(result, value...)
+	}
+	return append(result, p[len(entry):]...)
+}
+
+// Write queues a copy of p. Errors from the sink are reported by Health.
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	if err := aw.enqueue(asyncOp{p: append([]byte(nil), p...)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteLevel queues a copy of p logged at level. The level is passed on to
+// sinks which implement WriteLevel, eg a FileWriter syncing errors.
+func (aw *AsyncWriter) WriteLevel(level int, p []byte) (int, error) {
+	if err := aw.enqueue(asyncOp{p: append([]byte(nil), p...), level: level, leveled: true}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteBlocking writes p after every queued write and waits for the
+// result.
+func (aw *AsyncWriter) WriteBlocking(p []byte) (int, error) {
+	done := make(chan error, 1)
+	if err := aw.enqueue(asyncOp{p: append([]byte(nil), p...), done: done}); err != nil {
+		return 0, err
+	}
+	if err := <-done; err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush waits up to timeout for queued writes to be written.
+func (aw *AsyncWriter) Flush(timeout time.Duration) error {
+	done := make(chan error, 1)
+	if err := aw.enqueue(asyncOp{done: done}); err != nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return ErrFlushTimeout
+	}
+}
+
+// Sync syncs the sink if it supports it, eg *os.File. Call Flush first to
+// write queued entries.
+func (aw *AsyncWriter) Sync() error {
+	if s, ok := unwrapWriter(aw.writer).(syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Reopen reopens the sink if it supports it, eg a FileWriter. Entries
+// queued before are written to the new file.
+func (aw *AsyncWriter) Reopen() error {
+	if r, ok := unwrapWriter(aw.writer).(reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+// Close writes queued entries and stops the writer. The sink is closed if it
+// implements io.Closer.
+func (aw *AsyncWriter) Close() error {
+	aw.closing.Lock()
+	if aw.closed {
+		aw.closing.Unlock()
+		return nil
+	}
+	aw.closed = true
+	close(aw.queue)
+	aw.closing.Unlock()
+
+	<-aw.stopped
+	if closer, ok := aw.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Health reports the number of queued writes and the last error, followed
+// by the health of the sink if it reports its own.
+func (aw *AsyncWriter) Health() []SinkStatus {
+	aw.mu.Lock()
+	ss := SinkStatus{
+		Name:          "async",
+		Connected:     true,
+		SpoolDepth:    len(aw.queue),
+		Failures:      aw.failures,
+		LastErrorTime: aw.lastErrorTime,
+	}
+	if aw.lastErr != nil {
+		ss.LastError = aw.lastErr.Error()
+	}
+	aw.mu.Unlock()
+	result := []SinkStatus{ss}
+	if hr, ok := unwrapWriter(aw.writer).(HealthReporter); ok {
+		result = append(result, hr.Health()...)
+	}
+	return result
+}
+
+// writeBlocker is implemented by writers which write asynchronously but can
+// also write synchronously
+type writeBlocker interface {
+	WriteBlocking(p []byte) (int, error)
+}
+
+// levelWriter is implemented by writers which treat entries differently by
+// level, eg a FileWriter syncing errors
+type levelWriter interface {
+	WriteLevel(level int, p []byte) (int, error)
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (fn writerFunc) Write(p []byte) (int, error) {
+	return fn(p)
+}
@@ -0,0 +1,67 @@
+package log
+
+// Changes to levels and configuration at runtime, eg from an admin endpoint
+// or a config reload, are logged at Info on InternalLog so they can be
+// audited. InternalLog only logs errors unless its level is set explicitly
+//
+//	LOGXI=*=WRN,__logxi=INF
+
+// internalLogLevel is the level of InternalLog. Wildcards in LOGXI don't
+// apply to it, so it is only silenced explicitly, eg LOGXI=*=OFF,__logxi=OFF.
+func internalLogLevel() int {
+	if level, ok := cfg().logxiNameLevelMap["__logxi"]; ok {
+		return level
+	}
+	return LevelError
+}
+
+// auditLevel logs a level change. skip is the number of frames above the
+// caller of auditLevel which made the change.
+func auditLevel(name string, before, after int, skip int) {
+	if InternalLog == nil || !InternalLog.IsInfo() {
+		return
+	}
+	InternalLog.Info("Level changed",
+		"logger", name,
+		"before", LevelMap[before],
+		"after", LevelMap[after],
+		"caller", callerOf(skip+2))
+}
+
+// auditFormat logs a format change. skip is the number of frames above the
+// caller of auditFormat which made the change.
+func auditFormat(name, before, after string, skip int) {
+	if InternalLog == nil || !InternalLog.IsInfo() || before == after {
+		return
+	}
+	InternalLog.Info("Format changed",
+		"logger", name,
+		"before", before,
+		"after", after,
+		"caller", callerOf(skip+2))
+}
+
+// auditConfig logs each setting which differs between before and after.
+func auditConfig(before, after Configuration, skip int) {
+	if InternalLog == nil || !InternalLog.IsInfo() {
+		return
+	}
+	// the first configuration is not a change
+	if before == (Configuration{}) {
+		return
+	}
+	caller := callerOf(skip + 2)
+	var audit = func(setting, before, after string) {
+		if before != after {
+			InternalLog.Info("Configuration changed",
+				"setting", setting,
+				"before", before,
+				"after", after,
+				"caller", caller)
+		}
+	}
+	audit("LOGXI", before.Levels, after.Levels)
+	audit("LOGXI_FORMAT", before.Format, after.Format)
+	audit("LOGXI_COLORS", before.Colors, after.Colors)
+	audit("LOGXI_SHOW", before.Show, after.Show)
+}
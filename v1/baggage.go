@@ -0,0 +1,185 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BaggageHeader is the W3C baggage header and gRPC metadata key.
+const BaggageHeader = "baggage"
+
+// maxBaggage is the size limit of a baggage header
+const maxBaggage = 8192
+
+// boundFields returns the key-value pairs bound to logger with With.
+func boundFields(logger Logger) []interface{} {
+	if tl, ok := logger.(*traceLogger); ok {
+		logger = tl.Logger
+	}
+	if fl, ok := logger.(*fieldLogger); ok {
+		return fl.args
+	}
+	return nil
+}
+
+// boundValue returns the value of key in fields. The last binding of a key
+// wins, as it does for formatters.
+func boundValue(fields []interface{}, key string) (interface{}, bool) {
+	var value interface{}
+	found := false
+	for i := 0; i+1 < len(fields); i += 2 {
+		if k, ok := fields[i].(string); ok && k == key {
+			value = fields[i+1]
+			found = true
+		}
+	}
+	return value, found
+}
+
+// Baggage returns the fields bound to logger named by keys as a W3C
+// baggage header value, so correlation fields follow requests to other
+// services. Values are formatted with fmt.Sprint. Unbound keys are
+// skipped.
+//
+// Example
+//
+//	// gRPC client
+//	ctx = metadata.AppendToOutgoingContext(ctx, log.BaggageHeader, log.Baggage(logger, "tenant", "order"))
+func Baggage(logger Logger, keys ...string) string {
+	fields := boundFields(logger)
+	var members []string
+	size := 0
+	for _, key := range keys {
+		if !isBaggageKey(key) {
+			continue
+		}
+		value, ok := boundValue(fields, key)
+		if !ok {
+			continue
+		}
+		member := key + "=" + url.PathEscape(fmt.Sprint(value))
+		if size+len(member)+1 > maxBaggage {
+			break
+		}
+		size += len(member) + 1
+		members = append(members, member)
+	}
+	return strings.Join(members, ",")
+}
+
+// isBaggageKey reports whether key is a valid baggage key, an HTTP token.
+func isBaggageKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// InjectBaggage adds the fields bound to logger named by keys to the
+// baggage header of an outgoing request. Members already in the header
+// with the same keys are replaced, others are kept.
+//
+// Example
+//
+//	req, _ := http.NewRequest("GET", url, nil)
+//	log.InjectBaggage(req.Header, log.FromContext(ctx), "tenant", "order")
+func InjectBaggage(header http.Header, logger Logger, keys ...string) {
+	baggage := Baggage(logger, keys...)
+	if baggage == "" {
+		return
+	}
+	members := []string{baggage}
+	for _, value := range header.Values(BaggageHeader) {
+		for _, member := range strings.Split(value, ",") {
+			key, _ := splitBaggageMember(member)
+			if key == "" || containsString(keys, key) {
+				continue
+			}
+			members = append(members, strings.TrimSpace(member))
+		}
+	}
+	header.Set(BaggageHeader, strings.Join(members, ","))
+}
+
+// splitBaggageMember returns the key and unescaped value of a member,
+// ignoring its properties. The key is empty if the member is invalid.
+func splitBaggageMember(member string) (string, string) {
+	if i := strings.IndexByte(member, ';'); i >= 0 {
+		member = member[:i]
+	}
+	i := strings.IndexByte(member, '=')
+	if i < 0 {
+		return "", ""
+	}
+	key := strings.TrimSpace(member[:i])
+	value, err := url.PathUnescape(strings.TrimSpace(member[i+1:]))
+	if err != nil || !isBaggageKey(key) {
+		return "", ""
+	}
+	return key, value
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// BaggageFields returns the members of a baggage header value named by
+// keys as key-value pairs. Other members are ignored so a caller can't add
+// arbitrary fields to entries.
+func BaggageFields(baggage string, keys ...string) []interface{} {
+	var args []interface{}
+	for _, member := range strings.Split(baggage, ",") {
+		key, value := splitBaggageMember(member)
+		if key != "" && containsString(keys, key) {
+			args = append(args, key, value)
+		}
+	}
+	return args
+}
+
+// WithBaggage returns a copy of ctx whose logger, see FromContext, has the
+// baggage fields named by keys.
+//
+// Example
+//
+//	// gRPC server
+//	md, _ := metadata.FromIncomingContext(ctx)
+//	ctx = log.WithBaggage(ctx, strings.Join(md.Get(log.BaggageHeader), ","), "tenant", "order")
+func WithBaggage(ctx context.Context, baggage string, keys ...string) context.Context {
+	args := BaggageFields(baggage, keys...)
+	if len(args) == 0 {
+		return ctx
+	}
+	return NewContext(ctx, newFieldLogger(contextLogger(ctx), args))
+}
+
+// BaggageHandler wraps next so the logger of each request, see
+// FromContext, has the baggage fields named by keys.
+//
+// Example
+//
+//	http.Handle("/", log.BaggageHandler(mux, "tenant", "order"))
+func BaggageHandler(next http.Handler, keys ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baggage := strings.Join(r.Header.Values(BaggageHeader), ",")
+		if baggage == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithBaggage(r.Context(), baggage, keys...)))
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strconv"
 	"strings"
 
@@ -64,7 +65,7 @@ func (ci *frameInfo) String(color string, sourceColor string) string {
 
 	if disableCallstack {
 		buf.WriteString(color)
-		buf.WriteString(Separator)
+		buf.WriteString(cfg().pairSeparator())
 		buf.WriteString(indent)
 		buf.WriteString(ci.filename)
 		buf.WriteRune(':')
@@ -83,13 +84,17 @@ func (ci *frameInfo) String(color string, sourceColor string) string {
 		InternalLog.Warn("Could not make path relative", "path", ci.filename)
 		return ""
 	}
-	// ../../../ is too complex.  Make path relative to home
+	// ../../../ is too complex.  Make path relative to module or home
 	if strings.HasPrefix(tildeFilename, strings.Repeat(".."+string(os.PathSeparator), 3)) {
-		tildeFilename = strings.Replace(tildeFilename, home, "~", 1)
+		if short := shortenPath(ci.filename); cfg().shortPaths && short != ci.filename {
+			tildeFilename = short
+		} else {
+			tildeFilename = strings.Replace(tildeFilename, home, "~", 1)
+		}
 	}
 
 	buf.WriteString(color)
-	buf.WriteString(Separator)
+	buf.WriteString(cfg().pairSeparator())
 	buf.WriteString(indent)
 	buf.WriteString("in ")
 	buf.WriteString(ci.method)
@@ -137,17 +142,19 @@ func (ci *frameInfo) String(color string, sourceColor string) string {
 		}
 		// trim spaces at start
 		idx := minInt(len(li.line), skipSpaces)
-		buf.WriteString(fmt.Sprintf(format, Separator+indent+indent, li.lineno, li.line[idx:]))
+		buf.WriteString(fmt.Sprintf(format, cfg().pairSeparator()+indent+indent, li.lineno, li.line[idx:]))
 	}
 	// get rid of last \n
 	buf.Truncate(buf.Len() - 1)
-	if !disableColors {
+	if !cfg().disableColors {
 		buf.WriteString(ansi.Reset)
 	}
 	return buf.String()
 }
 
 // Generates a stack from runtime.Callers()
+var runtimeSrc = filepath.Join("src", "runtime")
+
 func stackFrames(skip int, ignoreRuntime bool) []*frameInfo {
 	frames := []*frameInfo{}
 	size := 20
@@ -159,7 +166,7 @@ func stackFrames(skip int, ignoreRuntime bool) []*frameInfo {
 		fn := runtime.FuncForPC(pc)
 		name := fn.Name()
 		file, line := fn.FileLine(pc - 1)
-		if ignoreRuntime && strings.Contains(file, filepath.Join("src", "runtime")) {
+		if ignoreRuntime && strings.Contains(file, runtimeSrc) {
 			break
 		}
 
@@ -175,18 +182,110 @@ func stackFrames(skip int, ignoreRuntime bool) []*frameInfo {
 	return frames
 }
 
+func capturesCaller(level int) bool {
+	return level == LevelTrace || level <= cfg().callerLevel
+}
+
+// capturesStack determines if the stack is captured. Warnings logging an
+// error capture the stack when warnings capture the caller.
+func capturesStack(level int, args []interface{}) bool {
+	return level <= cfg().stackLevel || (level == LevelWarn && capturesCaller(level) && hasError(args))
+}
+
+// callerString returns file:line of the caller for machine formats.
+func callerString() string {
+	frame, ok := callerFrame()
+	if !ok {
+		return ""
+	}
+	filename := frame.File
+	if cfg().shortPaths {
+		filename = shortenPath(filename)
+	}
+	return filename + ":" + strconv.Itoa(frame.Line)
+}
+
+// Frame is a stack frame passed to a FrameFilter.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// FrameFilter determines if a frame is printed in call stacks.
+type FrameFilter func(frame Frame) bool
+
+var frameFilter FrameFilter
+
+// SetFrameFilter sets a filter applied to every call stack printed for
+// errors so applications can hide their own logging wrappers and vendored
+// code. A nil filter prints every frame. Set it at startup.
+//
+// Example
+//
+//	log.SetFrameFilter(func(f log.Frame) bool {
+//		return !strings.Contains(f.File, "/vendor/") &&
+//			!strings.HasPrefix(f.Function, "myapp/logging.")
+//	})
+func SetFrameFilter(filter FrameFilter) {
+	frameFilter = filter
+}
+
+// filterFrames removes frames rejected by the frame filter.
+func filterFrames(frames []*frameInfo) []*frameInfo {
+	filter := frameFilter
+	if filter == nil {
+		return frames
+	}
+	result := frames[:0]
+	for _, frame := range frames {
+		if filter(Frame{Function: frame.method, File: frame.filename, Line: frame.lineno}) {
+			result = append(result, frame)
+		}
+	}
+	return result
+}
+
+// textStack returns the current goroutine's stack for text formats. When a
+// frame filter is set, the filtered frames are printed like
+// runtime/debug.Stack.
+func textStack() string {
+	if frameFilter == nil {
+		return stackString(debug.Stack())
+	}
+	return framesText(callerFrames())
+}
+
+// framesText renders frames like runtime/debug.Stack.
+func framesText(frames []*frameInfo) string {
+	buf := pool.Get()
+	defer pool.Put(buf)
+	for _, frame := range frames {
+		filename := frame.filename
+		if cfg().shortPaths {
+			filename = shortenPath(filename)
+		}
+		fmt.Fprintf(buf, "%s()\n\t%s:%d\n", frame.method, filename, frame.lineno)
+	}
+	return buf.String()
+}
+
 // Returns debug stack excluding logxi frames
 func trimmedStackTrace() string {
 	buf := pool.Get()
 	defer pool.Put(buf)
-	frames := stackFrames(0, false)
+	frames := filterFrames(stackFrames(0, false))
 	for _, frame := range frames {
 		// skip anything in the logxi package
 		if isLogxiCode(frame.filename) {
 			continue
 		}
 
-		fmt.Fprintf(buf, "%s:%d (0x%x)\n", frame.filename, frame.lineno, frame.pc)
+		filename := frame.filename
+		if cfg().shortPaths {
+			filename = shortenPath(filename)
+		}
+		fmt.Fprintf(buf, "%s:%d (0x%x)\n", filename, frame.lineno, frame.pc)
 
 		err := frame.readSource(0)
 		if err != nil || len(frame.context) < 1 {
@@ -0,0 +1,214 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+// CanonicalLine accumulates fields across the lifetime of a request and
+// logs them as a single summary entry when the request completes, so the
+// most important facts about a request are on one line. Timings recorded
+// with Time are logged in milliseconds, eg "db_ms".
+//
+// CanonicalLine is concurrent safe.
+//
+// Example
+//
+//	cl := log.NewCanonicalLine(logger, "canonical-log-line")
+//	defer cl.Emit()
+//	cl.Add("user", userID)
+//	stop := cl.Time("db")
+//	rows := query()
+//	stop()
+type CanonicalLine struct {
+	logger Logger
+	msg    string
+	start  time.Time
+	// ctx is the context of the request's trace task, if annotated
+	ctx context.Context
+
+	mu      sync.Mutex
+	args    []interface{}
+	timings []string
+	elapsed map[string]time.Duration
+	level   int
+	emitted bool
+}
+
+// NewCanonicalLine creates a canonical line logged to logger with msg. The
+// total duration is measured from now.
+func NewCanonicalLine(logger Logger, msg string) *CanonicalLine {
+	return &CanonicalLine{
+		logger:  logger,
+		msg:     msg,
+		start:   time.Now(),
+		elapsed: map[string]time.Duration{},
+		level:   LevelInfo,
+	}
+}
+
+// Add adds key-value pairs to the entry. Keys added again replace the
+// earlier value.
+func (cl *CanonicalLine) Add(args ...interface{}) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		replaced := false
+		for j := 0; j+1 < len(cl.args); j += 2 {
+			if cl.args[j] == args[i] {
+				cl.args[j+1] = args[i+1]
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			cl.args = append(cl.args, args[i], args[i+1])
+		}
+	}
+}
+
+// Time starts timing name and returns a func which stops it. Repeated
+// timings of the same name are summed.
+func (cl *CanonicalLine) Time(name string) func() {
+	start := time.Now()
+	var region *trace.Region
+	if cl.ctx != nil && isTracing() {
+		region = trace.StartRegion(cl.ctx, name)
+	}
+	return func() {
+		cl.AddDuration(name, time.Since(start))
+		if region != nil {
+			region.End()
+		}
+	}
+}
+
+// AddDuration adds d to the timing of name.
+func (cl *CanonicalLine) AddDuration(name string, d time.Duration) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if _, ok := cl.elapsed[name]; !ok {
+		cl.timings = append(cl.timings, name)
+	}
+	cl.elapsed[name] += d
+}
+
+// SetLevel sets the level of the entry. Levels only escalate, so an error
+// recorded during the request is not downgraded later.
+func (cl *CanonicalLine) SetLevel(level int) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if level < cl.level {
+		cl.level = level
+	}
+}
+
+// Emit logs the entry with the accumulated fields, the timings and the
+// total duration. Only the first call logs.
+func (cl *CanonicalLine) Emit() {
+	cl.mu.Lock()
+	if cl.emitted {
+		cl.mu.Unlock()
+		return
+	}
+	cl.emitted = true
+	args := make([]interface{}, 0, len(cl.args)+2*len(cl.timings)+2)
+	args = append(args, cl.args...)
+	for _, name := range cl.timings {
+		args = append(args, name+"_ms", durationMillis(cl.elapsed[name]))
+	}
+	args = append(args, "duration_ms", durationMillis(time.Since(cl.start)))
+	level := cl.level
+	cl.mu.Unlock()
+
+	cl.logger.Log(level, cl.msg, args)
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type canonicalKey struct{}
+
+// NewCanonicalContext returns a copy of ctx carrying cl.
+func NewCanonicalContext(ctx context.Context, cl *CanonicalLine) context.Context {
+	return context.WithValue(ctx, canonicalKey{}, cl)
+}
+
+// CanonicalFromContext returns the canonical line carried by ctx. If there
+// is none, a line which is never emitted is returned so callers don't need
+// to check.
+func CanonicalFromContext(ctx context.Context) *CanonicalLine {
+	if ctx != nil {
+		if cl, ok := ctx.Value(canonicalKey{}).(*CanonicalLine); ok {
+			return cl
+		}
+	}
+	return NewCanonicalLine(NullLog, "")
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(p)
+	sr.bytes += n
+	return n, err
+}
+
+// CanonicalHandler wraps next so every request logs a single canonical
+// line to logger with the method, path, status, response size and
+// duration. Handlers add fields and timings with CanonicalFromContext.
+// Responses with a 5xx status are logged as errors and 4xx as warnings.
+//
+// Example
+//
+//	http.Handle("/", log.CanonicalHandler(logger, mux))
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		log.CanonicalFromContext(r.Context()).Add("user", userID)
+//	}
+func CanonicalHandler(logger Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cl := NewCanonicalLine(logger, "canonical-log-line")
+		cl.Add("method", r.Method, "path", r.URL.Path)
+		ctx := r.Context()
+		if isTracing() {
+			var task *trace.Task
+			ctx, task = trace.NewTask(ctx, "http "+r.Method)
+			defer task.End()
+			cl.ctx = ctx
+		}
+		sr := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			status := sr.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= 500 {
+				cl.SetLevel(LevelError)
+			} else if status >= 400 {
+				cl.SetLevel(LevelWarn)
+			}
+			cl.Add("status", status, "bytes", sr.bytes)
+			cl.Emit()
+		}()
+		next.ServeHTTP(sr, r.WithContext(NewCanonicalContext(ctx, cl)))
+	})
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// ErrNoChecksum is returned by VerifyChecksum when an entry has no checksum.
+var ErrNoChecksum = errors.New("logxi: entry has no checksum")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumWriter adds KeyMap.Checksum, the CRC-32C of the formatted entry
+// as 8 hex digits, to every entry so consumers reading logs over lossy
+// transports can detect truncated or corrupted lines with VerifyChecksum.
+// Each write must be a single entry, as written by the formatters.
+//
+// Example
+//
+//	logger := log.NewLogger(log.NewChecksumWriter(conn), "api")
+type ChecksumWriter struct {
+	writer io.Writer
+}
+
+// NewChecksumWriter creates a writer which adds checksums to entries
+// written to writer.
+func NewChecksumWriter(writer io.Writer) *ChecksumWriter {
+	return &ChecksumWriter{writer: writer}
+}
+
+func (cw *ChecksumWriter) Write(p []byte) (int, error) {
+	sum := crc32.Checksum(bytes.TrimRight(p, "\n"), castagnoli)
+	if _, err := cw.writer.Write(appendField(p, KeyMap.Checksum, fmt.Sprintf("%08x", sum))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// VerifyChecksum verifies the checksum added to a line by ChecksumWriter.
+// It returns ErrNoChecksum if the line has none, for example because it
+// was truncated.
+func VerifyChecksum(line []byte) error {
+	line = bytes.TrimRight(line, "\n")
+	var entry []byte
+	var sum []byte
+	jsonSuffix := []byte(`,"` + KeyMap.Checksum + `":"`)
+	textSuffix := []byte(cfg().pairSeparator() + KeyMap.Checksum + cfg().assignment())
+	if n := len(line) - len(jsonSuffix) - 10; n >= 0 && bytes.HasPrefix(line[n:], jsonSuffix) && bytes.HasSuffix(line, []byte(`"}`)) {
+		entry = append(append([]byte(nil), line[:n]...), '}')
+		sum = line[n+len(jsonSuffix) : len(line)-2]
+	} else if n := len(line) - len(textSuffix) - 8; n >= 0 && bytes.HasPrefix(line[n:], textSuffix) {
+		entry = line[:n]
+		sum = line[n+len(textSuffix):]
+	} else {
+		return ErrNoChecksum
+	}
+	if expected := fmt.Sprintf("%08x", crc32.Checksum(entry, castagnoli)); expected != string(sum) {
+		return fmt.Errorf("logxi: checksum mismatch, expected %s got %s", expected, sum)
+	}
+	return nil
+}
+
+// Health reports the health of the sink if it reports its own.
+func (cw *ChecksumWriter) Health() []SinkStatus {
+	if hr, ok := unwrapWriter(cw.writer).(HealthReporter); ok {
+		return hr.Health()
+	}
+	return nil
+}
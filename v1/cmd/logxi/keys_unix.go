@@ -0,0 +1,48 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// readKeys puts the terminal in cbreak mode and sends the keys pressed,
+// so keys can be read while entries are piped in. The returned func
+// restores the terminal.
+func readKeys(keys chan<- byte) (func(), error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, err
+	}
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = tty
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	state, err := stty("-g")
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+	if _, err := stty("cbreak", "-echo"); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := tty.Read(b); err != nil {
+				return
+			}
+			keys <- b[0]
+		}
+	}()
+	return func() {
+		stty(state)
+		tty.Close()
+	}, nil
+}
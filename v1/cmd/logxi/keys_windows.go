@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// readKeys is not supported on Windows, filters are set with flags.
+func readKeys(keys chan<- byte) (func(), error) {
+	return nil, errors.New("logxi: keys are not supported on Windows")
+}
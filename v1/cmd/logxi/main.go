@@ -0,0 +1,43 @@
+// Command logxi contains tools for working with logxi.
+//
+//	logxi merge     interleave log files by time
+//	logxi selftest  write an entry at every level through the configured pipeline
+//	logxi tail      print entries as they are written, filtered by level and name
+//	logxi themes    preview the color themes on the current terminal
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var commands = map[string]func(args []string) int{
+	"merge":    merge,
+	"selftest": selftest,
+	"tail":     tail,
+	"themes":   themes,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: logxi <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "    merge     interleave log files by time")
+	fmt.Fprintln(os.Stderr, "    selftest  write an entry at every level through the configured pipeline")
+	fmt.Fprintln(os.Stderr, "    tail      print entries as they are written, filtered by level and name")
+	fmt.Fprintln(os.Stderr, "    themes    preview the color themes on the current terminal")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "logxi: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(command(os.Args[2:]))
+}
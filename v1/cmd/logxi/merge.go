@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mgutz/logxi/v1/parse"
+)
+
+// merge prints the entries of log files in time order, each prefixed with
+// the label of its file. Arguments are files or label=file.
+func merge(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: logxi merge [label=]file ...")
+		return 2
+	}
+
+	sources := make([]parse.Source, 0, len(args))
+	width := 0
+	for _, arg := range args {
+		label, filename := "", arg
+		if i := strings.IndexByte(arg, '='); i > 0 {
+			label, filename = arg[:i], arg[i+1:]
+		}
+		if label == "" {
+			label = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		}
+		file, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "logxi:", err)
+			return 1
+		}
+		defer file.Close()
+		sources = append(sources, parse.Source{Label: label, Reader: file})
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+
+	err := parse.Merge(sources, func(m *parse.Merged) error {
+		fmt.Printf("%-*s | %s\n", width, m.Source, m.Raw)
+		if m.Stack != "" {
+			for _, line := range strings.Split(m.Stack, "\n") {
+				fmt.Printf("%-*s | %s\n", width, m.Source, line)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "logxi:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mgutz/logxi/v1"
+)
+
+// selftest writes an entry at every level through the configured pipeline
+// and reports whether each was delivered.
+func selftest(args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	config := fs.String("config", "", "configuration file to load, see log.LoadConfig")
+	name := fs.String("name", "selftest", "logger name selecting the level, writer and format")
+	fs.Parse(args)
+
+	if *config != "" {
+		if err := log.LoadConfig(*config); err != nil {
+			fmt.Fprintln(os.Stderr, "logxi:", err)
+			return 1
+		}
+	}
+
+	results, err := log.SelfTest(*name)
+	if results == nil && err != nil {
+		fmt.Fprintln(os.Stderr, "logxi:", err)
+		return 1
+	}
+	status := 0
+	for _, r := range results {
+		enabled := "enabled"
+		if !r.Enabled {
+			enabled = "disabled"
+		}
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s %-8s failed: %v\n", r.Label, enabled, r.Err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s %-8s delivered %d bytes\n", r.Label, enabled, r.Bytes)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "flush failed:", err)
+		status = 1
+	}
+	return status
+}
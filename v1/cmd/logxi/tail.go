@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mgutz/logxi/v1"
+	"github.com/mgutz/logxi/v1/parse"
+)
+
+// tailHistory is the number of lines kept to replay when filters change
+const tailHistory = 1000
+
+// tailReplay is the number of matching lines replayed when filters change
+const tailReplay = 50
+
+const tailHelp = `keys: e w i d t  toggle errors, warnings, info, debug, trace
+      /         filter logger names by a LOGXI pattern, eg api*
+      a         show all levels and loggers
+      ?         show this help`
+
+// tailLine is a line of input. Indented lines continue the entry above
+// them, eg its call stack.
+type tailLine struct {
+	text         string
+	entry        *parse.Entry
+	continuation bool
+}
+
+// tailFilter selects the entries shown by level, logger name and query.
+type tailFilter struct {
+	hidden map[byte]bool
+	name   string
+	// where is the -where query, which keys don't change
+	where *parse.Query
+}
+
+// levelKey returns the key toggling level, 0 if the level is unknown.
+func levelKey(level int) byte {
+	switch {
+	case level == 0:
+		return 0
+	case level <= log.LevelError:
+		return 'e'
+	case level == log.LevelWarn:
+		return 'w'
+	case level == log.LevelNotice, level == log.LevelInfo:
+		return 'i'
+	case level == log.LevelDebug:
+		return 'd'
+	}
+	return 't'
+}
+
+// show reports whether an entry is shown. Lines which aren't entries are
+// always shown.
+func (f *tailFilter) show(entry *parse.Entry) bool {
+	if entry == nil {
+		return true
+	}
+	if f.hidden[levelKey(entry.Level)] {
+		return false
+	}
+	if f.name != "" && !log.MatchName(f.name, entry.Name) {
+		return false
+	}
+	return f.where == nil || f.where.Match(entry)
+}
+
+// toggle applies a key and reports whether the filters changed.
+func (f *tailFilter) toggle(key byte) bool {
+	switch key {
+	case 'e', 'w', 'i', 'd', 't':
+		f.hidden[key] = !f.hidden[key]
+	case 'a':
+		f.hidden = map[byte]bool{}
+		f.name = ""
+	default:
+		return false
+	}
+	return true
+}
+
+func (f *tailFilter) String() string {
+	var shown []string
+	for _, level := range []struct {
+		key   byte
+		label string
+	}{{'e', "ERR"}, {'w', "WRN"}, {'i', "INF"}, {'d', "DBG"}, {'t', "TRC"}} {
+		if !f.hidden[level.key] {
+			shown = append(shown, level.label)
+		}
+	}
+	s := "showing " + strings.Join(shown, " ")
+	if len(shown) == 0 {
+		s = "showing no levels"
+	}
+	if f.name != "" {
+		s += " of " + f.name
+	}
+	if f.where != nil {
+		s += " where " + f.where.String()
+	}
+	return s
+}
+
+// tail prints entries as they are written, filtering them by level, logger
+// name and query. On terminals keys toggle the filters while tailing.
+func tail(args []string) int {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	follow := fs.Bool("f", false, "keep reading the file as it grows")
+	name := fs.String("name", "", "only show loggers matching this LOGXI pattern")
+	levels := fs.String("hide", "", "levels to hide, eg dt for debug and trace")
+	where := fs.String("where", "", `only show entries matching this query, eg 'level>=WRN && fields.user_id=="42"'`)
+	noKeys := fs.Bool("nokeys", false, "don't read keys from the terminal")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: logxi tail [-f] [-name pattern] [-hide levels] [-where query] [file]")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, tailHelp)
+	}
+	fs.Parse(args)
+
+	filter := &tailFilter{hidden: map[byte]bool{}, name: *name}
+	if *where != "" {
+		query, err := parse.ParseQuery(*where)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "logxi:", err)
+			return 2
+		}
+		filter.where = query
+	}
+
+	var input io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "logxi:", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	for i := 0; i < len(*levels); i++ {
+		filter.toggle((*levels)[i])
+	}
+
+	lines := make(chan tailLine, 64)
+	errs := make(chan error, 1)
+	go readTail(input, *follow && input != os.Stdin, lines, errs)
+
+	keys := make(chan byte)
+	if !*noKeys {
+		restore, err := readKeys(keys)
+		if err == nil {
+			defer restore()
+			fmt.Fprintln(os.Stderr, "--", filter, "-- press ? for keys")
+		}
+	}
+
+	t := &tailer{filter: filter, out: bufio.NewWriter(os.Stdout), status: os.Stderr}
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.out.Flush()
+				if err := <-errs; err != nil {
+					fmt.Fprintln(os.Stderr, "logxi:", err)
+					return 1
+				}
+				return 0
+			}
+			t.add(line)
+			if len(lines) == 0 {
+				t.out.Flush()
+			}
+		case key := <-keys:
+			t.key(key)
+			t.out.Flush()
+		}
+	}
+}
+
+// tailer prints the lines shown by its filter and applies keys.
+type tailer struct {
+	filter *tailFilter
+	out    *bufio.Writer
+	// status receives the prompt, help and filter changes, eg stderr
+	status  io.Writer
+	history []tailLine
+	// shown is whether the last entry was shown, which decides whether
+	// its continuation lines are
+	shown bool
+	// prompt is the name pattern being typed, nil unless prompting
+	prompt []byte
+}
+
+func (t *tailer) add(line tailLine) {
+	t.history = append(t.history, line)
+	if len(t.history) > tailHistory {
+		t.history = t.history[len(t.history)-tailHistory:]
+	}
+	if t.prompt != nil {
+		// printed when the prompt ends
+		return
+	}
+	t.print(line)
+}
+
+func (t *tailer) print(line tailLine) {
+	if !line.continuation {
+		t.shown = t.filter.show(line.entry)
+	}
+	if t.shown {
+		t.out.WriteString(line.text)
+		t.out.WriteByte('\n')
+	}
+}
+
+// replay prints the last matching lines of the history.
+func (t *tailer) replay() {
+	fmt.Fprintln(t.status, "--", t.filter, "--")
+	start := len(t.history)
+	for matched := 0; start > 0 && matched < tailReplay; {
+		start--
+		line := t.history[start]
+		if !line.continuation && t.filter.show(line.entry) {
+			matched++
+		}
+	}
+	for _, line := range t.history[start:] {
+		t.print(line)
+	}
+}
+
+func (t *tailer) key(key byte) {
+	if t.prompt != nil {
+		switch key {
+		case '\r', '\n':
+			t.filter.name = string(t.prompt)
+			t.prompt = nil
+			fmt.Fprintln(t.status)
+			t.replay()
+		case 27:
+			// escape
+			t.prompt = nil
+			fmt.Fprintln(t.status)
+			t.replay()
+		case 127, 8:
+			if len(t.prompt) > 0 {
+				t.prompt = t.prompt[:len(t.prompt)-1]
+				fmt.Fprint(t.status, "\b \b")
+			}
+		default:
+			if key >= ' ' && key < 127 {
+				t.prompt = append(t.prompt, key)
+				fmt.Fprintf(t.status, "%c", key)
+			}
+		}
+		return
+	}
+	switch key {
+	case '/':
+		t.prompt = []byte{}
+		t.out.Flush()
+		fmt.Fprint(t.status, "name pattern (empty for all): ")
+	case '?':
+		fmt.Fprintln(t.status, tailHelp)
+		fmt.Fprintln(t.status, "--", t.filter, "--")
+	default:
+		if t.filter.toggle(key) {
+			t.out.Flush()
+			t.replay()
+		}
+	}
+}
+
+// readTail sends the lines of r, parsed if they are entries. When follow
+// is set it waits for more lines at the end of r, like tail -f.
+func readTail(r io.Reader, follow bool, lines chan<- tailLine, errs chan<- error) {
+	defer close(lines)
+	br := bufio.NewReader(r)
+	var partial string
+	for {
+		s, err := br.ReadString('\n')
+		partial += s
+		if err == io.EOF && follow {
+			time.Sleep(250 * time.Millisecond)
+			continue
+		}
+		if err != nil && err != io.EOF {
+			errs <- err
+			return
+		}
+		if text := strings.TrimRight(partial, "\r\n"); partial != "" {
+			line := tailLine{text: text}
+			if text != "" && (text[0] == ' ' || text[0] == '\t') {
+				line.continuation = true
+			} else if entry, perr := parse.Line([]byte(text)); perr == nil {
+				line.entry = entry
+			}
+			lines <- line
+		}
+		partial = ""
+		if err == io.EOF {
+			errs <- nil
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mgutz/logxi/v1"
+	"github.com/mgutz/logxi/v1/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailerKeys(t *testing.T) {
+	var out, status bytes.Buffer
+	tr := &tailer{filter: &tailFilter{hidden: map[byte]bool{}}, out: bufio.NewWriter(&out), status: &status}
+	lines := func() []string {
+		tr.out.Flush()
+		s := strings.TrimSpace(out.String())
+		out.Reset()
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, "\n")
+	}
+	entry := func(text string, level int, name string) tailLine {
+		return tailLine{text: text, entry: &parse.Entry{Level: level, Name: name}}
+	}
+
+	tr.add(entry("api error", log.LevelError, "api"))
+	tr.add(tailLine{text: "    at main.go:12", continuation: true})
+	tr.add(entry("db debug", log.LevelDebug, "db"))
+	tr.add(tailLine{text: "not an entry"})
+	assert.Equal(t, []string{"api error", "    at main.go:12", "db debug", "not an entry"}, lines())
+
+	// hiding a level replays the history without it, continuations follow
+	// their entry
+	tr.key('e')
+	assert.Equal(t, []string{"db debug", "not an entry"}, lines())
+	assert.Contains(t, status.String(), "showing WRN INF DBG TRC")
+	tr.add(entry("api error 2", log.LevelError, "api"))
+	tr.add(tailLine{text: "    at main.go:13", continuation: true})
+	assert.Nil(t, lines())
+
+	// unknown keys don't change the filters
+	status.Reset()
+	tr.key('x')
+	assert.Nil(t, lines())
+	assert.Equal(t, "", status.String())
+
+	// the name prompt buffers lines until it ends
+	tr.key('a')
+	lines()
+	tr.key('/')
+	for _, key := range []byte("dbx") {
+		tr.key(key)
+	}
+	tr.key(127)
+	tr.add(entry("api warning", log.LevelWarn, "api"))
+	assert.Nil(t, lines(), "not printed while prompting")
+	tr.key('\r')
+	assert.Equal(t, "db", tr.filter.name)
+	assert.Equal(t, []string{"db debug", "not an entry"}, lines())
+
+	// escape cancels the prompt and keeps the pattern
+	tr.key('/')
+	tr.key('a')
+	tr.key(27)
+	assert.Equal(t, "db", tr.filter.name)
+	assert.Equal(t, []string{"db debug", "not an entry"}, lines())
+
+	// a shows everything
+	tr.key('a')
+	assert.Len(t, lines(), 7)
+	assert.Equal(t, "", tr.filter.name)
+	assert.Equal(t, "showing ERR WRN INF DBG TRC", tr.filter.String())
+
+	status.Reset()
+	tr.key('?')
+	assert.Contains(t, status.String(), tailHelp)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mattn/go-isatty"
+	"github.com/mgutz/logxi/v1"
+)
+
+// themes prints sample entries at every level in each theme, or in the
+// themes named by args.
+func themes(args []string) int {
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		fmt.Fprintln(os.Stderr, "logxi: themes must be run on a terminal to show colors")
+		return 1
+	}
+
+	if err := renderThemes(os.Stdout, args); err != nil {
+		fmt.Fprintln(os.Stderr, "logxi:", err)
+		return 1
+	}
+	return 0
+}
+
+// renderThemes writes sample entries at every level to w in each theme, or
+// in the themes named by names.
+func renderThemes(w io.Writer, names []string) error {
+	if len(names) == 0 {
+		for name := range log.Themes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	writer := log.NewConcurrentWriter(w)
+	for _, name := range names {
+		colors, ok := log.Themes[name]
+		if !ok {
+			return fmt.Errorf("unknown theme %q", name)
+		}
+		log.ProcessEnv(&log.Configuration{
+			Levels: "*=TRC",
+			Format: "happy,t=15:04:05",
+			Colors: colors,
+		})
+
+		fmt.Fprintf(writer, "%s\n\n", name)
+		logger := log.NewLogger3(writer, "preview", log.NewHappyDevFormatter("preview"))
+		logger.SetLevel(log.LevelAll)
+		sample(logger)
+		fmt.Fprintf(writer, "\n    LOGXI_COLORS=%q\n\n", colors)
+	}
+	return nil
+}
+
+// sample logs an entry at every level with a label, except Fatal which panics.
+func sample(logger log.Logger) {
+	logger.Trace("Reading request", "bytes", 512)
+	logger.Debug("Parsed request", "method", "GET", "path", "/users/42")
+	logger.Info("Serving request", "user", 42)
+	logger.Warn("Slow query", "ms", 812)
+	logger.Error("Could not write response", "err", fmt.Errorf("connection reset"))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mgutz/logxi/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderThemes(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, renderThemes(&buf, []string{"ansi", "windows"}))
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "ansi\n\n"))
+	assert.True(t, strings.Index(out, "ansi") < strings.Index(out, "windows\n\n"))
+	for _, msg := range []string{"Reading request", "Parsed request", "Serving request", "Slow query", "Could not write response"} {
+		assert.Equal(t, 2, strings.Count(out, "preview "+msg), msg+" is logged in each theme")
+	}
+	assert.Contains(t, out, "LOGXI_COLORS="+strconv.Quote(log.Themes["windows"]))
+
+	// every theme by default
+	buf.Reset()
+	assert.NoError(t, renderThemes(&buf, nil))
+	for name := range log.Themes {
+		assert.Contains(t, buf.String(), name+"\n\n")
+	}
+
+	assert.Error(t, renderThemes(&buf, []string{"neon"}))
+}
@@ -0,0 +1,88 @@
+// Package cobralog wires logxi configuration into Cobra commands.
+//
+// Example
+//
+//	root := &cobra.Command{Use: "app"}
+//	cobralog.Bind(root)
+//	serve := &cobra.Command{
+//		Use: "serve",
+//		RunE: func(cmd *cobra.Command, args []string) error {
+//			logger := log.FromContext(cmd.Context())
+//			logger.Info("serving")
+//			...
+//		},
+//	}
+//	root.AddCommand(serve)
+//
+// Then
+//
+//	app serve -vv          # debug
+//	app serve -q           # errors only
+//	app serve --log-format JSON --log-file app.log
+package cobralog
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mgutz/logxi/v1"
+	"github.com/spf13/cobra"
+)
+
+// verboseLevels maps the number of -v flags to a level
+var verboseLevels = []string{"", "INF", "DBG", "TRC"}
+
+// Bind registers persistent --verbose/-v (repeatable), --quiet/-q and the
+// standard logxi flags on cmd. Before any command runs, the flags are applied
+// and a logger named after the command path, eg "app.serve", is stored in
+// the command's context, see log.FromContext.
+//
+// Bind sets PersistentPreRunE on cmd, calling any existing hook afterwards.
+// Cobra only runs the closest persistent hook, so subcommands defining their
+// own skip it.
+func Bind(cmd *cobra.Command) {
+	var verbose int
+	var quiet bool
+	flags := cmd.PersistentFlags()
+	logFlags := log.RegisterFlags(flags)
+	flags.CountVarP(&verbose, "verbose", "v", "increase verbosity: -v info, -vv debug, -vvv trace")
+	flags.BoolVarP(&quiet, "quiet", "q", false, "log errors only")
+
+	preRunE := cmd.PersistentPreRunE
+	preRun := cmd.PersistentPreRun
+	cmd.PersistentPreRun = nil
+	cmd.PersistentPreRunE = func(c *cobra.Command, args []string) error {
+		if logFlags.Level == "" {
+			if quiet {
+				logFlags.Level = "ERR"
+			} else if verbose > 0 {
+				logFlags.Level = verboseLevels[minInt(verbose, len(verboseLevels)-1)]
+			}
+		}
+		if err := logFlags.Apply(); err != nil {
+			return err
+		}
+
+		ctx := c.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		name := strings.Replace(c.CommandPath(), " ", ".", -1)
+		c.SetContext(log.NewContext(ctx, log.New(name)))
+
+		if preRunE != nil {
+			return preRunE(c, args)
+		}
+		if preRun != nil {
+			preRun(c, args)
+		}
+		return nil
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
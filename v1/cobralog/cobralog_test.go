@@ -0,0 +1,48 @@
+package cobralog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mgutz/logxi/v1"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBind(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	var logger log.Logger
+	root := &cobra.Command{Use: "app"}
+	Bind(root)
+	serve := &cobra.Command{
+		Use: "serve",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger = log.FromContext(cmd.Context())
+			logger.Debug("serving", "port", 8080)
+			return nil
+		},
+	}
+	root.AddCommand(serve)
+
+	root.SetArgs([]string{"serve", "--log-level", "DBG", "--log-format", "JSON", "--log-file", filename})
+	assert.NoError(t, root.Execute())
+	defer log.Unregister("app.serve")
+	if assert.NotNil(t, logger) {
+		assert.True(t, logger.IsDebug())
+		assert.False(t, logger.IsTrace())
+		assert.Equal(t, "app.serve", logger.(log.Namer).Name())
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(string(b))), &entry), "format is JSON")
+	assert.Equal(t, "serving", entry[log.KeyMap.Message])
+	assert.Equal(t, "app.serve", entry[log.KeyMap.Name])
+
+	root.SetArgs([]string{"serve", "--log-level", "LOUD"})
+	assert.Error(t, root.Execute())
+}
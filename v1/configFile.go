@@ -0,0 +1,108 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigFile is a JSON configuration file. Settings in the environment
+// selected by LOGXI_ENV override the top level settings so one committed
+// file can describe every environment.
+//
+//	{
+//	    "levels": "*=WRN",
+//	    "format": "JSON",
+//	    "environments": {
+//	        "dev":  {"levels": "*=DBG", "format": "happy"},
+//	        "prod": {"levels": "*=ERR"}
+//	    }
+//	}
+type ConfigFile struct {
+	Configuration
+	Environments map[string]Configuration `json:"environments,omitempty"`
+	// Writers maps logger names or patterns to "stdout", "stderr" or a
+	// file path, see LoadConfig
+	Writers map[string]string `json:"writers,omitempty"`
+}
+
+// Resolve returns the configuration for environment env with environment
+// variables expanded, see ExpandEnv. An empty env selects the top level
+// settings.
+func (cf *ConfigFile) Resolve(env string, strict bool) (*Configuration, error) {
+	conf := cf.Configuration
+	if env != "" {
+		scoped, ok := cf.Environments[env]
+		if !ok {
+			names := make([]string, 0, len(cf.Environments))
+			for name := range cf.Environments {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("logxi: unknown environment %q, expected one of: %s", env, strings.Join(names, ", "))
+		}
+		if scoped.Format != "" {
+			conf.Format = scoped.Format
+		}
+		if scoped.Colors != "" {
+			conf.Colors = scoped.Colors
+		}
+		if scoped.Levels != "" {
+			conf.Levels = scoped.Levels
+		}
+		if scoped.Show != "" {
+			conf.Show = scoped.Show
+		}
+	}
+	if err := conf.Expand(strict); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// LoadConfigFile reads a configuration file and resolves it for the
+// environment named by LOGXI_ENV. Files ending in .yaml or .yml are read as
+// YAML, others as JSON.
+//
+// Example
+//
+//	conf, err := log.LoadConfigFile("logxi.json", true)
+//	if err != nil {
+//		...
+//	}
+//	log.ProcessEnv(conf)
+func LoadConfigFile(filename string, strict bool) (*Configuration, error) {
+	cf, err := readConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return cf.Resolve(os.Getenv("LOGXI_ENV"), strict)
+}
+
+// readConfigFile reads a JSON or YAML configuration file.
+func readConfigFile(filename string) (*ConfigFile, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".yaml" || ext == ".yml" {
+		m, err := parseYAML(b)
+		if err != nil {
+			return nil, fmt.Errorf("logxi: could not parse %s: %v", filename, err)
+		}
+		// the YAML subset maps onto JSON
+		if b, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+	}
+	var cf ConfigFile
+	if err := json.Unmarshal(b, &cf); err != nil {
+		return nil, fmt.Errorf("logxi: could not parse %s: %v", filename, err)
+	}
+	return &cf, nil
+}
@@ -0,0 +1,196 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// configWriters holds the writers set by LoadConfig
+var configWriters = struct {
+	sync.Mutex
+	// patterns maps logger name patterns to destinations
+	patterns map[string]string
+	// files are the files opened for destinations
+	files map[string]*FileWriter
+}{files: map[string]*FileWriter{}}
+
+// LoadConfig reads a JSON or YAML configuration file, see LoadConfigFile,
+// and applies it to registered loggers and loggers created afterwards.
+// Writers map logger names or patterns to "stdout", "stderr" or a file
+// path. Writers only apply to loggers created with New. The configuration
+// is not changed if the file is invalid.
+//
+// Example
+//
+//	# logxi.yaml
+//	levels: "*=WRN,models=DBG"
+//	format: JSON
+//	writers:
+//	  models: /var/log/models.log
+//	  "*": stderr
+func LoadConfig(path string) error {
+	cf, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	conf, err := cf.Resolve(os.Getenv("LOGXI_ENV"), true)
+	if err != nil {
+		return err
+	}
+	if err := setConfigWriters(cf.Writers); err != nil {
+		return err
+	}
+	ProcessEnv(conf)
+	loggers.Lock()
+	for name, logger := range loggers.loggers {
+		if name != "__logxi" {
+			logger.SetLevel(getLogLevel(name))
+		}
+	}
+	loggers.Unlock()
+	return nil
+}
+
+// WatchConfig loads the configuration file at path then reloads it every
+// time it changes, polling every interval. A ConfigMap mounted in a
+// Kubernetes pod is a good fit. Errors reloading the file are logged to
+// InternalLog and the previous configuration is kept. Call the returned
+// func to stop watching.
+//
+// The file is polled with os.Stat rather than watched with fsnotify. It
+// adds no dependency, and a ConfigMap is updated by swapping a symlink in
+// its directory, which file watches don't report but Stat follows.
+//
+// Example
+//
+//	stop, err := log.WatchConfig("/etc/logxi/logxi.yaml", 10*time.Second)
+//	if err != nil {
+//		...
+//	}
+//	defer stop()
+func WatchConfig(path string, interval time.Duration) (stop func(), err error) {
+	if err := LoadConfig(path); err != nil {
+		return nil, err
+	}
+	last, _ := os.Stat(path)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(path)
+			if err != nil || (last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size()) {
+				continue
+			}
+			last = fi
+			if err := LoadConfig(path); err != nil {
+				InternalLog.Error("Could not reload configuration", "file", path, "err", err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}, nil
+}
+
+// setConfigWriters opens the writers of a configuration and sets them on
+// registered loggers. Files no longer used are closed and loggers writing
+// to them write to stdout again.
+func setConfigWriters(patterns map[string]string) error {
+	configWriters.Lock()
+	defer configWriters.Unlock()
+
+	files := map[string]*FileWriter{}
+	for _, dest := range patterns {
+		if dest == "stdout" || dest == "stderr" || files[dest] != nil {
+			continue
+		}
+		fw := configWriters.files[dest]
+		if fw == nil {
+			var err error
+			if fw, err = NewFileWriter(dest, PartitionNone); err != nil {
+				for path, opened := range files {
+					if configWriters.files[path] == nil {
+						opened.Close()
+					}
+				}
+				return err
+			}
+		}
+		files[dest] = fw
+	}
+	previous := map[io.Writer]bool{stderrFallback: true}
+	if isPointer(colorableStdout) {
+		previous[colorableStdout] = true
+	}
+	closed := map[*FileWriter]bool{}
+	for path, fw := range configWriters.files {
+		previous[fw] = true
+		if files[path] == nil {
+			closed[fw] = true
+		}
+	}
+	configWriters.patterns = patterns
+	configWriters.files = files
+
+	loggers.Lock()
+	for name, logger := range loggers.loggers {
+		// loggers created with their own writer are left alone
+		l, ok := logger.(*DefaultLogger)
+		if !ok {
+			continue
+		}
+		writer := l.getWriter()
+		if !isPointer(writer) || !previous[writer] {
+			continue
+		}
+		if w := configWriter(name); w != nil {
+			l.setWriter(w)
+		} else if fw, ok := writer.(*FileWriter); ok && closed[fw] {
+			l.setWriter(colorableStdout)
+		}
+	}
+	loggers.Unlock()
+
+	for fw := range closed {
+		fw.Close()
+	}
+	return nil
+}
+
+// configWriter returns the writer configured for the most specific pattern
+// matching name, or nil. The configWriters lock must be held.
+func configWriter(name string) io.Writer {
+	matches := []string{}
+	for pattern := range configWriters.patterns {
+		if matchName(pattern, name) {
+			matches = append(matches, pattern)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i] == name || matches[j] == name {
+			return matches[i] == name
+		}
+		return literals(matches[i]) > literals(matches[j])
+	})
+	switch dest := configWriters.patterns[matches[0]]; dest {
+	case "stdout":
+		return colorableStdout
+	case "stderr":
+		return stderrFallback
+	default:
+		return configWriters.files[dest]
+	}
+}
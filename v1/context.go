@@ -0,0 +1,99 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx or DefaultLog. Fields
+// extracted from ctx by the rules added with AddContextField and
+// AddContextExtractor are added to every entry of the returned logger.
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return DefaultLog
+	}
+	logger := contextLogger(ctx)
+	if args := extractContext(ctx); len(args) > 0 {
+		logger = newFieldLogger(logger, args)
+	}
+	if isTracing() {
+		logger = &traceLogger{Logger: logger, ctx: ctx}
+	}
+	return logger
+}
+
+// contextLogger returns the logger carried by ctx or DefaultLog, without
+// extracted fields.
+func contextLogger(ctx context.Context) Logger {
+	if carried, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return carried
+	}
+	return DefaultLog
+}
+
+// ContextExtractor returns key-value pairs for values carried by ctx, eg
+// the user ID set by an auth middleware. It returns nil if there are none.
+type ContextExtractor func(ctx context.Context) []interface{}
+
+var contextMutex sync.Mutex
+
+// contextExtractors holds []ContextExtractor
+var contextExtractors atomic.Value
+
+// AddContextExtractor adds an extractor run by FromContext.
+func AddContextExtractor(extractor ContextExtractor) {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	extractors, _ := contextExtractors.Load().([]ContextExtractor)
+	updated := make([]ContextExtractor, len(extractors), len(extractors)+1)
+	copy(updated, extractors)
+	contextExtractors.Store(append(updated, extractor))
+}
+
+// AddContextField makes FromContext add the value ctx carries for key as
+// field. Contexts without the value get no field.
+//
+// Example
+//
+//	// in the auth middleware's package
+//	type userKey struct{}
+//	ctx = context.WithValue(ctx, userKey{}, userID)
+//
+//	// at startup
+//	log.AddContextField("user", userKey{})
+//
+//	// in handlers, entries have user=<userID>
+//	log.FromContext(r.Context()).Info("updated profile")
+func AddContextField(field string, key interface{}) {
+	AddContextExtractor(func(ctx context.Context) []interface{} {
+		if value := ctx.Value(key); value != nil {
+			return []interface{}{field, value}
+		}
+		return nil
+	})
+}
+
+// ClearContextExtractors removes the extractors and fields added.
+func ClearContextExtractors() {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	contextExtractors.Store([]ContextExtractor(nil))
+}
+
+// extractContext runs the extractors on ctx.
+func extractContext(ctx context.Context) []interface{} {
+	extractors, _ := contextExtractors.Load().([]ContextExtractor)
+	var args []interface{}
+	for _, extractor := range extractors {
+		args = append(args, extractor(ctx)...)
+	}
+	return args
+}
@@ -0,0 +1,136 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// CrashReporter writes crash report bundles: recent entries from a ring
+// buffer, a dump of all goroutines, build info and the logging
+// configuration, a flight recorder for support tickets.
+type CrashReporter struct {
+	// Dir is where bundles are written. Defaults to os.TempDir().
+	Dir string
+	// Ring supplies recent entries. Optional.
+	Ring *RingBuffer
+	// Upload receives each bundle instead of writing it to Dir. Optional.
+	Upload func(name string, bundle []byte) error
+}
+
+type crashBundle struct {
+	Time       time.Time      `json:"time"`
+	Reason     string         `json:"reason"`
+	BootID     string         `json:"bootID"`
+	PID        int            `json:"pid"`
+	Hostname   string         `json:"hostname"`
+	Go         string         `json:"go"`
+	Build      string         `json:"build,omitempty"`
+	Config     *Configuration `json:"config"`
+	Entries    []RingEntry    `json:"entries"`
+	Goroutines string         `json:"goroutines"`
+}
+
+var crashReporter *CrashReporter
+
+// SetCrashReporter installs a reporter which writes a bundle whenever Fatal
+// is logged. Pass nil to remove it.
+func SetCrashReporter(cr *CrashReporter) {
+	pkgMutex.Lock()
+	crashReporter = cr
+	pkgMutex.Unlock()
+}
+
+func reportCrash(reason string) {
+	pkgMutex.Lock()
+	cr := crashReporter
+	pkgMutex.Unlock()
+	if cr == nil {
+		return
+	}
+	if _, err := cr.Write(reason); err != nil {
+		InternalLog.Error("Could not write crash report", "err", err)
+	}
+}
+
+// Write writes a bundle on demand and returns the file name or, when
+// uploading, the name passed to Upload.
+func (cr *CrashReporter) Write(reason string) (string, error) {
+	now := time.Now()
+	hostname, _ := os.Hostname()
+	config := cfg().config
+	bundle := &crashBundle{
+		Time:       now,
+		Reason:     reason,
+		BootID:     BootID,
+		PID:        pid,
+		Hostname:   hostname,
+		Go:         runtime.Version(),
+		Config:     &config,
+		Entries:    []RingEntry{},
+		Goroutines: goroutineDump(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		bundle.Build = info.String()
+	}
+	if cr.Ring != nil {
+		for _, entry := range cr.Ring.Entries() {
+			bundle.Entries = append(bundle.Entries, jsonSafeEntry(entry))
+		}
+	}
+
+	b, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	name := "logxi-crash-" + now.Format("20060102T150405") + "-" + BootID + ".json"
+	if cr.Upload != nil {
+		return name, cr.Upload(name, b)
+	}
+	dir := cr.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	filename := filepath.Join(dir, name)
+	return filename, ioutil.WriteFile(filename, b, 0600)
+}
+
+// jsonSafeEntry converts field values which don't marshal meaningfully,
+// like errors, to strings.
+func jsonSafeEntry(entry RingEntry) RingEntry {
+	if entry.Fields == nil {
+		return entry
+	}
+	fields := make(map[string]interface{}, len(entry.Fields))
+	for key, val := range entry.Fields {
+		if err, ok := val.(error); ok {
+			fields[key] = err.Error()
+		} else if _, err := json.Marshal(val); err != nil {
+			fields[key] = fmt.Sprint(val)
+		} else {
+			fields[key] = val
+		}
+	}
+	entry.Fields = fields
+	return entry
+}
+
+func goroutineDump() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		if len(buf) >= 16*1024*1024 {
+			return string(buf)
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
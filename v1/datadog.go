@@ -0,0 +1,74 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+// Keys of the trace correlation fields Datadog joins with APM traces.
+const (
+	DatadogTraceIDKey = "dd.trace_id"
+	DatadogSpanIDKey  = "dd.span_id"
+)
+
+// datadogStatuses are the Datadog statuses of levels
+var datadogStatuses = map[int]string{
+	LevelEmergency: "emergency",
+	LevelAlert:     "alert",
+	LevelFatal:     "critical",
+	LevelError:     "error",
+	LevelWarn:      "warn",
+	LevelNotice:    "notice",
+	LevelInfo:      "info",
+	LevelDebug:     "debug",
+	LevelTrace:     "debug",
+}
+
+func datadogStatus(level int) string {
+	if status, ok := datadogStatuses[level]; ok {
+		return status
+	}
+	return "info"
+}
+
+// datadogField returns the key and value of a pair in Datadog mode. Bound
+// trace_id and span_id fields are renamed to the correlation keys and IDs
+// are written as strings, since 64-bit IDs lose precision as JSON numbers.
+func datadogField(key string, val interface{}) (string, interface{}) {
+	switch key {
+	case "trace_id":
+		key = DatadogTraceIDKey
+	case "span_id":
+		key = DatadogSpanIDKey
+	case DatadogTraceIDKey, DatadogSpanIDKey:
+	default:
+		return key, val
+	}
+	if val == nil {
+		return key, val
+	}
+	return key, fmt.Sprint(val)
+}
+
+// DatadogExtractor returns a ContextExtractor adding the trace and span
+// IDs of ctx to entries logged with FromContext. ids returns them, eg from
+// the active span of the Datadog tracer.
+//
+// Example
+//
+//	log.AddContextExtractor(log.DatadogExtractor(func(ctx context.Context) (uint64, uint64, bool) {
+//		span, ok := tracer.SpanFromContext(ctx)
+//		if !ok {
+//			return 0, 0, false
+//		}
+//		return span.Context().TraceID(), span.Context().SpanID(), true
+//	}))
+func DatadogExtractor(ids func(ctx context.Context) (traceID, spanID uint64, ok bool)) ContextExtractor {
+	return func(ctx context.Context) []interface{} {
+		traceID, spanID, ok := ids(ctx)
+		if !ok {
+			return nil
+		}
+		return []interface{}{DatadogTraceIDKey, traceID, DatadogSpanIDKey, spanID}
+	}
+}
@@ -3,22 +3,43 @@ package log
 import (
 	"fmt"
 	"io"
+	"sync/atomic"
+	"time"
 )
 
 // DefaultLogger is the default logger for this package.
 type DefaultLogger struct {
-	writer    io.Writer
-	name      string
-	level     int
-	formatter Formatter
+	// writer holds a writerBox so the writer can be replaced while logging
+	writer atomic.Value
+	name   string
+	level  int32
+	// formatter holds a formatterBox so the formatter can be replaced
+	// while logging, eg by AdminHandler
+	formatter atomic.Value
+	blocking  bool
+	stack     stackOptions
 }
 
-// NewLogger creates a new default logger. If writer is not concurrent
-// safe, wrap it with NewConcurrentWriter.
+// NewLogger creates a new default logger writing to writer instead of
+// stdout, eg a file, a net.Conn or a buffer in tests. If writer is not
+// concurrent safe, wrap it with NewConcurrentWriter.
+//
+// Example
+//
+//	conn, err := net.Dial("tcp", "logs:5000")
+//	if err != nil {
+//		...
+//	}
+//	logger := log.NewLogger(log.NewConcurrentWriter(conn), "shipper")
 func NewLogger(writer io.Writer, name string) Logger {
-	formatter, err := createFormatter(name, logxiFormat)
+	name = sanitizeName(name)
+	format := writerFormat(writer)
+	formatter, err := createFormatter(name, format)
 	if err != nil {
-		panic("Could not create formatter")
+		writer = fallback("formatter "+format, err)
+		formatter = NewJSONFormatter(name)
+	} else {
+		recovered("formatter " + format)
 	}
 	return NewLogger3(writer, name, formatter)
 }
@@ -26,6 +47,7 @@ func NewLogger(writer io.Writer, name string) Logger {
 // NewLogger3 creates a new logger with a writer, name and formatter. If writer is not concurrent
 // safe, wrap it with NewConcurrentWriter.
 func NewLogger3(writer io.Writer, name string, formatter Formatter) Logger {
+	name = sanitizeName(name)
 	var level int
 	if name != "__logxi" {
 		// if err is returned, then it means the log is disabled
@@ -34,13 +56,17 @@ func NewLogger3(writer io.Writer, name string, formatter Formatter) Logger {
 			return NullLog
 		}
 	}
+	return newDefaultLogger(writer, name, formatter, level)
+}
 
+// newDefaultLogger creates and registers a logger.
+func newDefaultLogger(writer io.Writer, name string, formatter Formatter, level int) *DefaultLogger {
 	log := &DefaultLogger{
-		formatter: formatter,
-		writer:    writer,
-		name:      name,
-		level:     level,
+		name:  name,
+		level: int32(level),
 	}
+	log.setWriter(writer)
+	log.SetFormatter(formatter)
 
 	// TODO loggers will be used when watching changes to configuration such
 	// as in consul, etcd
@@ -50,12 +76,59 @@ func NewLogger3(writer io.Writer, name string, formatter Formatter) Logger {
 	return log
 }
 
-// New creates a colorable default logger.
-func New(name string) Logger {
-	return NewLogger(colorableStdout, name)
+// New creates a colorable default logger. It writes to the writer set for
+// name by LoadConfig, if any. Options override the defaults taken from the
+// environment.
+//
+// Example
+//
+//	logger := log.New("api", log.WithLevel(log.LevelDebug), log.WithFields("region", region))
+func New(name string, opts ...Option) Logger {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	name = sanitizeName(name)
+
+	writer := o.writer
+	if writer == nil {
+		writer = colorableStdout
+		configWriters.Lock()
+		if w := configWriter(name); w != nil {
+			writer = w
+		}
+		configWriters.Unlock()
+	}
+
+	var logger Logger
+	switch {
+	case o.level != 0:
+		// an explicit level enables loggers disabled by the environment
+		formatter := o.formatter
+		if formatter == nil {
+			var err error
+			format := writerFormat(writer)
+			if formatter, err = createFormatter(name, format); err != nil {
+				writer = fallback("formatter "+format, err)
+				formatter = NewJSONFormatter(name)
+			} else {
+				recovered("formatter " + format)
+			}
+		}
+		logger = newDefaultLogger(writer, name, formatter, o.level)
+	case o.formatter != nil:
+		logger = NewLogger3(writer, name, o.formatter)
+	default:
+		logger = NewLogger(writer, name)
+	}
+
+	if len(o.fields) > 0 {
+		logger = newFieldLogger(logger, o.fields)
+	}
+	return logger
 }
 
-// Trace logs a debug entry.
+// Trace logs a trace entry.
 func (l *DefaultLogger) Trace(msg string, args ...interface{}) {
 	l.Log(LevelTrace, msg, args)
 }
@@ -83,6 +156,10 @@ func (l *DefaultLogger) Warn(msg string, args ...interface{}) error {
 
 		return nil
 	}
+	if hasEscalationRules() {
+		// the warning may be escalated to a level this logger logs
+		l.Log(LevelWarn, msg, args)
+	}
 	return nil
 }
 
@@ -102,48 +179,167 @@ func (l *DefaultLogger) Error(msg string, args ...interface{}) error {
 	return l.extractLogError(LevelError, msg, args)
 }
 
-// Fatal logs a fatal entry then panics.
+// Fatal logs a fatal entry, flushes every logger then panics.
 func (l *DefaultLogger) Fatal(msg string, args ...interface{}) {
 	l.extractLogError(LevelFatal, msg, args)
+	reportCrash(msg)
+	Flush(FlushTimeout)
 	defer panic("Exit due to fatal error: ")
 }
 
 // Log logs a leveled entry.
 func (l *DefaultLogger) Log(level int, msg string, args []interface{}) {
+	if target := adoptedBy(); target != nil {
+		target.Log(level, msg, adoptArgs(l.name, args))
+		return
+	}
+	level, args = escalate(l.name, level, msg, args)
 	// log if the log level (warn=4) >= level of message (err=3)
-	if l.level < level || silent {
+	if l.getLevel() < level || silent || (quietMode && level > LevelFatal) {
+		return
+	}
+	if isLinting() {
+		lintMessage(l.name, msg, l.stack.skip)
+	}
+	args = expandPairs(args)
+	args = annotateSLO(l.name, level, args)
+	args = annotateErrChain(args)
+	args = annotateFirstSeen(l.name, level, msg, args)
+	args = annotateTTL(level, args)
+	writer := l.entryWriter(level)
+	formatter := l.getFormatter()
+	if isStatsEnabled() {
+		cw := &countingWriter{writer: writer}
+		formatEntry(formatter, cw, level, l.name, msg, args, l.stack)
+		stats.get(l.name).record(time.Now(), cw.n)
 		return
 	}
-	l.formatter.Format(l.writer, level, msg, args)
+	formatEntry(formatter, writer, level, l.name, msg, args, l.stack)
+}
+
+// entryWriter returns the writer of an entry logged at level.
+func (l *DefaultLogger) entryWriter(level int) io.Writer {
+	dest := l.getWriter()
+	writer := dest
+	if l.blocking {
+		if wb, ok := unwrapWriter(writer).(writeBlocker); ok {
+			writer = writerFunc(wb.WriteBlocking)
+		}
+	} else if lw, ok := unwrapWriter(writer).(levelWriter); ok {
+		writer = writerFunc(func(p []byte) (int, error) {
+			return lw.WriteLevel(level, p)
+		})
+	}
+	if stripsColors(dest) {
+		writer = stripColors(writer)
+	}
+	return writer
 }
 
-// IsTrace determines if this logger logs a debug statement.
+// IsTrace determines if this logger logs a trace statement.
 func (l *DefaultLogger) IsTrace() bool {
+	if target := adoptedBy(); target != nil {
+		return target.IsTrace()
+	}
 	// DEBUG(7) >= TRACE(10)
-	return l.level >= LevelTrace
+	return l.getLevel() >= LevelTrace
 }
 
 // IsDebug determines if this logger logs a debug statement.
 func (l *DefaultLogger) IsDebug() bool {
-	return l.level >= LevelDebug
+	if target := adoptedBy(); target != nil {
+		return target.IsDebug()
+	}
+	return l.getLevel() >= LevelDebug
 }
 
 // IsInfo determines if this logger logs an info statement.
 func (l *DefaultLogger) IsInfo() bool {
-	return l.level >= LevelInfo
+	if target := adoptedBy(); target != nil {
+		return target.IsInfo()
+	}
+	return l.getLevel() >= LevelInfo
 }
 
 // IsWarn determines if this logger logs a warning statement.
 func (l *DefaultLogger) IsWarn() bool {
-	return l.level >= LevelWarn
+	if target := adoptedBy(); target != nil {
+		return target.IsWarn()
+	}
+	return l.getLevel() >= LevelWarn
+}
+
+// Name returns the canonical name of this logger.
+func (l *DefaultLogger) Name() string {
+	return l.name
 }
 
-// SetLevel sets the level of this logger.
+// writerBox boxes writers so writers of different types can be stored in
+// the same atomic.Value
+type writerBox struct {
+	io.Writer
+}
+
+// getWriter returns the writer of this logger. The writer is accessed
+// atomically so it can be replaced while logging, eg by LoadConfig.
+func (l *DefaultLogger) getWriter() io.Writer {
+	if box, ok := l.writer.Load().(writerBox); ok {
+		return box.Writer
+	}
+	return nil
+}
+
+// setWriter replaces the writer of this logger.
+func (l *DefaultLogger) setWriter(writer io.Writer) {
+	l.writer.Store(writerBox{writer})
+}
+
+// getLevel returns the level of this logger. The level is accessed
+// atomically so it can be changed while logging.
+func (l *DefaultLogger) getLevel() int {
+	return int(atomic.LoadInt32(&l.level))
+}
+
+// SetLevel sets the level of this logger. It takes effect immediately and
+// is safe to call while logging.
 func (l *DefaultLogger) SetLevel(level int) {
-	l.level = level
+	before := int(atomic.SwapInt32(&l.level, int32(level)))
+	if before != level {
+		auditLevel(l.name, before, level, 1)
+	}
+}
+
+// SetBlocking makes this logger wait until its entries are written when its
+// writer is asynchronous, eg an AsyncWriter, so entries are not lost when
+// the process dies. Other loggers sharing the writer are not affected.
+func (l *DefaultLogger) SetBlocking(blocking bool) {
+	l.blocking = blocking
+}
+
+// SetStackCapture tunes the call stacks captured for entries of this
+// logger: skip frames above the logxi frames are skipped, eg those of a
+// logging wrapper, and at most depth frames are captured. A depth of 0 uses
+// DefaultStackDepth. See SetStackCapturer to change how stacks are captured.
+func (l *DefaultLogger) SetStackCapture(skip, depth int) {
+	l.stack = stackOptions{skip: skip, depth: depth}
+}
+
+// formatterBox boxes formatters so formatters of different types can be
+// stored in the same atomic.Value
+type formatterBox struct {
+	Formatter
+}
+
+// getFormatter returns the formatter of this logger.
+func (l *DefaultLogger) getFormatter() Formatter {
+	if box, ok := l.formatter.Load().(formatterBox); ok {
+		return box.Formatter
+	}
+	return nil
 }
 
-// SetFormatter set the formatter for this logger.
+// SetFormatter set the formatter for this logger. It takes effect
+// immediately and is safe to call while logging.
 func (l *DefaultLogger) SetFormatter(formatter Formatter) {
-	l.formatter = formatter
+	l.formatter.Store(formatterBox{formatter})
 }
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Deprecation records the first use of a deprecated feature.
+type Deprecation struct {
+	Feature string
+	Message string
+	Caller  string
+	Time    time.Time
+	Count   int
+}
+
+type deprecationMap struct {
+	sync.Mutex
+	features map[string]*Deprecation
+}
+
+var deprecations = &deprecationMap{
+	features: map[string]*Deprecation{},
+}
+
+// Deprecated logs a warning the first time feature is used in this process.
+// Subsequent uses are only counted. The entry includes the file and line of
+// the code calling the deprecated API, which is the caller of the function
+// that calls Deprecated.
+//
+// Example
+//
+//	func OldThing() {
+//		log.Deprecated("mypkg.OldThing", "Use NewThing instead", "removal", "v2")
+//	}
+func Deprecated(feature string, msg string, args ...interface{}) {
+	deprecations.Lock()
+	d := deprecations.features[feature]
+	if d != nil {
+		d.Count++
+		deprecations.Unlock()
+		return
+	}
+	d = &Deprecation{
+		Feature: feature,
+		Message: msg,
+		Caller:  callerOf(3),
+		Time:    time.Now(),
+		Count:   1,
+	}
+	deprecations.features[feature] = d
+	deprecations.Unlock()
+
+	kv := make([]interface{}, 0, len(args)+4)
+	kv = append(kv, "feature", feature, "caller", d.Caller)
+	kv = append(kv, args...)
+	DefaultLog.Warn(msg, kv...)
+}
+
+// Deprecations returns the deprecated features which have been used in this
+// process sorted by feature name.
+func Deprecations() []Deprecation {
+	deprecations.Lock()
+	defer deprecations.Unlock()
+	result := make([]Deprecation, 0, len(deprecations.features))
+	for _, d := range deprecations.features {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Feature < result[j].Feature
+	})
+	return result
+}
+
+// callerOf returns "file:line" of the caller skip frames above callerOf.
+func callerOf(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+	return file + ":" + strconv.Itoa(line)
+}
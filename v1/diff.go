@@ -0,0 +1,271 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+// maxDiffLines limits the size of line based diffs. Larger strings are
+// reported as a single replacement.
+const maxDiffLines = 1000
+
+type diffOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Line  int         `json:"line,omitempty"`
+	From  interface{} `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// DiffValue is the difference between two values. Use Diff to create it.
+//
+// HappyDevFormatter prints additions and deletions in color below the
+// entry. JSONFormatter logs a patch, an array of operations
+// {"op": "add"|"remove"|"replace", "path" or "line", "from", "value"}.
+type DiffValue struct {
+	ops []diffOp
+}
+
+// Diff returns the difference between before and after. Strings are
+// compared line by line. Any other value is compared field by field using
+// its JSON representation which makes it suitable for structs and maps.
+//
+// Example
+//
+//	logger.Info("config changed", "diff", log.Diff(oldConfig, newConfig))
+func Diff(before, after interface{}) *DiffValue {
+	s1, ok1 := before.(string)
+	s2, ok2 := after.(string)
+	if ok1 && ok2 {
+		return &DiffValue{ops: diffLines(s1, s2)}
+	}
+	return &DiffValue{ops: diffFields(before, after)}
+}
+
+// Changed returns whether before and after differ.
+func (dv *DiffValue) Changed() bool {
+	return len(dv.ops) > 0
+}
+
+// MarshalJSON marshals the diff as a patch.
+func (dv *DiffValue) MarshalJSON() ([]byte, error) {
+	if dv.ops == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(dv.ops)
+}
+
+func (dv *DiffValue) String() string {
+	b, err := dv.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+func (dv *DiffValue) summary() string {
+	var added, removed, replaced int
+	for _, op := range dv.ops {
+		switch op.Op {
+		case "add":
+			added++
+		case "remove":
+			removed++
+		case "replace":
+			replaced++
+		}
+	}
+	if added+removed+replaced == 0 {
+		return "no changes"
+	}
+	s := "+" + strconv.Itoa(added) + " -" + strconv.Itoa(removed)
+	if replaced > 0 {
+		s += " ~" + strconv.Itoa(replaced)
+	}
+	return s
+}
+
+func (dv *DiffValue) happyValue() (string, string) {
+	if len(dv.ops) == 0 {
+		return dv.summary(), ""
+	}
+	conf := cfg()
+	reset := ansi.Reset
+	if conf.disableColors {
+		reset = ""
+	}
+
+	buf := pool.Get()
+	defer pool.Put(buf)
+	// line diffs have no paths
+	writePath := func(path string) {
+		if path != "" {
+			buf.WriteString(path)
+			buf.WriteString(conf.assignment())
+		}
+	}
+	for _, op := range dv.ops {
+		switch op.Op {
+		case "add":
+			buf.WriteString(conf.theme.Added)
+			buf.WriteString("+ ")
+		case "remove":
+			buf.WriteString(conf.theme.Removed)
+			buf.WriteString("- ")
+		case "replace":
+			buf.WriteString(conf.theme.Removed)
+			buf.WriteString("- ")
+			writePath(op.Path)
+			buf.WriteString(diffString(op.From))
+			buf.WriteString(reset)
+			buf.WriteRune('\n')
+			buf.WriteString(conf.theme.Added)
+			buf.WriteString("+ ")
+		}
+		writePath(op.Path)
+		buf.WriteString(diffString(op.Value))
+		buf.WriteString(reset)
+		buf.WriteRune('\n')
+	}
+	return dv.summary(), buf.String()
+}
+
+func diffString(val interface{}) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	return string(b)
+}
+
+// diffLines computes the longest common subsequence of lines in a and b.
+func diffLines(a, b string) []diffOp {
+	if a == b {
+		return nil
+	}
+	la := strings.Split(a, "\n")
+	lb := strings.Split(b, "\n")
+	if len(la) > maxDiffLines || len(lb) > maxDiffLines {
+		return []diffOp{{Op: "replace", From: a, Value: b}}
+	}
+
+	// lcs[i][j] is the length of the LCS of la[i:] and lb[j:]
+	lcs := make([][]int, len(la)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(lb)+1)
+	}
+	for i := len(la) - 1; i >= 0; i-- {
+		for j := len(lb) - 1; j >= 0; j-- {
+			if la[i] == lb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = maxInt(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(la) && j < len(lb) {
+		switch {
+		case la[i] == lb[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{Op: "remove", Line: i + 1, Value: la[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{Op: "add", Line: j + 1, Value: lb[j]})
+			j++
+		}
+	}
+	for ; i < len(la); i++ {
+		ops = append(ops, diffOp{Op: "remove", Line: i + 1, Value: la[i]})
+	}
+	for ; j < len(lb); j++ {
+		ops = append(ops, diffOp{Op: "add", Line: j + 1, Value: lb[j]})
+	}
+	return ops
+}
+
+// diffFields compares the flattened JSON representation of a and b.
+func diffFields(a, b interface{}) []diffOp {
+	fa := map[string]interface{}{}
+	fb := map[string]interface{}{}
+	flattenJSON(fa, "", toJSONValue(a))
+	flattenJSON(fb, "", toJSONValue(b))
+
+	paths := make([]string, 0, len(fa)+len(fb))
+	for path := range fa {
+		paths = append(paths, path)
+	}
+	for path := range fb {
+		if _, ok := fa[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var ops []diffOp
+	for _, path := range paths {
+		va, inA := fa[path]
+		vb, inB := fb[path]
+		switch {
+		case !inA:
+			ops = append(ops, diffOp{Op: "add", Path: path, Value: vb})
+		case !inB:
+			ops = append(ops, diffOp{Op: "remove", Path: path, Value: va})
+		case diffString(va) != diffString(vb):
+			ops = append(ops, diffOp{Op: "replace", Path: path, From: va, Value: vb})
+		}
+	}
+	return ops
+}
+
+func toJSONValue(val interface{}) interface{} {
+	b, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	var result interface{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return string(b)
+	}
+	return result
+}
+
+func flattenJSON(m map[string]interface{}, prefix string, val interface{}) {
+	var join = func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			m[prefix] = v
+		}
+		for key, child := range v {
+			flattenJSON(m, join(key), child)
+		}
+	case []interface{}:
+		if len(v) == 0 && prefix != "" {
+			m[prefix] = v
+		}
+		for i, child := range v {
+			flattenJSON(m, join(strconv.Itoa(i)), child)
+		}
+	default:
+		m[prefix] = v
+	}
+}
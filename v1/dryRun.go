@@ -0,0 +1,165 @@
+package log
+
+import (
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+// DryRunCount is the volume a logger would have emitted at a level.
+type DryRunCount struct {
+	Name    string
+	Level   int
+	Entries int64
+	Bytes   int64
+}
+
+type dryRunKey struct {
+	name  string
+	level int
+}
+
+// DryRun formats entries exactly like a real sink but only counts them,
+// optionally keeping a sample of the most recent formatted entries. Use it to
+// measure what a proposed configuration would emit before enabling it in
+// production.
+//
+// Example
+//
+//	dr := log.NewDryRun("*=INF,models=DBG", log.FormatJSON, 10)
+//	logger := dr.Logger("models")
+//	...
+//	for _, count := range dr.Counts() {
+//		fmt.Println(count.Name, log.LevelMap[count.Level], count.Entries, count.Bytes)
+//	}
+type DryRun struct {
+	sync.Mutex
+	nameLevelMap map[string]int
+	format       string
+	counts       map[dryRunKey]*DryRunCount
+	samples      []string
+	sampleSize   int
+	next         int
+}
+
+// NewDryRun creates a dry run for the proposed LOGXI levels and
+// LOGXI_FORMAT formatter kind. Empty values use the current configuration.
+// sampleSize is the number of formatted entries to keep.
+func NewDryRun(levels string, format string, sampleSize int) *DryRun {
+	dr := &DryRun{
+		format:     format,
+		counts:     map[dryRunKey]*DryRunCount{},
+		sampleSize: sampleSize,
+	}
+	if levels != "" {
+		dr.nameLevelMap = parseLogxiEnv(levels)
+	}
+	return dr
+}
+
+func (dr *DryRun) level(name string) int {
+	if dr.nameLevelMap == nil {
+		return getLogLevel(name)
+	}
+	return levelFromMap(dr.nameLevelMap, name)
+}
+
+// Logger returns a logger whose entries are measured by the dry run. The
+// logger is not registered and never writes output.
+func (dr *DryRun) Logger(name string) Logger {
+	formatter, err := createFormatter(name, dr.format)
+	if err != nil {
+		panic("Could not create formatter")
+	}
+	l := &DefaultLogger{
+		name:  name,
+		level: LevelAll,
+	}
+	l.setWriter(ioutil.Discard)
+	l.SetFormatter(dr.Formatter(name, formatter))
+	return l
+}
+
+// Formatter wraps formatter so entries are measured by the dry run instead
+// of being written.
+func (dr *DryRun) Formatter(name string, formatter Formatter) Formatter {
+	return &dryRunFormatter{dryRun: dr, name: name, formatter: formatter}
+}
+
+func (dr *DryRun) record(name string, level int, entry []byte) {
+	dr.Lock()
+	defer dr.Unlock()
+	key := dryRunKey{name: name, level: level}
+	count := dr.counts[key]
+	if count == nil {
+		count = &DryRunCount{Name: name, Level: level}
+		dr.counts[key] = count
+	}
+	count.Entries++
+	count.Bytes += int64(len(entry))
+
+	if dr.sampleSize <= 0 {
+		return
+	}
+	if len(dr.samples) < dr.sampleSize {
+		dr.samples = append(dr.samples, string(entry))
+	} else {
+		dr.samples[dr.next] = string(entry)
+	}
+	dr.next = (dr.next + 1) % dr.sampleSize
+}
+
+// Counts returns the volume by logger and level sorted by name then level.
+func (dr *DryRun) Counts() []DryRunCount {
+	dr.Lock()
+	defer dr.Unlock()
+	result := make([]DryRunCount, 0, len(dr.counts))
+	for _, count := range dr.counts {
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name == result[j].Name {
+			return result[i].Level < result[j].Level
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Samples returns the most recent formatted entries, oldest first.
+func (dr *DryRun) Samples() []string {
+	dr.Lock()
+	defer dr.Unlock()
+	result := make([]string, 0, len(dr.samples))
+	if len(dr.samples) < dr.sampleSize {
+		return append(result, dr.samples...)
+	}
+	result = append(result, dr.samples[dr.next:]...)
+	return append(result, dr.samples[:dr.next]...)
+}
+
+// Reset clears counts and samples.
+func (dr *DryRun) Reset() {
+	dr.Lock()
+	defer dr.Unlock()
+	dr.counts = map[dryRunKey]*DryRunCount{}
+	dr.samples = nil
+	dr.next = 0
+}
+
+type dryRunFormatter struct {
+	dryRun    *DryRun
+	name      string
+	formatter Formatter
+}
+
+func (df *dryRunFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	if df.dryRun.level(df.name) < level {
+		return
+	}
+	buf := pool.Get()
+	defer pool.Put(buf)
+	formatNamed(df.formatter, buf, level, df.name, msg, args)
+	df.dryRun.record(df.name, level, buf.Bytes())
+}
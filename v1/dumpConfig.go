@@ -0,0 +1,115 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DumpConfig logs the resolved logging configuration as a single info
+// entry to DefaultLog: the level of each LOGXI pattern, the format and its
+// options, the colors, the level and format of registered loggers and
+// their sinks. Call it at startup, after loggers are created, so the logs
+// of a deployment show what it is logging.
+//
+// Example
+//
+//	func main() {
+//		flags.Apply()
+//		log.DumpConfig()
+//		//=> {"_m":"logging config", "levels":{"*":"WRN","models":"DBG"}, "format":"JSON", ...}
+//	}
+func DumpConfig() {
+	if !DefaultLog.IsInfo() {
+		return
+	}
+	DefaultLog.Info("logging config", configArgs()...)
+}
+
+func configArgs() []interface{} {
+	conf := cfg()
+	var levels []interface{}
+	patterns := make([]string, 0, len(conf.logxiNameLevelMap))
+	for pattern := range conf.logxiNameLevelMap {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		levels = append(levels, pattern, levelLabel(conf.logxiNameLevelMap[pattern]))
+	}
+
+	var registered []interface{}
+	for _, lc := range Loggers() {
+		registered = append(registered, Group(lc.Name, "level", lc.Level, "format", lc.Format))
+	}
+
+	sinks := []string{}
+	seen := map[string]bool{}
+	for _, writer := range loggerWriters() {
+		desc := describeWriter(writer)
+		if !seen[desc] {
+			seen[desc] = true
+			sinks = append(sinks, desc)
+		}
+	}
+	sort.Strings(sinks)
+
+	return []interface{}{
+		Group("levels", levels...),
+		"format", conf.logxiFormat,
+		"formatOptions", conf.config.Format,
+		"timeFormat", conf.timeFormat,
+		"colors", conf.config.Colors,
+		"show", conf.config.Show,
+		"colorsEnabled", !conf.disableColors && (isTerminal || stderrTerminal),
+		"silent", silent,
+		"quiet", quietMode,
+		Group("loggers", registered...),
+		"sinks", sinks,
+	}
+}
+
+func levelLabel(level int) string {
+	if label, ok := LevelMap[level]; ok && label != "" {
+		return label
+	}
+	return fmt.Sprintf("%d", level)
+}
+
+// describeWriter returns a short description of a sink, eg "stdout" or
+// "async(1024) file:/var/log/api.log".
+func describeWriter(writer io.Writer) string {
+	if writer == colorableStdout {
+		return "stdout"
+	}
+	switch w := unwrapWriter(writer).(type) {
+	case *os.File:
+		switch w {
+		case os.Stdout:
+			return "stdout"
+		case os.Stderr:
+			return "stderr"
+		}
+		return "file:" + w.Name()
+	case *AsyncWriter:
+		return fmt.Sprintf("async(%d) %s", cap(w.queue), describeWriter(w.writer))
+	case *FileWriter:
+		return "file:" + filepath.Join(w.dir, w.name)
+	case *RotatingFileWriter:
+		return "rotating:" + w.path
+	case *LevelSplitWriter:
+		return fmt.Sprintf("split(%s) %s, %s", levelLabel(w.threshold), describeWriter(w.stdout), describeWriter(w.stderr))
+	case *NetWriter:
+		return w.network + "://" + w.addr
+	case *SyslogSink:
+		if w.network == "" {
+			return "syslog"
+		}
+		return "syslog:" + w.network + "://" + w.addr
+	case *JournaldSink:
+		return "journald"
+	}
+	return fmt.Sprintf("%T", writer)
+}
@@ -0,0 +1,155 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// FormatECS uses ECSFormatter
+const FormatECS = "ecs"
+
+// ECSVersion is the version of the Elastic Common Schema written by
+// ECSFormatter.
+const ECSVersion = "1.6.0"
+
+// DefaultECSNamespace is the field key-value pairs are nested under.
+const DefaultECSNamespace = "fields"
+
+// ecsLevels are the log.level values of levels
+var ecsLevels = map[int]string{
+	LevelEmergency: "emergency",
+	LevelAlert:     "alert",
+	LevelFatal:     "fatal",
+	LevelError:     "error",
+	LevelWarn:      "warn",
+	LevelNotice:    "notice",
+	LevelInfo:      "info",
+	LevelDebug:     "debug",
+	LevelTrace:     "trace",
+}
+
+// ECSFormatter formats entries as Elastic Common Schema JSON documents,
+// one per line. The message is message, the level log.level, the logger
+// name log.logger and the caller log.origin.*. The first error argument is
+// error.message and error.type, with the call stack as error.stack_trace.
+// Other key-value pairs are nested under a namespace, "fields" by default,
+// so they don't collide with ECS fields. Select it with LOGXI_FORMAT=ecs.
+//
+// Example
+//
+//	ef := log.NewECSFormatter("api")
+//	ef.SetNamespace("api")
+//	logger := log.NewLogger3(os.Stdout, "api", ef)
+//	logger.Error("charge failed", "order", 42, "err", err)
+//	//=> {"@timestamp":"...", "log.level":"error", "message":"charge failed", ..., "error.message":"card declined", "api":{"order":42}}
+type ECSFormatter struct {
+	name      string
+	namespace string
+	jf        *JSONFormatter
+}
+
+// NewECSFormatter creates a formatter for the logger name.
+func NewECSFormatter(name string) *ECSFormatter {
+	return &ECSFormatter{name: name, namespace: DefaultECSNamespace, jf: NewJSONFormatter(name)}
+}
+
+// SetNamespace sets the field key-value pairs are nested under, eg the
+// name of your organization as recommended for custom fields.
+func (ef *ECSFormatter) SetNamespace(namespace string) {
+	ef.namespace = namespace
+}
+
+// Format writes a document without the caller and call stack.
+func (ef *ECSFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	ef.format(writer, time.Now(), level, msg, args, nil, nil)
+}
+
+// FormatEntry writes a document with the caller and call stack, if
+// captured.
+func (ef *ECSFormatter) FormatEntry(writer io.Writer, entry *Entry) {
+	ef.format(writer, entry.Time, entry.Level, entry.Msg, entry.Fields, entry.Caller, entry.Stack)
+}
+
+func (ef *ECSFormatter) format(writer io.Writer, t time.Time, level int, msg string, args []interface{}, caller *Frame, stack []Frame) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+	jf := ef.jf
+
+	buf.WriteString(`{"@timestamp":"`)
+	buf.WriteString(t.UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	buf.WriteString(`", "log.level":"`)
+	if label, ok := ecsLevels[level]; ok {
+		buf.WriteString(label)
+	} else {
+		buf.WriteString(strconv.Itoa(level))
+	}
+	buf.WriteString(`", "message":`)
+	jf.writeString(buf, stripANSI(msg))
+	buf.WriteString(`, "ecs.version":"` + ECSVersion + `", "log.logger":`)
+	jf.writeString(buf, ef.name)
+	buf.WriteString(`, "process.pid":`)
+	buf.WriteString(pidStr)
+	if caller != nil {
+		buf.WriteString(`, "log.origin.file.name":`)
+		jf.writeString(buf, caller.File)
+		buf.WriteString(`, "log.origin.file.line":`)
+		buf.WriteString(strconv.Itoa(caller.Line))
+		buf.WriteString(`, "log.origin.function":`)
+		jf.writeString(buf, caller.Function)
+	}
+
+	if len(args) == 1 {
+		args = []interface{}{singleArgKey, args[0]}
+	}
+	if len(args)%2 != 0 {
+		args = []interface{}{warnImbalancedKey, fmt.Sprint(args)}
+	}
+	errIndex := -1
+	for i := 1; i < len(args); i += 2 {
+		if err, ok := args[i].(error); ok {
+			errIndex = i
+			buf.WriteString(`, "error.message":`)
+			jf.writeString(buf, stripANSI(err.Error()))
+			buf.WriteString(`, "error.type":`)
+			jf.writeString(buf, fmt.Sprintf("%T", err))
+			if len(stack) > 0 {
+				buf.WriteString(`, "error.stack_trace":`)
+				jf.writeString(buf, stackTrace(stack))
+			}
+			break
+		}
+	}
+
+	if len(args) > 2 || len(args) == 2 && errIndex < 0 {
+		buf.WriteString(`, `)
+		jf.writeString(buf, ef.namespace)
+		buf.WriteString(`:{`)
+		first := true
+		for i := 0; i < len(args); i += 2 {
+			if i+1 == errIndex {
+				continue
+			}
+			if !first {
+				buf.WriteString(", ")
+			}
+			first = false
+			key, ok := args[i].(string)
+			if !ok || key == "" {
+				key = badKeyAtIndex(i)
+			}
+			jf.writeString(buf, key)
+			buf.WriteRune(':')
+			if err, ok := args[i+1].(error); ok {
+				// only the first error has error.* fields
+				jf.writeString(buf, stripANSI(err.Error()))
+			} else {
+				jf.appendValue(buf, args[i+1])
+			}
+		}
+		buf.WriteRune('}')
+	}
+	buf.WriteString("}\n")
+	buf.WriteTo(writer)
+}
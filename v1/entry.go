@@ -0,0 +1,135 @@
+package log
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a log entry passed to formatters which implement
+// EntryFormatter. Entries are pooled, formatters must not retain them
+// after FormatEntry returns.
+type Entry struct {
+	Time  time.Time
+	Level int
+	Name  string
+	Msg   string
+	// Fields are the key-value pairs of the entry, including fields bound
+	// with With
+	Fields []interface{}
+	// Caller is the frame which logged the entry. By default it is set for
+	// traces, warnings and more severe entries, see the caller option of
+	// LOGXI_FORMAT.
+	Caller *Frame
+	// Stack is the call stack, excluding logxi frames. By default it is set
+	// for errors, more severe entries and warnings logging an error, see the
+	// stack option of LOGXI_FORMAT.
+	Stack []Frame
+
+	caller Frame
+}
+
+// EntryFormatter is implemented by formatters which format an Entry
+// instead of separate arguments. Loggers call FormatEntry instead of Format
+// when their formatter implements it.
+type EntryFormatter interface {
+	Formatter
+	FormatEntry(writer io.Writer, entry *Entry)
+}
+
+var entryPool = sync.Pool{
+	New: func() interface{} {
+		return &Entry{}
+	},
+}
+
+// stackOptions tunes the stacks captured for a logger's entries
+type stackOptions struct {
+	// skip is the number of frames to skip above the logxi frames, eg those
+	// of a logging wrapper
+	skip int
+	// depth is the maximum number of frames, DefaultStackDepth if 0
+	depth int
+}
+
+// newEntry gets an entry from the pool, capturing the caller and stack as
+// required by level. Call release when done.
+func newEntry(level int, name string, msg string, args []interface{}, opts stackOptions) *Entry {
+	e := entryPool.Get().(*Entry)
+	e.Time = time.Now()
+	e.Level = level
+	e.Name = name
+	e.Msg = msg
+	e.Fields = args
+	e.Caller = nil
+	e.Stack = e.Stack[:0]
+
+	if capturesStack(level, args) {
+		frames := captureFrames(opts.skip, opts.depth)
+		for _, frame := range frames {
+			e.Stack = append(e.Stack, Frame{Function: frame.method, File: frame.filename, Line: frame.lineno})
+		}
+		if len(frames) > 0 {
+			e.caller = e.Stack[0]
+			e.Caller = &e.caller
+		}
+	} else if capturesCaller(level) {
+		if frames := captureFrames(opts.skip, 1); len(frames) > 0 {
+			e.caller = Frame{Function: frames[0].method, File: frames[0].filename, Line: frames[0].lineno}
+			e.Caller = &e.caller
+		}
+	}
+	return e
+}
+
+func hasError(args []interface{}) bool {
+	for _, arg := range args {
+		if _, ok := arg.(error); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) release() {
+	e.Fields = nil
+	entryPool.Put(e)
+}
+
+// formatEntry formats an entry with formatter, preferring FormatEntry.
+func formatEntry(formatter Formatter, writer io.Writer, level int, name string, msg string, args []interface{}, opts stackOptions) {
+	ef, ok := formatter.(EntryFormatter)
+	if !ok {
+		formatNamed(formatter, writer, level, name, msg, args)
+		return
+	}
+	e := newEntry(level, name, msg, args, opts)
+	ef.FormatEntry(writer, e)
+	e.release()
+}
+
+// stackTrace formats frames like a Go stack trace, a function per line
+// followed by its indented file and line.
+func stackTrace(frames []Frame) string {
+	lines := make([]string, len(frames))
+	for i, frame := range frames {
+		lines[i] = frame.Function + "\n\t" + frame.File + ":" + strconv.Itoa(frame.Line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sourceFrames converts frames for printing with source context. Frames
+// after the first runtime frame, eg runtime.goexit, are dropped when
+// ignoreRuntime is set.
+func sourceFrames(frames []Frame, ignoreRuntime bool) []*frameInfo {
+	result := make([]*frameInfo, 0, len(frames))
+	for _, frame := range frames {
+		if ignoreRuntime && strings.Contains(frame.File, runtimeSrc) {
+			break
+		}
+		result = append(result, &frameInfo{filename: frame.File, lineno: frame.Line, method: frame.Function})
+	}
+	return result
+}
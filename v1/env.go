@@ -6,21 +6,28 @@ import (
 	"strings"
 )
 
-var contextLines int
-
 // Configuration comes from environment or external services like
 // consul, etcd.
 type Configuration struct {
 	Format string `json:"format"`
 	Colors string `json:"colors"`
 	Levels string `json:"levels"`
+	// Show selects the fields HappyDevFormatter prints, see LOGXI_SHOW
+	Show string `json:"show,omitempty"`
 }
 
 func readFromEnviron() *Configuration {
 	conf := &Configuration{}
 
 	var envOrDefault = func(name, val string) string {
-		result := os.Getenv(name)
+		var result string
+		if envNamespace != "" {
+			// a vendored copy may be configured separately
+			result = os.Getenv(envNamespace + "_" + name)
+		}
+		if result == "" {
+			result = os.Getenv(name)
+		}
 		if result == "" {
 			result = val
 		}
@@ -30,6 +37,7 @@ func readFromEnviron() *Configuration {
 	conf.Levels = envOrDefault("LOGXI", defaultLogxiEnv)
 	conf.Format = envOrDefault("LOGXI_FORMAT", defaultLogxiFormatEnv)
 	conf.Colors = envOrDefault("LOGXI_COLORS", defaultLogxiColorsEnv)
+	conf.Show = envOrDefault("LOGXI_SHOW", "")
 	return conf
 }
 
@@ -37,17 +45,52 @@ func readFromEnviron() *Configuration {
 func ProcessEnv(env *Configuration) {
 	// TODO: allow reading from etcd
 
-	ProcessLogxiEnv(env.Levels)
-	ProcessLogxiColorsEnv(env.Colors)
-	ProcessLogxiFormatEnv(env.Format)
+	// apply the variables as one update so entries logged meanwhile never
+	// see a mix of the previous and new settings
+	var before Configuration
+	levels := parseLogxiEnv(env.Levels)
+	updateSettings(func(s *settings) {
+		before = s.config
+		s.config = *env
+		s.logxiNameLevelMap = levels
+		s.applyColorsEnv(env.Colors)
+		s.applyFormatEnv(env.Format)
+		s.applyShowEnv(env.Show)
+	})
+	clearFormatterCache()
+	if InternalLog != nil {
+		InternalLog.SetLevel(internalLogLevel())
+	}
+	auditConfig(before, *env, 1)
 }
 
 // ProcessLogxiFormatEnv parses LOGXI_FORMAT
 func ProcessLogxiFormatEnv(env string) {
-	logxiFormat = env
-	m := parseKVList(logxiFormat, ",")
+	updateSettings(func(s *settings) { s.applyFormatEnv(env) })
+}
+
+// applyFormatEnv applies LOGXI_FORMAT to s
+func (s *settings) applyFormatEnv(env string) {
+	m := parseKVList(env, ",")
 	formatterFormat := ""
 	tFormat := ""
+	s.expandValues = false
+	s.shortPaths = false
+	s.maxDepth = defaultMaxDepth
+	s.maxSize = defaultMaxSize
+	s.nameWidth = 0
+	s.showUptime = false
+	s.showSchema = false
+	s.datadogMode = false
+	s.firstSeenLevel = 0
+	s.lintMode = false
+	s.isLogfmt = false
+	s.callerLevel = LevelWarn
+	s.stackLevel = LevelError
+	s.logCaller = false
+	s.floatFormat = DefaultFloatFormat
+	s.assignmentChar = ""
+	s.separator = ""
 	for key, value := range m {
 		switch key {
 		default:
@@ -55,86 +98,230 @@ func ProcessLogxiFormatEnv(env string) {
 		case "t":
 			tFormat = value
 		case "pretty":
-			isPretty = value != "false" && value != "0"
+			s.isPretty = value != "false" && value != "0"
+		case "shortpaths":
+			s.shortPaths = value != "false" && value != "0"
+		case "uptime":
+			s.showUptime = value != "false" && value != "0"
+		case "schema":
+			s.showSchema = value != "false" && value != "0"
+		case "datadog":
+			s.datadogMode = value != "false" && value != "0"
+		case "lint":
+			s.lintMode = value != "false" && value != "0"
+		case "caller":
+			if level, ok := ParseLevel(value); ok {
+				s.callerLevel = level
+				s.logCaller = true
+			} else {
+				unknownLevel("LOGXI_FORMAT", key, value, env)
+			}
+		case "firstseen":
+			if value == "" {
+				s.firstSeenLevel = LevelWarn
+			} else if level, ok := ParseLevel(value); ok {
+				s.firstSeenLevel = level
+			} else {
+				unknownLevel("LOGXI_FORMAT", key, value, env)
+			}
+		case "stack":
+			if level, ok := ParseLevel(value); ok {
+				s.stackLevel = level
+			} else {
+				unknownLevel("LOGXI_FORMAT", key, value, env)
+			}
+		case "precision":
+			precision, err := strconv.Atoi(value)
+			if err == nil {
+				s.floatFormat.Precision = precision
+			}
+		case "fixed":
+			s.floatFormat.Fixed = value != "false" && value != "0"
+		case "nan":
+			s.floatFormat.NaNString = value == "string"
+		case "expand":
+			s.expandValues = value != "false" && value != "0"
+		case "maxdepth":
+			depth, err := strconv.Atoi(value)
+			if err == nil {
+				s.maxDepth = depth
+			}
+		case "maxsize":
+			size, err := strconv.Atoi(value)
+			if err == nil {
+				s.maxSize = size
+			}
+		case "namewidth":
+			width, err := strconv.Atoi(value)
+			if err == nil {
+				s.nameWidth = width
+			}
 		case "maxcol":
 			col, err := strconv.Atoi(value)
 			if err == nil {
-				maxCol = col
+				s.maxCol = col
 			} else {
-				maxCol = defaultMaxCol
+				s.maxCol = defaultMaxCol
 			}
 		case "context":
 			lines, err := strconv.Atoi(value)
 			if err == nil {
-				contextLines = lines
+				s.contextLines = lines
 			} else {
-				contextLines = defaultContextLines
+				s.contextLines = defaultContextLines
 			}
+		case "json":
+			formatterFormat = FormatJSON
 		case "LTSV":
 			formatterFormat = "text"
-			AssignmentChar = ltsvAssignmentChar
-			Separator = ltsvSeparator
+			s.assignmentChar = ltsvAssignmentChar
+			s.separator = ltsvSeparator
+		case "logfmt":
+			formatterFormat = "text"
+			s.assignmentChar = logfmtAssignmentChar
+			s.separator = logfmtSeparator
+			s.isLogfmt = true
 		}
 	}
+	s.requestedFormat = formatterFormat
 	if formatterFormat == "" || formatterCreators[formatterFormat] == nil {
 		formatterFormat = defaultFormat
 	}
-	logxiFormat = formatterFormat
+	s.logxiFormat = formatterFormat
 	if tFormat == "" {
 		tFormat = defaultTimeFormat
 	}
-	timeFormat = tFormat
+	s.timeFormat = tFormat
 }
 
 // ProcessLogxiEnv parses LOGXI variable
 func ProcessLogxiEnv(env string) {
+	levels := parseLogxiEnv(env)
+	updateSettings(func(s *settings) { s.logxiNameLevelMap = levels })
+}
+
+// parseLogxiEnv parses a LOGXI value into a map of name patterns to levels
+func parseLogxiEnv(env string) map[string]int {
 	logxiEnable := env
 	if logxiEnable == "" {
 		logxiEnable = defaultLogxiEnv
 	}
 
-	logxiNameLevelMap = map[string]int{}
+	nameLevelMap := map[string]int{}
 	m := parseKVList(logxiEnable, ",")
 	if m == nil {
-		logxiNameLevelMap["*"] = defaultLevel
+		nameLevelMap["*"] = defaultLevel
 	}
 	for key, value := range m {
 		if strings.HasPrefix(key, "-") {
 			// LOGXI=*,-foo => disable foo
-			logxiNameLevelMap[key[1:]] = LevelOff
+			nameLevelMap[key[1:]] = LevelOff
 		} else if value == "" {
 			// LOGXI=* => default to all
-			logxiNameLevelMap[key] = LevelAll
+			nameLevelMap[key] = LevelAll
 		} else {
 			// LOGXI=*=ERR => use user-specified level
-			level := LevelAtoi[value]
-			if level == 0 {
-				InternalLog.Error("Unknown level in LOGXI environment variable", "key", key, "value", value, "LOGXI", env)
+			level, ok := ParseLevel(value)
+			if !ok {
+				unknownLevel("LOGXI", key, value, env)
 				level = defaultLevel
 			}
-			logxiNameLevelMap[key] = level
+			nameLevelMap[key] = level
 		}
 	}
 
 	// must always have global default, otherwise errs may get eaten up
-	if _, ok := logxiNameLevelMap["*"]; !ok {
-		logxiNameLevelMap["*"] = LevelError
+	if _, ok := nameLevelMap["*"]; !ok {
+		nameLevelMap["*"] = LevelError
 	}
+	return nameLevelMap
+}
+
+// unknownLevel reports an unknown level in an environment variable.
+func unknownLevel(variable, key, value, env string) {
+	if InternalLog == nil {
+		return
+	}
+	InternalLog.Error("Unknown level in "+variable+" environment variable", "key", key, "value", value, variable, env)
+}
+
+// MatchName determines if a logger name matches a LOGXI pattern, eg
+// "api*". Tools filtering entries by name use it to match like LOGXI.
+func MatchName(pattern, name string) bool {
+	return matchName(pattern, name)
+}
+
+// matchName determines if a logger name matches a LOGXI pattern. Patterns
+// are globs where "*" matches any run of characters and "?" matches a
+// single character, eg "worker-??" or "api.*.db".
+func matchName(pattern, name string) bool {
+	if pattern == "*" || pattern == name {
+		return true
+	}
+	// backtrack to the last star on a mismatch
+	p, n := 0, 0
+	star, starN := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, starN = p, n
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case star >= 0:
+			starN++
+			p, n = star+1, starN
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// isGlob determines if a LOGXI pattern contains wildcards.
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
+
+// literals is the number of characters of a pattern which aren't
+// wildcards. Patterns with more literals are more specific.
+func literals(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
 }
 
 func getLogLevel(name string) int {
+	return levelFromMap(cfg().logxiNameLevelMap, name)
+}
+
+// levelFromMap returns the level of the most specific pattern matching
+// name. An exact name is the most specific, then the pattern with the most
+// literal characters. Exclusions, eg "-noisy*", win ties, then the more
+// verbose level.
+func levelFromMap(nameLevelMap map[string]int, name string) int {
 	var wildcardLevel int
 	var result int
 
-	for k, v := range logxiNameLevelMap {
-		if k == name {
-			result = v
-		} else if k == "*" {
-			wildcardLevel = v
-		} else if strings.HasPrefix(k, "*") && strings.HasSuffix(name, k[1:]) {
-			result = v
-		} else if strings.HasSuffix(k, "*") && strings.HasPrefix(name, k[:len(k)-1]) {
-			result = v
+	if v, ok := nameLevelMap[name]; ok {
+		result = v
+	} else {
+		best := -1
+		for k, v := range nameLevelMap {
+			if k == "*" {
+				wildcardLevel = v
+				continue
+			}
+			if !isGlob(k) || !matchName(k, name) {
+				continue
+			}
+			n := literals(k)
+			if n > best || (n == best && result != LevelOff && (v == LevelOff || v > result)) {
+				best = n
+				result = v
+			}
 		}
 	}
 
@@ -155,12 +342,17 @@ func getLogLevel(name string) int {
 
 // ProcessLogxiColorsEnv parases LOGXI_COLORS
 func ProcessLogxiColorsEnv(env string) {
+	updateSettings(func(s *settings) { s.applyColorsEnv(env) })
+}
+
+// applyColorsEnv applies LOGXI_COLORS to s
+func (s *settings) applyColorsEnv(env string) {
 	colors := env
 	if colors == "" {
 		colors = defaultLogxiColorsEnv
 	} else if colors == "*=off" {
 		// disable all colors
-		disableColors = true
+		s.disableColors = true
 	}
-	theme = parseTheme(colors)
+	s.theme = parseThemeColors(colors, s.disableColors)
 }
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// BootID is a random identifier generated once per process. It is attached
+// to every entry so entries from multiple restarts of the same service can be
+// told apart when reconstructing a timeline.
+var BootID = newBootID()
+
+var startTime = time.Now()
+
+// uptime returns the seconds since the process started. time.Since uses the
+// monotonic clock so it is not affected by NTP adjustments.
+func uptime() string {
+	return strconv.FormatFloat(time.Since(startTime).Seconds(), 'f', 6, 64)
+}
+
+func newBootID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// fall back to something unique enough for a single host
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Epoch logs a "logging started" entry to DefaultLog describing this process:
+// build info, configuration summary, pid and BootID. It is logged once at
+// startup and may be called again on demand, for example after a
+// configuration reload. The entry is logged at INF whatever the level of
+// DefaultLog so restarts show in a timeline with the default levels. It is
+// not logged when DefaultLog, named "~", is off, eg LOGXI=~=OFF, or logxi is
+// silenced.
+func Epoch() {
+	const msg = "logging started"
+	l, ok := DefaultLog.(*DefaultLogger)
+	if !ok || l.IsInfo() || adoptedBy() != nil {
+		DefaultLog.Info(msg, epochArgs()...)
+		return
+	}
+	if l.getLevel() == LevelOff || silent || quietMode {
+		return
+	}
+	formatEntry(l.getFormatter(), l.entryWriter(LevelInfo), LevelInfo, l.name, msg, epochArgs(), l.stack)
+}
+
+func epochArgs() []interface{} {
+	conf := cfg()
+	args := []interface{}{
+		"boot", BootID,
+		"pid", pid,
+		"started", startTime.Format(time.RFC3339),
+		"logxi", Version,
+		"go", runtime.Version(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		args = append(args, "main", info.Main.Path, "mainVersion", info.Main.Version)
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				args = append(args, "revision", setting.Value)
+			}
+		}
+	}
+	args = append(args,
+		"LOGXI", conf.config.Levels,
+		"LOGXI_FORMAT", conf.config.Format,
+		"LOGXI_COLORS", conf.config.Colors,
+	)
+	return args
+}
@@ -0,0 +1,73 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrChainSuffix is appended to the key of a wrapped error to name the field
+// summarizing its chain, eg "err_chain".
+var ErrChainSuffix = "_chain"
+
+// errChain summarizes the unwrap chain of err, eg
+// "api: timeout: context deadline exceeded". Messages which may contain
+// per-request specifics are replaced by the type of the error so the summary
+// is stable. It returns "" if err does not wrap an error.
+func errChain(err error) string {
+	if errors.Unwrap(err) == nil {
+		return ""
+	}
+	var links []string
+	for err != nil {
+		inner := errors.Unwrap(err)
+		links = append(links, chainLink(err, inner))
+		err = inner
+	}
+	return strings.Join(links, ": ")
+}
+
+// chainLink describes a single error of a chain. Messages of errors created
+// with errors.New and fmt.Errorf are kept without the wrapped message, as are
+// messages of errors without data such as context.DeadlineExceeded. Other
+// errors are described by their type.
+func chainLink(err error, inner error) string {
+	switch fmt.Sprintf("%T", err) {
+	case "*errors.errorString":
+		return err.Error()
+	case "*fmt.wrapError":
+		msg := err.Error()
+		if inner == nil {
+			return msg
+		}
+		if own := strings.TrimSuffix(msg, ": "+inner.Error()); own != msg {
+			return own
+		}
+		return msg
+	}
+	if t := reflect.TypeOf(err); t.Kind() != reflect.Ptr && t.Size() == 0 {
+		return err.Error()
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// annotateErrChain appends a chain summary for each wrapped error in args.
+func annotateErrChain(args []interface{}) []interface{} {
+	var chains []interface{}
+	for i := 0; i+1 < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			continue
+		}
+		if err, ok := args[i+1].(error); ok {
+			if chain := errChain(err); chain != "" {
+				chains = append(chains, key+ErrChainSuffix, chain)
+			}
+		}
+	}
+	if chains == nil {
+		return args
+	}
+	return appendArgs(args, chains...)
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EscalatedKey is the key of the original level label added to entries
+// escalated by an EscalationRule.
+var EscalatedKey = "escalated_from"
+
+// EscalationRule raises the level of matching entries, eg a warning logged
+// too often becomes an error, so chronic warnings eventually page someone.
+type EscalationRule struct {
+	// Name is a logger name pattern as used in LOGXI, eg "api*"
+	Name string
+	// Level matches entries of this level, eg LevelWarn
+	Level int
+	// Key optionally matches entries having this key. If Value is not empty
+	// the value must also match.
+	Key   string
+	Value string
+	// Count escalates once the same message of a logger is matched more
+	// than Count times within Window. Zero escalates every match.
+	Count  int
+	Window time.Duration
+	// To is the escalated level, eg LevelError
+	To int
+}
+
+// escalation is a rule with the message counts of its current windows
+type escalation struct {
+	rule EscalationRule
+
+	mu      sync.Mutex
+	windows map[uint64]*escalationWindow
+}
+
+type escalationWindow struct {
+	start time.Time
+	count int
+}
+
+// count counts a matched message and reports whether it exceeds the rule's
+// count.
+func (e *escalation) count(fp uint64, now time.Time) bool {
+	if e.rule.Count == 0 {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w := e.windows[fp]
+	if w == nil || now.Sub(w.start) > e.rule.Window {
+		if w == nil && len(e.windows) >= MaxSeenMessages {
+			e.windows = map[uint64]*escalationWindow{}
+		}
+		w = &escalationWindow{start: now}
+		e.windows[fp] = w
+	}
+	w.count++
+	return w.count > e.rule.Count
+}
+
+var escalationMutex sync.Mutex
+
+// escalations holds []*escalation
+var escalations atomic.Value
+
+// AddEscalationRule adds a rule which escalates the level of matching
+// entries. Escalated entries are logged by loggers which would have
+// discarded them at their original level and are annotated with
+// EscalatedKey.
+//
+// Example
+//
+//	// a warning logged more than 10 times a minute by the same logger is an error
+//	log.AddEscalationRule(log.EscalationRule{Name: "*", Level: log.LevelWarn, Count: 10, Window: time.Minute, To: log.LevelError})
+func AddEscalationRule(rule EscalationRule) {
+	escalationMutex.Lock()
+	defer escalationMutex.Unlock()
+	current, _ := escalations.Load().([]*escalation)
+	updated := make([]*escalation, len(current), len(current)+1)
+	copy(updated, current)
+	escalations.Store(append(updated, &escalation{rule: rule, windows: map[uint64]*escalationWindow{}}))
+}
+
+// ClearEscalationRules removes all escalation rules.
+func ClearEscalationRules() {
+	escalationMutex.Lock()
+	defer escalationMutex.Unlock()
+	escalations.Store([]*escalation(nil))
+}
+
+// hasEscalationRules reports whether entries may be escalated, in which
+// case entries can't be discarded before they are matched.
+func hasEscalationRules() bool {
+	current, _ := escalations.Load().([]*escalation)
+	return len(current) > 0
+}
+
+// escalate returns the escalated level of an entry and its args annotated
+// with the original level. The first rule which escalates wins.
+func escalate(name string, level int, msg string, args []interface{}) (int, []interface{}) {
+	current, _ := escalations.Load().([]*escalation)
+	if len(current) == 0 {
+		return level, args
+	}
+	now := time.Now()
+	var fp uint64
+	for _, e := range current {
+		r := &e.rule
+		if level != r.Level || !matchName(r.Name, name) || !matchesField(r.Key, r.Value, args) {
+			continue
+		}
+		if fp == 0 {
+			fp = messageFingerprint(name, msg)
+		}
+		if e.count(fp, now) {
+			return r.To, appendArgs(args, EscalatedKey, LevelMap[level])
+		}
+	}
+	return level, args
+}
+
+// matchesField reports whether args have key, with value if not empty. An
+// empty key matches any args.
+func matchesField(key string, value string, args []interface{}) bool {
+	if key == "" {
+		return true
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		if k, ok := args[i].(string); ok && k == key {
+			return value == "" || fmt.Sprint(args[i+1]) == value
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package log
+
+// Windows event log types
+const (
+	eventLogError       = 1
+	eventLogWarning     = 2
+	eventLogInformation = 4
+)
+
+// eventLogType maps a logxi level to an event log type. Errors and more
+// severe entries are errors, warnings are warnings and all others are
+// information.
+func eventLogType(level int) uint16 {
+	switch {
+	case level <= LevelError:
+		return eventLogError
+	case level == LevelWarn:
+		return eventLogWarning
+	}
+	return eventLogInformation
+}
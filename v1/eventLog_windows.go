@@ -0,0 +1,106 @@
+package log
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+)
+
+// eventLogID is the event ID of entries. It is the ID EventCreate.exe's
+// message file formats as the entry text.
+const eventLogID = 1
+
+// EventLogSink is a concurrent safe writer reporting entries to the Windows
+// event log, eg for services. Loggers pass the level of entries, errors and
+// more severe entries are reported as errors, warnings as warnings and all
+// others as information. Writes without a level are information.
+//
+// Register the source once, eg when installing the service, or Event
+// Viewer can't display the text of entries.
+//
+// Example
+//
+//	// PowerShell, as administrator:
+//	//   New-EventLog -LogName Application -Source api -MessageResourceFile %SystemRoot%\System32\EventCreate.exe
+//	sink, err := log.NewEventLogSink("api")
+//	if err != nil {
+//		panic(err)
+//	}
+//	logger := log.NewLogger3(sink, "api", log.NewTextFormatter("api"))
+type EventLogSink struct {
+	mu     sync.Mutex
+	handle syscall.Handle
+}
+
+// NewEventLogSink opens the event log of source.
+func NewEventLogSink(source string) (*EventLogSink, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	h, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, err
+	}
+	return &EventLogSink{handle: syscall.Handle(h)}, nil
+}
+
+func (es *EventLogSink) Write(p []byte) (int, error) {
+	return es.WriteLevel(LevelInfo, p)
+}
+
+// WriteLevel reports an entry with the event type of level.
+func (es *EventLogSink) WriteLevel(level int, p []byte) (int, error) {
+	msg := p
+	if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[:len(msg)-1]
+	}
+	text, err := syscall.UTF16PtrFromString(string(msg))
+	if err != nil {
+		return 0, err
+	}
+	strs := []*uint16{text}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.handle == 0 {
+		return 0, ErrWriterClosed
+	}
+	ok, _, err := procReportEventW.Call(
+		uintptr(es.handle),
+		uintptr(eventLogType(level)),
+		0,
+		eventLogID,
+		0,
+		uintptr(len(strs)),
+		0,
+		uintptr(unsafe.Pointer(&strs[0])),
+		0,
+	)
+	if ok == 0 {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the event log.
+func (es *EventLogSink) Close() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.handle == 0 {
+		return nil
+	}
+	ok, _, err := procDeregisterEventSource.Call(uintptr(es.handle))
+	es.handle = 0
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExpandEnv replaces ${VAR} and ${VAR:-default} in s with environment
+// variables, eg in DSNs like kafka://${LOG_BROKER}/logs. Only the braced
+// form is expanded so values containing $, like passwords, are kept. In
+// strict mode a missing variable without a default is an error, otherwise
+// it expands to "".
+func ExpandEnv(s string, strict bool) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var missing []string
+	var buf strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		buf.WriteString(s[:start])
+
+		name := s[start+2 : end]
+		def, hasDefault := "", false
+		if i := strings.Index(name, ":-"); i >= 0 {
+			name, def, hasDefault = name[:i], name[i+2:], true
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok || (hasDefault && value == "") {
+			if !hasDefault && strict {
+				missing = append(missing, name)
+			}
+			value = def
+		}
+		buf.WriteString(value)
+		s = s[end+1:]
+	}
+	buf.WriteString(s)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("logxi: undefined environment variables: %s", strings.Join(missing, ", "))
+	}
+	return buf.String(), nil
+}
+
+// Expand expands environment variables in every value of the configuration,
+// see ExpandEnv.
+func (c *Configuration) Expand(strict bool) error {
+	var err error
+	for _, value := range []*string{&c.Format, &c.Colors, &c.Levels, &c.Show} {
+		if *value, err = ExpandEnv(*value, strict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
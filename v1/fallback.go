@@ -0,0 +1,74 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Degradation records a sink or formatter which could not be created and
+// was replaced by the stderr fallback.
+type Degradation struct {
+	Sink string    `json:"sink"`
+	Err  string    `json:"err"`
+	Time time.Time `json:"time"`
+}
+
+// degradations holds the last degradation of each sink until it recovers
+var degradations = struct {
+	sync.Mutex
+	sinks map[string]Degradation
+}{sinks: map[string]Degradation{}}
+
+// stderrFallback is the concurrent safe writer used when configured sinks
+// can't be created
+var stderrFallback = NewConcurrentWriter(os.Stderr)
+
+// Degraded returns the sinks which fell back to stderr; empty if logging is
+// healthy.
+func Degraded() []Degradation {
+	degradations.Lock()
+	result := make([]Degradation, 0, len(degradations.sinks))
+	for _, d := range degradations.sinks {
+		result = append(result, d)
+	}
+	degradations.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result
+}
+
+// IsDegraded determines if any sink fell back to stderr.
+func IsDegraded() bool {
+	degradations.Lock()
+	defer degradations.Unlock()
+	return len(degradations.sinks) > 0
+}
+
+// recovered clears the degradation of sink once it could be created.
+func recovered(sink string) {
+	degradations.Lock()
+	delete(degradations.sinks, sink)
+	degradations.Unlock()
+}
+
+// fallback records that sink could not be created and logs the error as
+// JSON to stderr. It returns the stderr writer which replaces sink.
+func fallback(sink string, err error) io.Writer {
+	degradations.Lock()
+	degradations.sinks[sink] = Degradation{Sink: sink, Err: err.Error(), Time: time.Now()}
+	degradations.Unlock()
+
+	// not registered so InternalLog stays registered as __logxi
+	l := &DefaultLogger{
+		name:  "__logxi",
+		level: LevelError,
+	}
+	l.setWriter(stderrFallback)
+	l.SetFormatter(NewJSONFormatter("__logxi"))
+	l.Error("Could not create log sink, logging to stderr instead", "sink", sink, "err", err)
+	return stderrFallback
+}
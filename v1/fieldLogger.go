@@ -0,0 +1,61 @@
+package log
+
+// fieldLogger adds key-value pairs to every entry of the logger it wraps.
+type fieldLogger struct {
+	Logger
+	args []interface{}
+}
+
+func newFieldLogger(logger Logger, args []interface{}) Logger {
+	if fl, ok := logger.(*fieldLogger); ok {
+		return &fieldLogger{Logger: fl.Logger, args: fl.merge(args)}
+	}
+	return &fieldLogger{Logger: logger, args: append([]interface{}(nil), args...)}
+}
+
+// merge returns the logger's args followed by args.
+func (fl *fieldLogger) merge(args []interface{}) []interface{} {
+	merged := make([]interface{}, 0, len(fl.args)+len(args))
+	merged = append(merged, fl.args...)
+	return append(merged, args...)
+}
+
+// Name returns the name of the wrapped logger.
+func (fl *fieldLogger) Name() string {
+	return loggerName(fl.Logger)
+}
+
+// Trace logs a trace entry.
+func (fl *fieldLogger) Trace(msg string, args ...interface{}) {
+	fl.Logger.Trace(msg, fl.merge(args)...)
+}
+
+// Debug logs a debug entry.
+func (fl *fieldLogger) Debug(msg string, args ...interface{}) {
+	fl.Logger.Debug(msg, fl.merge(args)...)
+}
+
+// Info logs an info entry.
+func (fl *fieldLogger) Info(msg string, args ...interface{}) {
+	fl.Logger.Info(msg, fl.merge(args)...)
+}
+
+// Warn logs a warn entry.
+func (fl *fieldLogger) Warn(msg string, args ...interface{}) error {
+	return fl.Logger.Warn(msg, fl.merge(args)...)
+}
+
+// Error logs an error entry.
+func (fl *fieldLogger) Error(msg string, args ...interface{}) error {
+	return fl.Logger.Error(msg, fl.merge(args)...)
+}
+
+// Fatal logs a fatal entry then panics.
+func (fl *fieldLogger) Fatal(msg string, args ...interface{}) {
+	fl.Logger.Fatal(msg, fl.merge(args)...)
+}
+
+// Log logs a leveled entry.
+func (fl *fieldLogger) Log(level int, msg string, args []interface{}) {
+	fl.Logger.Log(level, msg, fl.merge(args))
+}
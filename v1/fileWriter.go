@@ -0,0 +1,271 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Partition determines the directories a FileWriter writes to.
+type Partition int
+
+const (
+	// PartitionNone writes to the file as is
+	PartitionNone Partition = iota
+	// PartitionDay writes to dir/2006/01/02/name
+	PartitionDay
+	// PartitionWeek writes to dir/2006/W01/name using ISO 8601 weeks
+	PartitionWeek
+)
+
+// ParsePartition parses "none", "day" or "week".
+func ParsePartition(s string) (Partition, error) {
+	switch s {
+	case "", "none":
+		return PartitionNone, nil
+	case "day":
+		return PartitionDay, nil
+	case "week":
+		return PartitionWeek, nil
+	}
+	return PartitionNone, fmt.Errorf("logxi: partition must be none, day or week, got %q", s)
+}
+
+// dir returns the partition directory for t relative to the log directory.
+func (p Partition) dir(t time.Time) string {
+	switch p {
+	case PartitionDay:
+		return filepath.Join(t.Format("2006"), t.Format("01"), t.Format("02"))
+	case PartitionWeek:
+		year, week := t.ISOWeek()
+		return filepath.Join(fmt.Sprintf("%04d", year), fmt.Sprintf("W%02d", week))
+	}
+	return ""
+}
+
+// SyncPolicy determines when a FileWriter commits entries to stable
+// storage, trading durability against throughput.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves syncing to the OS
+	SyncNever SyncPolicy = iota
+	// SyncInterval syncs at most an interval after an entry is written
+	SyncInterval
+	// SyncError syncs after every Error or Fatal entry
+	SyncError
+)
+
+// ParseSyncPolicy parses "never", "error" or an interval such as "1s".
+func ParseSyncPolicy(s string) (SyncPolicy, time.Duration, error) {
+	switch s {
+	case "", "never":
+		return SyncNever, 0, nil
+	case "error":
+		return SyncError, 0, nil
+	}
+	interval, err := time.ParseDuration(s)
+	if err != nil || interval <= 0 {
+		return SyncNever, 0, fmt.Errorf("logxi: sync must be never, error or an interval, got %q", s)
+	}
+	return SyncInterval, interval, nil
+}
+
+// FileWriter is a concurrent safe writer which appends entries to a file.
+// When partitioned, entries are written to a file in a directory for the
+// current day or ISO week, eg logs/2024/05/17/app.log, which is created
+// as needed. The file is switched on the first write in a new partition.
+//
+// Files are only ever appended to. Use SetSync for audit-grade durability.
+//
+// Example
+//
+//	fw, err := log.NewFileWriter("logs/app.log", log.PartitionDay)
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer fw.Close()
+//	logger := log.NewLogger(fw, "app")
+type FileWriter struct {
+	dir       string
+	name      string
+	partition Partition
+
+	mu     sync.Mutex
+	file   *os.File
+	path   string
+	health sinkHealth
+
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+	// syncTimer is set while an interval sync is pending
+	syncTimer *time.Timer
+}
+
+// NewFileWriter creates a writer appending to filename. The file for the
+// current partition is opened immediately so configuration errors are
+// reported up front.
+func NewFileWriter(filename string, partition Partition) (*FileWriter, error) {
+	fw := &FileWriter{
+		dir:       filepath.Dir(filename),
+		name:      filepath.Base(filename),
+		partition: partition,
+	}
+	if err := fw.open(time.Now()); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+// open opens the file for the partition of t, closing the previous one.
+// The lock must be held.
+func (fw *FileWriter) open(t time.Time) error {
+	path := filepath.Join(fw.dir, fw.partition.dir(t), fw.name)
+	if fw.file != nil && path == fw.path {
+		return nil
+	}
+	if fw.partition != PartitionNone {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if fw.file != nil {
+		if fw.syncPolicy != SyncNever {
+			fw.file.Sync()
+		}
+		fw.file.Close()
+	}
+	fw.file = file
+	fw.path = path
+	return nil
+}
+
+// SetSync sets when entries are synced. The interval is only used by
+// SyncInterval.
+func (fw *FileWriter) SetSync(policy SyncPolicy, interval time.Duration) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.syncPolicy = policy
+	fw.syncInterval = interval
+}
+
+func (fw *FileWriter) Write(p []byte) (int, error) {
+	return fw.WriteLevel(LevelInfo, p)
+}
+
+// WriteLevel writes an entry logged at level, syncing it if the sync
+// policy requires it.
+func (fw *FileWriter) WriteLevel(level int, p []byte) (int, error) {
+	n, err := fw.writeLevel(level, p)
+	fw.health.record(err)
+	return n, err
+}
+
+func (fw *FileWriter) writeLevel(level int, p []byte) (int, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.file == nil {
+		return 0, ErrWriterClosed
+	}
+	if fw.partition != PartitionNone {
+		if err := fw.open(time.Now()); err != nil {
+			return 0, err
+		}
+	}
+	n, err := fw.file.Write(p)
+	if err != nil {
+		return n, err
+	}
+	switch fw.syncPolicy {
+	case SyncError:
+		if level <= LevelError {
+			err = fw.file.Sync()
+		}
+	case SyncInterval:
+		if fw.syncTimer == nil {
+			fw.syncTimer = time.AfterFunc(fw.syncInterval, fw.syncPending)
+		}
+	}
+	return n, err
+}
+
+// syncPending syncs entries written since the interval started.
+func (fw *FileWriter) syncPending() {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.syncTimer = nil
+	if fw.file != nil {
+		fw.file.Sync()
+	}
+}
+
+// Health reports whether the file is open and the last error.
+func (fw *FileWriter) Health() []SinkStatus {
+	fw.mu.Lock()
+	open, path := fw.file != nil, fw.path
+	fw.mu.Unlock()
+	return []SinkStatus{fw.health.status("file "+path, open, 0, 0)}
+}
+
+// Path returns the path of the file being written.
+func (fw *FileWriter) Path() string {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.path
+}
+
+// Sync commits the file to stable storage.
+func (fw *FileWriter) Sync() error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.file == nil {
+		return nil
+	}
+	return fw.file.Sync()
+}
+
+// Reopen reopens the file at its path, eg after logrotate moved it. If the
+// file can't be opened, entries are still written to the old one.
+func (fw *FileWriter) Reopen() error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.file == nil {
+		// nothing to reopen
+		return nil
+	}
+	file, err := os.OpenFile(fw.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if fw.syncPolicy != SyncNever {
+		fw.file.Sync()
+	}
+	fw.file.Close()
+	fw.file = file
+	return nil
+}
+
+// Close syncs and closes the file. Later writes fail with ErrWriterClosed.
+func (fw *FileWriter) Close() error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.file == nil {
+		return nil
+	}
+	if fw.syncTimer != nil {
+		fw.syncTimer.Stop()
+		fw.syncTimer = nil
+	}
+	err := fw.file.Sync()
+	if cerr := fw.file.Close(); err == nil {
+		err = cerr
+	}
+	fw.file = nil
+	return err
+}
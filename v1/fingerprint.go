@@ -0,0 +1,41 @@
+package log
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FingerprintFrames is the number of application frames hashed into an
+// error's fingerprint.
+var FingerprintFrames = 5
+
+// Fingerprint returns a stable identifier for err logged from the current
+// call stack. It hashes the type of err and the functions of the top
+// application frames, ignoring the runtime and logxi itself, so recurrences
+// of the same error group together regardless of their message.
+//
+// Formatters attach the fingerprint of logged errors as KeyMap.Fingerprint.
+func Fingerprint(err interface{}) string {
+	return fingerprint(err, stackFrames(2, false))
+}
+
+func fingerprint(val interface{}, frames []*frameInfo) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%T", val)
+	n := 0
+	for _, frame := range frames {
+		if n >= FingerprintFrames {
+			break
+		}
+		if isLogxiCode(frame.filename) || strings.Contains(frame.filename, filepath.Join("src", "runtime")) {
+			continue
+		}
+		h.Write([]byte{'\n'})
+		h.Write([]byte(frame.method))
+		n++
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// FirstSeenKey is added as true to the first entry of a message, see the
+// firstseen option of LOGXI_FORMAT.
+const FirstSeenKey = "first_seen"
+
+// SeenKey is the number of times a message was logged, added to later
+// entries of the message.
+const SeenKey = "seen"
+
+// MaxSeenMessages bounds the number of distinct messages counted. Messages
+// first logged after the limit is reached are not annotated.
+var MaxSeenMessages = 10000
+
+var seenMessages = struct {
+	sync.Mutex
+	counts map[uint64]int
+}{counts: map[uint64]int{}}
+
+// messageFingerprint identifies a message of a logger. Messages are
+// expected to be constant, with variable data in key-value pairs.
+func messageFingerprint(name string, msg string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(msg))
+	return h.Sum64()
+}
+
+// annotateFirstSeen marks the first entry of a message in this process with
+// FirstSeenKey and counts later ones with SeenKey, so dashboards can tell
+// novel errors from known noise.
+func annotateFirstSeen(name string, level int, msg string, args []interface{}) []interface{} {
+	if cfg().firstSeenLevel == 0 || level > cfg().firstSeenLevel {
+		return args
+	}
+	fp := messageFingerprint(name, msg)
+	seenMessages.Lock()
+	count, ok := seenMessages.counts[fp]
+	if !ok && len(seenMessages.counts) >= MaxSeenMessages {
+		seenMessages.Unlock()
+		return args
+	}
+	count++
+	seenMessages.counts[fp] = count
+	seenMessages.Unlock()
+
+	if count == 1 {
+		return appendArgs(args, FirstSeenKey, true)
+	}
+	return appendArgs(args, SeenKey, count)
+}
+
+// resetSeenMessages forgets the messages counted so far.
+func resetSeenMessages() {
+	seenMessages.Lock()
+	seenMessages.counts = map[uint64]int{}
+	seenMessages.Unlock()
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FlagSet is implemented by *flag.FlagSet and *pflag.FlagSet.
+type FlagSet interface {
+	StringVar(p *string, name string, value string, usage string)
+}
+
+// Flags holds the values of the standard logging flags registered by
+// RegisterFlags.
+type Flags struct {
+	// Level is a level for all loggers, eg "DBG", or a LOGXI value
+	Level string
+	// Format is a LOGXI_FORMAT value
+	Format string
+	// File is a file entries are appended to instead of stdout
+	File string
+	// Partition is "none", "day" or "week", see FileWriter
+	Partition string
+	// Sync is "never", "error" or an interval, see FileWriter.SetSync
+	Sync string
+	// Color is "auto", "always" or "never"
+	Color string
+}
+
+// RegisterFlags registers -log-level, -log-format, -log-file,
+// -log-partition, -log-sync and -log-color. Call Apply after parsing.
+//
+// Example
+//
+//	logFlags := log.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	if err := logFlags.Apply(); err != nil {
+//		fmt.Fprintln(os.Stderr, err)
+//		os.Exit(2)
+//	}
+func RegisterFlags(fs FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.Level, "log-level", "", "log level for all loggers, eg DBG, or a LOGXI value, eg *=WRN,api=DBG")
+	fs.StringVar(&f.Format, "log-format", "", "log format: happy, text, JSON or a LOGXI_FORMAT value")
+	fs.StringVar(&f.File, "log-file", "", "append log entries to this file instead of stdout")
+	fs.StringVar(&f.Partition, "log-partition", "none", "write the log file in directories per day or ISO week: none, day or week")
+	fs.StringVar(&f.Sync, "log-sync", "never", "sync the log file: never, error to sync every error, or an interval, eg 1s")
+	fs.StringVar(&f.Color, "log-color", "auto", "colorize entries: auto, always or never")
+	return f
+}
+
+// Apply applies flags which were set on top of the environment
+// configuration. Registered loggers get the new levels and file. Loggers
+// created earlier keep their formatter. If the file can't be opened,
+// entries are logged as JSON to stderr, see Degraded.
+func (f *Flags) Apply() error {
+	conf := cfg().config
+
+	if f.Level != "" {
+		levels := f.Level
+		if !strings.Contains(levels, "=") {
+			if _, ok := ParseLevel(levels); !ok {
+				return fmt.Errorf("logxi: unknown log level %q", f.Level)
+			}
+			levels = "*=" + levels
+		}
+		conf.Levels = levels
+	}
+	if f.Format != "" {
+		conf.Format = f.Format
+	}
+
+	switch f.Color {
+	case "", "auto":
+		if f.File != "" {
+			updateSettings(func(s *settings) { s.disableColors = true })
+		}
+	case "always":
+		updateSettings(func(s *settings) { s.disableColors = false })
+		if conf.Colors == "*=off" {
+			conf.Colors = ""
+		}
+	case "never":
+		conf.Colors = "*=off"
+	default:
+		return fmt.Errorf("logxi: log-color must be auto, always or never, got %q", f.Color)
+	}
+
+	partition, err := ParsePartition(f.Partition)
+	if err != nil {
+		return err
+	}
+	syncPolicy, syncInterval, err := ParseSyncPolicy(f.Sync)
+	if err != nil {
+		return err
+	}
+	if f.File != "" {
+		fw, err := NewFileWriter(f.File, partition)
+		if err != nil {
+			setStdout(fallback("file "+f.File, err))
+			conf.Format = FormatJSON
+		} else {
+			recovered("file " + f.File)
+			fw.SetSync(syncPolicy, syncInterval)
+			setStdout(fw)
+			appliedFile = fw
+		}
+	}
+
+	ProcessEnv(&conf)
+
+	loggers.Lock()
+	for name, logger := range loggers.loggers {
+		if name != "__logxi" {
+			logger.SetLevel(getLogLevel(name))
+		}
+	}
+	loggers.Unlock()
+	return nil
+}
+
+// appliedFile is the FileWriter opened by the last Apply, closed once it
+// is replaced.
+var appliedFile *FileWriter
+
+// setStdout replaces the writer used by New, including for registered
+// loggers writing to the previous one.
+func setStdout(writer io.Writer) {
+	old := colorableStdout
+	colorableStdout = writer
+	loggers.Lock()
+	for _, logger := range loggers.loggers {
+		if l, ok := logger.(*DefaultLogger); ok && l.getWriter() == old {
+			l.setWriter(writer)
+		}
+	}
+	loggers.Unlock()
+	if appliedFile != nil && old == io.Writer(appliedFile) && writer != old {
+		appliedFile.Close()
+		appliedFile = nil
+	}
+}
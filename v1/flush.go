@@ -0,0 +1,124 @@
+package log
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// FlushTimeout bounds how long Fatal and Recover wait for queued entries
+// to be written and synced before the process dies.
+var FlushTimeout = 5 * time.Second
+
+// flusher is implemented by writers which queue writes, eg AsyncWriter
+type flusher interface {
+	Flush(timeout time.Duration) error
+}
+
+// syncer is implemented by writers which buffer writes in the OS, eg
+// *os.File
+type syncer interface {
+	Sync() error
+}
+
+// Flush drains the queues of asynchronous writers used by registered
+// loggers, including the sinks of a MultiSink, and syncs file sinks,
+// waiting up to timeout.
+func Flush(timeout time.Duration) error {
+	writers := loggerWriters()
+
+	deadline := time.Now().Add(timeout)
+	done := make(chan error, 1)
+	go func() {
+		var firstErr error
+		for _, writer := range writers {
+			if err := flushWriter(writer, deadline); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		done <- firstErr
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return ErrFlushTimeout
+	}
+}
+
+// loggerWriters returns the distinct writers of registered loggers,
+// including the sinks of a MultiSink.
+func loggerWriters() []io.Writer {
+	seen := map[io.Writer]bool{}
+	var writers []io.Writer
+	loggers.Lock()
+	for _, logger := range loggers.loggers {
+		l, ok := logger.(*DefaultLogger)
+		if !ok {
+			continue
+		}
+		w := l.getWriter()
+		if w == nil {
+			continue
+		}
+		candidates := []io.Writer{w}
+		if ms, ok := l.getFormatter().(*MultiSink); ok {
+			for _, sink := range ms.sinks {
+				candidates = append(candidates, sink.Writer)
+			}
+		}
+		for _, writer := range candidates {
+			if writer == nil {
+				continue
+			}
+			if isPointer(writer) {
+				if seen[writer] {
+					continue
+				}
+				seen[writer] = true
+			}
+			writers = append(writers, writer)
+		}
+	}
+	loggers.Unlock()
+	return writers
+}
+
+// flushWriter flushes then syncs writer. The standard streams are not
+// synced since terminals and pipes don't support it.
+func flushWriter(writer io.Writer, deadline time.Time) error {
+	writer = unwrapWriter(writer)
+	var err error
+	if f, ok := writer.(flusher); ok {
+		err = f.Flush(time.Until(deadline))
+	}
+	if writer == os.Stdout || writer == os.Stderr {
+		return err
+	}
+	if s, ok := writer.(syncer); ok {
+		if serr := s.Sync(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Recover logs a panic as a fatal entry on logger, flushes every logger
+// and panics again so the process still dies. Defer it at the top of main
+// and goroutines.
+//
+//	defer log.Recover(logger)
+func Recover(logger Logger) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if err, ok := r.(error); ok {
+		logger.Log(LevelFatal, "Recovered from panic", []interface{}{"err", err})
+	} else {
+		logger.Log(LevelFatal, "Recovered from panic", []interface{}{"panic", r})
+	}
+	reportCrash("panic")
+	Flush(FlushTimeout)
+	panic(r)
+}
@@ -1,7 +1,53 @@
 package log
 
+import (
+	"io"
+	"sync"
+)
+
 var formatterCreators = map[string]CreateFormatterFunc{}
 
+// formatterCache holds stateless formatters by kind so loggers share a
+// single instance. It is cleared whenever the environment is processed
+// since formatters precompute labels from the configuration.
+var formatterCache = struct {
+	sync.Mutex
+	formatters map[string]Formatter
+}{formatters: map[string]Formatter{}}
+
+func clearFormatterCache() {
+	formatterCache.Lock()
+	formatterCache.formatters = map[string]Formatter{}
+	formatterCache.Unlock()
+}
+
+// namedFormatter is implemented by formatters which take the logger name
+// per entry, so loggers with different names can share them.
+type namedFormatter interface {
+	formatNamed(writer io.Writer, level int, name string, msg string, args []interface{})
+}
+
+// formatNamed formats an entry of the logger name, which is only used if
+// formatter is a namedFormatter.
+func formatNamed(formatter Formatter, writer io.Writer, level int, name string, msg string, args []interface{}) {
+	if nf, ok := formatter.(namedFormatter); ok {
+		nf.formatNamed(writer, level, name, msg, args)
+		return
+	}
+	formatter.Format(writer, level, msg, args)
+}
+
+// isShareable determines if a formatter is safe to share between loggers.
+// HappyDevFormatter tracks the column being written and can't be shared.
+// Shared formatters must be namedFormatters.
+func isShareable(formatter Formatter) bool {
+	switch formatter.(type) {
+	case *TextFormatter, *JSONFormatter:
+		return true
+	}
+	return false
+}
+
 // CreateFormatterFunc is a function which creates a new instance
 // of a Formatter.
 type CreateFormatterFunc func(name, kind string) (Formatter, error)
@@ -11,12 +57,32 @@ type CreateFormatterFunc func(name, kind string) (Formatter, error)
 // logger.
 func createFormatter(name string, kind string) (Formatter, error) {
 	if kind == FormatEnv {
-		kind = logxiFormat
+		kind = cfg().logxiFormat
 	}
 	if kind == "" {
 		kind = FormatText
 	}
 
+	formatterCache.Lock()
+	formatter := formatterCache.formatters[kind]
+	formatterCache.Unlock()
+	if formatter != nil {
+		return formatter, nil
+	}
+
+	formatter, err := newFormatter(name, kind)
+	if err != nil {
+		return nil, err
+	}
+	if isShareable(formatter) {
+		formatterCache.Lock()
+		formatterCache.formatters[kind] = formatter
+		formatterCache.Unlock()
+	}
+	return formatter, nil
+}
+
+func newFormatter(name string, kind string) (Formatter, error) {
 	fn := formatterCreators[kind]
 	if fn == nil {
 		fn = formatterCreators[FormatText]
@@ -45,11 +111,18 @@ func formatFactory(name string, kind string) (Formatter, error) {
 		formatter = NewTextFormatter(name)
 	case FormatJSON:
 		formatter = NewJSONFormatter(name)
+	case FormatGELF:
+		formatter = NewGELFFormatter(name)
+	case FormatECS:
+		formatter = NewECSFormatter(name)
+	case FormatStackdriver:
+		formatter = NewStackdriverFormatter(name)
 	}
 	return formatter, err
 }
 
-// RegisterFormatFactory registers a format factory function.
+// RegisterFormatFactory registers a format factory function. If
+// LOGXI_FORMAT selects kind, loggers created afterwards use it.
 func RegisterFormatFactory(kind string, fn CreateFormatterFunc) {
 	if kind == "" {
 		panic("kind is empty string")
@@ -58,4 +131,30 @@ func RegisterFormatFactory(kind string, fn CreateFormatterFunc) {
 		panic("creator is nil")
 	}
 	formatterCreators[kind] = fn
+	updateSettings(func(s *settings) {
+		if kind == s.requestedFormat {
+			s.logxiFormat = kind
+		}
+	})
+	clearFormatterCache()
+}
+
+// RegisterFormatter registers a formatter which can be selected with
+// LOGXI_FORMAT=kind. Register formatters in an init func so loggers created
+// at startup use them.
+//
+// Example
+//
+//	func init() {
+//		log.RegisterFormatter("myformat", func(name string) log.Formatter {
+//			return NewMyFormatter(name)
+//		})
+//	}
+func RegisterFormatter(kind string, factory func(name string) Formatter) {
+	if factory == nil {
+		panic("factory is nil")
+	}
+	RegisterFormatFactory(kind, func(name, kind string) (Formatter, error) {
+		return factory(name), nil
+	})
 }
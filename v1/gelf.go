@@ -0,0 +1,186 @@
+package log
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatGELF uses GELFFormatter
+const FormatGELF = "gelf"
+
+// GELFFormatter formats entries as GELF 1.1 messages for Graylog. The
+// message is short_message, the call stack of errors full_message and
+// key-value pairs additional fields, eg "userId" is "_userId". Numbers are
+// kept as numbers, other values are strings. Select it with
+// LOGXI_FORMAT=gelf.
+//
+// Messages end with a newline. Send them with a NetWriter created with
+// WithGELF, which frames them for TCP or chunks them for UDP.
+//
+// Example
+//
+//	nw, err := log.NewNetWriter("udp", "graylog:12201", log.WithGELF())
+//	if err != nil {
+//		panic(err)
+//	}
+//	logger := log.NewLogger3(nw, "api", log.NewGELFFormatter("api"))
+type GELFFormatter struct {
+	name string
+	host string
+}
+
+// NewGELFFormatter creates a formatter for the logger name.
+func NewGELFFormatter(name string) *GELFFormatter {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	return &GELFFormatter{name: name, host: host}
+}
+
+// gelfField returns key as an additional field name, which may only
+// contain word characters, dots and dashes. "_id" is reserved.
+func gelfField(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, key)
+	if name == "id" {
+		name = "id_"
+	}
+	return "_" + name
+}
+
+// Format writes a message without full_message.
+func (gf *GELFFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	gf.format(writer, time.Now(), level, msg, args, nil)
+}
+
+// FormatEntry writes a message with the entry's call stack, if captured,
+// as full_message.
+func (gf *GELFFormatter) FormatEntry(writer io.Writer, entry *Entry) {
+	gf.format(writer, entry.Time, entry.Level, entry.Msg, entry.Fields, entry.Stack)
+}
+
+func (gf *GELFFormatter) format(writer io.Writer, t time.Time, level int, msg string, args []interface{}, stack []Frame) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+
+	// reuse the JSON formatter's string escaping
+	var jf JSONFormatter
+	buf.WriteString(`{"version":"1.1","host":`)
+	jf.writeString(buf, gf.host)
+	buf.WriteString(`,"short_message":`)
+	if msg == "" {
+		// short_message is required to be non-empty
+		msg = "-"
+	}
+	jf.writeString(buf, stripANSI(msg))
+	if len(stack) > 0 {
+		buf.WriteString(`,"full_message":`)
+		jf.writeString(buf, msg+"\n"+stackTrace(stack))
+	}
+	buf.WriteString(`,"timestamp":`)
+	buf.WriteString(strconv.FormatFloat(float64(t.UnixNano()/int64(time.Millisecond))/1000, 'f', 3, 64))
+	buf.WriteString(`,"level":`)
+	buf.WriteString(strconv.Itoa(SyslogSeverity(level)))
+	buf.WriteString(`,"_logger":`)
+	jf.writeString(buf, gf.name)
+	buf.WriteString(`,"_pid":`)
+	buf.WriteString(pidStr)
+
+	if len(args) == 1 {
+		args = []interface{}{singleArgKey, args[0]}
+	}
+	if len(args)%2 != 0 {
+		args = []interface{}{warnImbalancedKey, syslogValue(args)}
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok || key == "" {
+			key = badKeyAtIndex(i)
+		}
+		buf.WriteString(`,"`)
+		buf.WriteString(gelfField(key))
+		buf.WriteString(`":`)
+		gf.writeValue(buf, &jf, args[i+1])
+	}
+	buf.WriteString("}\n")
+	buf.WriteTo(writer)
+}
+
+// writeValue writes numbers as numbers and other values as strings, the
+// only types of additional fields.
+func (gf *GELFFormatter) writeValue(buf bufferWriter, jf *JSONFormatter, val interface{}) {
+	value := reflect.ValueOf(val)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(value.Int(), 10))
+		return
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString(strconv.FormatUint(value.Uint(), 10))
+		return
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		// NaN and infinities are not JSON numbers
+		if f == f && f-f == 0 {
+			buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+			return
+		}
+	}
+	if val == nil {
+		jf.writeString(buf, "")
+		return
+	}
+	jf.writeString(buf, syslogValue(val))
+}
+
+// GELFChunkSize is the size of the UDP datagrams GELF messages are chunked
+// into, small enough for WAN links. Graylog accepts up to 8192.
+var GELFChunkSize = 1420
+
+// maxGELFChunks is the number of chunks Graylog reassembles
+const maxGELFChunks = 128
+
+// gelfChunkHeader is the size of a chunk's magic bytes, message ID and
+// sequence
+const gelfChunkHeader = 12
+
+// errGELFTooLarge is returned for messages needing more than 128 chunks
+var errGELFTooLarge = errors.New("logxi: GELF message is too large to chunk")
+
+// gelfChunks splits a message into chunks of at most size bytes.
+func gelfChunks(msg []byte, size int) ([][]byte, error) {
+	if len(msg) <= size {
+		return [][]byte{msg}, nil
+	}
+	payload := size - gelfChunkHeader
+	count := (len(msg) + payload - 1) / payload
+	if count > maxGELFChunks {
+		return nil, errGELFTooLarge
+	}
+	var id [8]byte
+	rand.Read(id[:])
+	chunks := make([][]byte, 0, count)
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * payload
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, gelfChunkHeader+end-seq*payload)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id[:]...)
+		chunk = append(chunk, byte(seq), byte(count))
+		chunks = append(chunks, append(chunk, msg[seq*payload:end]...))
+	}
+	return chunks, nil
+}
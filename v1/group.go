@@ -0,0 +1,159 @@
+package log
+
+// FieldGroup is a named group of key-value pairs created with Group.
+type FieldGroup struct {
+	name string
+	args []interface{}
+}
+
+// Group groups related key-value pairs under name. JSONFormatter logs the
+// group as a nested object, other formatters prefix the keys with name, eg
+// "db.host". A group takes the place of a key-value pair. Groups may be
+// nested.
+//
+// Example
+//
+//	logger.Info("query", log.Group("db", "host", host, "latency", d), "rows", n)
+//	//=> {"_m": "query", "db": {"host": "pg1", "latency": 3}, "rows": 12}
+func Group(name string, args ...interface{}) *FieldGroup {
+	return &FieldGroup{name: name, args: expandPairs(args)}
+}
+
+// each calls fn with each key-value pair of the group. Invalid keys and
+// imbalanced pairs are reported like they are for entries.
+func (g *FieldGroup) each(fn func(key string, val interface{})) {
+	if len(g.args)%2 != 0 {
+		fn(warnImbalancedKey, g.args)
+		return
+	}
+	for i := 0; i < len(g.args); i += 2 {
+		if key, ok := g.args[i].(string); ok && key != "" {
+			fn(key, g.args[i+1])
+		} else {
+			fn(badKeyAtIndex(i), g.args[i+1])
+		}
+	}
+}
+
+// flatten returns the pairs of the group with keys prefixed by prefix,
+// flattening nested groups.
+func (g *FieldGroup) flatten(prefix string) []interface{} {
+	var pairs []interface{}
+	g.each(func(key string, val interface{}) {
+		if nested, ok := val.(*FieldGroup); ok {
+			pairs = append(pairs, nested.flatten(prefix+key+".")...)
+			return
+		}
+		pairs = append(pairs, prefix+key, val)
+	})
+	return pairs
+}
+
+// pairArg is implemented by args which take the place of a key-value
+// pair, eg groups and arrays.
+type pairArg interface {
+	pair() (key string, val interface{})
+}
+
+func (g *FieldGroup) pair() (string, interface{}) {
+	return g.name, g
+}
+
+// expandPairs replaces each pairArg in args with its key-value pair.
+func expandPairs(args []interface{}) []interface{} {
+	found := false
+	for _, arg := range args {
+		if _, ok := arg.(pairArg); ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return args
+	}
+	result := make([]interface{}, 0, len(args)+2)
+	for i := 0; i < len(args); i++ {
+		if pa, ok := args[i].(pairArg); ok {
+			key, val := pa.pair()
+			result = append(result, key, val)
+			continue
+		}
+		result = append(result, args[i])
+		if i+1 < len(args) {
+			result = append(result, args[i+1])
+			i++
+		}
+	}
+	return result
+}
+
+func firstError(args []interface{}) error {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupLogger nests the key-value pairs of every entry under a group.
+type groupLogger struct {
+	Logger
+	name string
+}
+
+func (gl *groupLogger) group(args []interface{}) []interface{} {
+	if len(args) == 0 {
+		return args
+	}
+	return []interface{}{Group(gl.name, args...)}
+}
+
+// Name returns the name of the wrapped logger.
+func (gl *groupLogger) Name() string {
+	return loggerName(gl.Logger)
+}
+
+// Trace logs a trace entry.
+func (gl *groupLogger) Trace(msg string, args ...interface{}) {
+	gl.Logger.Trace(msg, gl.group(args)...)
+}
+
+// Debug logs a debug entry.
+func (gl *groupLogger) Debug(msg string, args ...interface{}) {
+	gl.Logger.Debug(msg, gl.group(args)...)
+}
+
+// Info logs an info entry.
+func (gl *groupLogger) Info(msg string, args ...interface{}) {
+	gl.Logger.Info(msg, gl.group(args)...)
+}
+
+// Warn logs a warn entry.
+func (gl *groupLogger) Warn(msg string, args ...interface{}) error {
+	gl.Logger.Warn(msg, gl.group(args)...)
+	if !gl.IsWarn() {
+		return nil
+	}
+	// the error is hidden in the group
+	return firstError(args)
+}
+
+// Error logs an error entry.
+func (gl *groupLogger) Error(msg string, args ...interface{}) error {
+	err := gl.Logger.Error(msg, gl.group(args)...)
+	if e := firstError(args); e != nil {
+		return e
+	}
+	return err
+}
+
+// Fatal logs a fatal entry then panics.
+func (gl *groupLogger) Fatal(msg string, args ...interface{}) {
+	gl.Logger.Fatal(msg, gl.group(args)...)
+}
+
+// Log logs a leveled entry.
+func (gl *groupLogger) Log(level int, msg string, args []interface{}) {
+	gl.Logger.Log(level, msg, gl.group(args))
+}
@@ -3,7 +3,10 @@ package log
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/mgutz/ansi"
@@ -22,11 +25,74 @@ type colorScheme struct {
 	Info  string
 	Warn  string
 	Error string
+	// Levels colors levels registered with RegisterLevel
+	Levels map[int]string
+
+	Added   string
+	Removed string
+
+	// Name colors logger names, see nameColor
+	Name     string
+	NameHash bool
+	Names    map[string]string
+	// patterns of Names, longest first
+	namePatterns []string
+}
+
+// namePalette is cycled through when logger names are colored by hash.
+var namePalette = []string{
+	"cyan", "green", "yellow", "blue", "magenta", "red",
+	"cyan+h", "green+h", "yellow+h", "blue+h", "magenta+h", "red+h",
+}
+
+// nameColor returns the color of a logger name. Colors for a specific
+// logger, eg "name.api*=cyan", take precedence over "name=hash" which picks
+// a stable color from the logger name.
+func (cs *colorScheme) nameColor(name string) string {
+	if c, ok := cs.Names[name]; ok {
+		return c
+	}
+	for _, pattern := range cs.namePatterns {
+		if matchName(pattern, name) {
+			return cs.Names[pattern]
+		}
+	}
+	if cs.NameHash && !cfg().disableColors {
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		return ansi.ColorCode(namePalette[h.Sum32()%uint32(len(namePalette))])
+	}
+	return cs.Name
+}
+
+// levelColor returns the color of a registered level. Unless set in the
+// theme, it is the color of the nearest built-in level at least as
+// verbose.
+func (cs *colorScheme) levelColor(level int) string {
+	if c, ok := cs.Levels[level]; ok {
+		return c
+	}
+	switch {
+	case level <= LevelError:
+		return cs.Error
+	case level <= LevelWarn:
+		return cs.Warn
+	case level <= LevelInfo:
+		return cs.Info
+	case level <= LevelDebug:
+		return cs.Debug
+	}
+	return cs.Trace
+}
+
+// happyValuer is implemented by values which render themselves in
+// HappyDevFormatter. inline is printed in place of the value and block, if
+// not empty, is printed on the lines following the entry.
+type happyValuer interface {
+	happyValue() (inline string, block string)
 }
 
 var indent = "  "
-var maxCol = defaultMaxCol
-var theme *colorScheme
 
 func parseKVList(s, separator string) map[string]string {
 	pairs := strings.Split(s, separator)
@@ -50,6 +116,12 @@ func parseKVList(s, separator string) map[string]string {
 }
 
 func parseTheme(theme string) *colorScheme {
+	return parseThemeColors(theme, cfg().disableColors)
+}
+
+// parseThemeColors parses a LOGXI_COLORS theme, leaving every color empty
+// when disableColors is set.
+func parseThemeColors(theme string, disableColors bool) *colorScheme {
 	m := parseKVList(theme, ",")
 	cs := &colorScheme{}
 	var wildcard string
@@ -81,6 +153,9 @@ func parseTheme(theme string) *colorScheme {
 		cs.Warn = wildcard
 		cs.Info = wildcard
 		cs.Error = wildcard
+
+		cs.Added = wildcard
+		cs.Removed = wildcard
 	}
 
 	cs.Key = color("key")
@@ -94,6 +169,39 @@ func parseTheme(theme string) *colorScheme {
 	cs.Warn = color("WRN")
 	cs.Info = color("INF")
 	cs.Error = color("ERR")
+
+	cs.Added = color("added")
+	cs.Removed = color("removed")
+
+	for level, name := range LevelMap {
+		if _, ok := m[name]; ok && !builtinLevel(level) {
+			if cs.Levels == nil {
+				cs.Levels = map[int]string{}
+			}
+			cs.Levels[level] = color(name)
+		}
+	}
+
+	cs.Name = cs.Misc
+	if style, ok := m["name"]; ok {
+		if style == "hash" {
+			cs.NameHash = true
+		} else {
+			cs.Name = color("name")
+		}
+	}
+	for key := range m {
+		if strings.HasPrefix(key, "name.") && len(key) > len("name.") {
+			if cs.Names == nil {
+				cs.Names = map[string]string{}
+			}
+			cs.Names[key[len("name."):]] = color(key)
+			cs.namePatterns = append(cs.namePatterns, key[len("name."):])
+		}
+	}
+	sort.Slice(cs.namePatterns, func(i, j int) bool {
+		return len(cs.namePatterns[i]) > len(cs.namePatterns[j])
+	})
 	return cs
 }
 
@@ -125,15 +233,17 @@ func NewHappyDevFormatter(name string) *HappyDevFormatter {
 }
 
 func (hd *HappyDevFormatter) writeKey(buf bufferWriter, key string) {
-	// assumes this is not the first key
-	hd.writeString(buf, Separator)
+	// the time may be hidden, see LOGXI_SHOW
+	if hd.col > 0 {
+		hd.writeString(buf, cfg().pairSeparator())
+	}
 	if key == "" {
 		return
 	}
-	buf.WriteString(theme.Key)
+	buf.WriteString(cfg().theme.Key)
 	hd.writeString(buf, key)
-	hd.writeString(buf, AssignmentChar)
-	if !disableColors {
+	hd.writeString(buf, cfg().assignment())
+	if !cfg().disableColors {
 		buf.WriteString(ansi.Reset)
 	}
 }
@@ -148,7 +258,7 @@ func (hd *HappyDevFormatter) set(buf bufferWriter, key string, value interface{}
 		str = fmt.Sprintf("%v", value)
 	}
 	val := strings.Trim(str, "\n ")
-	if (isPretty && key != "") || hd.col+len(key)+2+len(val) >= maxCol {
+	if (cfg().isPretty && key != "") || hd.col+displayWidth(key)+2+displayWidth(val) >= cfg().maxCol {
 		buf.WriteString("\n")
 		hd.col = 0
 		hd.writeString(buf, indent)
@@ -158,7 +268,7 @@ func (hd *HappyDevFormatter) set(buf bufferWriter, key string, value interface{}
 		buf.WriteString(color)
 	}
 	hd.writeString(buf, val)
-	if color != "" && !disableColors {
+	if color != "" && !cfg().disableColors {
 		buf.WriteString(ansi.Reset)
 	}
 }
@@ -167,19 +277,15 @@ func (hd *HappyDevFormatter) set(buf bufferWriter, key string, value interface{}
 // cleanly. Do not send ANSI escape sequences, just raw strings
 func (hd *HappyDevFormatter) writeString(buf bufferWriter, s string) {
 	buf.WriteString(s)
-	hd.col += len(s)
+	hd.col += displayWidth(s)
 }
 
-func (hd *HappyDevFormatter) getContext(color string) string {
-	if disableCallstack {
+func (hd *HappyDevFormatter) getContext(color string, caller *Frame) string {
+	if disableCallstack || caller == nil {
 		return ""
 	}
-	frames := stackFrames(7, true)
-	if len(frames) == 0 {
-		return ""
-	}
-	for _, frame := range frames {
-		context := frame.String(color, theme.Source)
+	for _, frame := range sourceFrames([]Frame{*caller}, true) {
+		context := frame.String(color, cfg().theme.Source)
 		if context != "" {
 			return context
 		}
@@ -187,43 +293,51 @@ func (hd *HappyDevFormatter) getContext(color string) string {
 	return ""
 }
 
-func (hd *HappyDevFormatter) getLevelContext(level int, entry map[string]interface{}) (message string, context string, color string) {
-
+func (hd *HappyDevFormatter) getLevelContext(e *Entry, entry map[string]interface{}) (message string, context string, color string) {
+	level := e.Level
 	switch level {
 	case LevelTrace:
-		color = theme.Trace
-		context = hd.getContext(color)
+		color = cfg().theme.Trace
+		context = hd.getContext(color, e.Caller)
 		context += "\n"
-	case LevelDebug:
-		color = theme.Debug
-	case LevelInfo:
-		color = theme.Info
-	// case LevelWarn:
-	// 	color = theme.Warn
-	// 	context = hd.getContext(color)
-	// 	context += "\n"
+	case LevelDebug, LevelInfo:
+		if level == LevelDebug {
+			color = cfg().theme.Debug
+		} else {
+			color = cfg().theme.Info
+		}
+		// only captured when configured, see the caller option
+		if e.Caller != nil {
+			context = hd.getContext(color, e.Caller)
+		}
 	case LevelWarn, LevelError, LevelFatal:
 
 		// warnings return an error but if it does not have an error
 		// then print line info only
 		if level == LevelWarn {
-			color = theme.Warn
+			color = cfg().theme.Warn
 			kv := entry[KeyMap.CallStack]
 			if kv == nil {
-				context = hd.getContext(color)
+				context = hd.getContext(color, e.Caller)
 				context += "\n"
 				break
 			}
 		} else {
-			color = theme.Error
+			color = cfg().theme.Error
 		}
 
-		if disableCallstack || contextLines == -1 {
+		// the stack may not be captured at this level, see the stack option
+		if len(e.Stack) == 0 {
+			context = hd.getContext(color, e.Caller)
+			break
+		}
+
+		if disableCallstack || cfg().contextLines == -1 {
 			context = trimmedStackTrace()
 			break
 		}
 
-		frames := stackFrames(6, true)
+		frames := sourceFrames(e.Stack, true)
 		if len(frames) == 0 {
 			break
 		}
@@ -232,13 +346,13 @@ func (hd *HappyDevFormatter) getLevelContext(level int, entry map[string]interfa
 		defer pool.Put(errbuf)
 		lines := 0
 		for _, frame := range frames {
-			err := frame.readSource(contextLines)
+			err := frame.readSource(cfg().contextLines)
 			if err != nil {
 				// by setting to empty, the original stack is used
 				errbuf.Reset()
 				break
 			}
-			ctx := frame.String(color, theme.Source)
+			ctx := frame.String(color, cfg().theme.Source)
 			if ctx == "" {
 				continue
 			}
@@ -248,15 +362,44 @@ func (hd *HappyDevFormatter) getLevelContext(level int, entry map[string]interfa
 		}
 		context = errbuf.String()
 	default:
-		panic("should never get here")
+		// levels registered with RegisterLevel
+		color = cfg().theme.levelColor(level)
+		if e.Caller != nil {
+			context = hd.getContext(color, e.Caller)
+		}
 	}
 	return message, context, color
 }
 
+// framesString renders the frames logged by JSONFormatter like
+// runtime/debug.Stack.
+func framesString(callstack interface{}) string {
+	frames, ok := callstack.([]interface{})
+	if !ok {
+		return fmt.Sprintf("%v", callstack)
+	}
+	buf := pool.Get()
+	defer pool.Put(buf)
+	for _, f := range frames {
+		frame, _ := f.(map[string]interface{})
+		fmt.Fprintf(buf, "%v()\n\t%v:%v\n", frame["func"], frame["file"], frame["line"])
+	}
+	return buf.String()
+}
+
 // Format a log entry.
 func (hd *HappyDevFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	e := newEntry(level, hd.name, msg, args, stackOptions{})
+	hd.FormatEntry(writer, e)
+	e.release()
+}
+
+// FormatEntry formats a log entry using its caller and call stack for
+// context.
+func (hd *HappyDevFormatter) FormatEntry(writer io.Writer, e *Entry) {
 	buf := pool.Get()
 	defer pool.Put(buf)
+	level, msg, args := e.Level, e.Msg, e.Fields
 
 	if len(args) == 1 {
 		args = append(args, 0)
@@ -294,28 +437,48 @@ func (hd *HappyDevFormatter) Format(writer io.Writer, level int, msg string, arg
 	hd.col = 0
 
 	// timestamp
-	buf.WriteString(theme.Misc)
-	hd.writeString(buf, entry[KeyMap.Time].(string))
-	if !disableColors {
-		buf.WriteString(ansi.Reset)
+	if isShown(KeyMap.Time) {
+		buf.WriteString(cfg().theme.Misc)
+		hd.writeString(buf, entry[KeyMap.Time].(string))
+		if up, ok := entry[KeyMap.Uptime].(float64); ok {
+			hd.writeString(buf, " +"+strconv.FormatFloat(up, 'f', 3, 64)+"s")
+		}
+		if !cfg().disableColors {
+			buf.WriteString(ansi.Reset)
+		}
 	}
 
 	// emphasize warnings and errors
-	message, context, color := hd.getLevelContext(level, entry)
+	message, context, color := hd.getLevelContext(e, entry)
 	if message == "" {
 		message = entry[KeyMap.Message].(string)
 	}
 
 	// DBG, INF ...
-	hd.set(buf, "", entry[KeyMap.Level].(string), color)
+	if isShown(KeyMap.Level) {
+		hd.set(buf, "", entry[KeyMap.Level].(string), color)
+	}
 	// logger name
-	hd.set(buf, "", entry[KeyMap.Name], theme.Misc)
+	if isShown(KeyMap.Name) {
+		if cfg().nameWidth > 0 {
+			name := abbreviateName(hd.name, cfg().nameWidth)
+			hd.set(buf, "", name, cfg().theme.nameColor(hd.name))
+			if pad := cfg().nameWidth - displayWidth(name); pad > 0 {
+				hd.writeString(buf, strings.Repeat(" ", pad))
+			}
+		} else {
+			hd.set(buf, "", entry[KeyMap.Name], cfg().theme.nameColor(hd.name))
+		}
+	}
 	// message from user
-	hd.set(buf, "", message, theme.Message)
+	if isShown(KeyMap.Message) {
+		hd.set(buf, "", message, cfg().theme.Message)
+	}
 
 	// Preserve key order in the sequencethey were added by developer.This
 	// makes it easier for developers to follow the log.
 	order := []string{}
+	values := []interface{}{}
 	lenArgs := len(args)
 	for i := 0; i < len(args); i += 2 {
 		if i+1 >= lenArgs {
@@ -326,9 +489,12 @@ func (hd *HappyDevFormatter) Format(writer io.Writer, level int, msg string, arg
 		} else {
 			order = append(order, badKeyAtIndex(i))
 		}
+		values = append(values, args[i+1])
 	}
 
-	for _, key := range order {
+	var blocks []string
+	hidden := 0
+	for i, key := range order {
 		// skip reserved keys which were already added to buffer above
 		isReserved, err := isReservedKey(key)
 		if err != nil {
@@ -336,7 +502,49 @@ func (hd *HappyDevFormatter) Format(writer io.Writer, level int, msg string, arg
 		} else if isReserved {
 			continue
 		}
-		hd.set(buf, key, entry[key], theme.Value)
+		if !isShown(key) {
+			hidden++
+			continue
+		}
+		if g, ok := values[i].(*FieldGroup); ok {
+			pairs := g.flatten(key + ".")
+			for j := 0; j < len(pairs); j += 2 {
+				hd.set(buf, pairs[j].(string), pairs[j+1], cfg().theme.Value)
+			}
+			continue
+		}
+		if err, ok := values[i].(error); ok {
+			if errs := unwrapErrors(err); errs != nil {
+				for j, e := range errs {
+					subkey := key + "." + strconv.Itoa(j)
+					hd.set(buf, subkey, stripANSI(e.Error()), cfg().theme.Value)
+					if frames := errorFrames(e); len(frames) > 0 {
+						blocks = append(blocks, subkey+":\n"+framesText(frames))
+					}
+				}
+				continue
+			}
+		}
+		if hv, ok := values[i].(happyValuer); ok {
+			inline, block := hv.happyValue()
+			hd.set(buf, key, inline, cfg().theme.Value)
+			if block != "" {
+				blocks = append(blocks, block)
+			}
+			continue
+		}
+		if cfg().expandValues && isComplex(entry[key]) {
+			hd.set(buf, key, summarizeComplex(entry[key]), cfg().theme.Value)
+			blocks = append(blocks, prettyJSON(values[i]))
+			continue
+		}
+		hd.set(buf, key, entry[key], cfg().theme.Value)
+	}
+
+	if hidden == 1 {
+		hd.set(buf, "", "+1 field", cfg().theme.Misc)
+	} else if hidden > 1 {
+		hd.set(buf, "", "+"+strconv.Itoa(hidden)+" fields", cfg().theme.Misc)
 	}
 
 	addLF := true
@@ -344,27 +552,37 @@ func (hd *HappyDevFormatter) Format(writer io.Writer, level int, msg string, arg
 	// WRN,ERR file, line number context
 
 	if context != "" {
-		// warnings and traces are single line, space can be optimized
-		if level == LevelTrace || (level == LevelWarn && !hasCallStack) {
+		// warnings and less severe entries are single line, space can be
+		// optimized
+		if level > LevelWarn || (level == LevelWarn && !hasCallStack) {
 			// gets rid of "in "
 			idx := strings.IndexRune(context, 'n')
 			hd.set(buf, "in", context[idx+2:], color)
 		} else {
 			buf.WriteRune('\n')
-			if !disableColors {
+			if !cfg().disableColors {
 				buf.WriteString(color)
 			}
 			addLF = context[len(context)-1:len(context)] != "\n"
 			buf.WriteString(context)
-			if !disableColors {
+			if !cfg().disableColors {
 				buf.WriteString(ansi.Reset)
 			}
 		}
 	} else if hasCallStack {
-		hd.set(buf, "", entry[KeyMap.CallStack], color)
+		hd.set(buf, "", framesString(entry[KeyMap.CallStack]), color)
 	}
 	if addLF {
 		buf.WriteRune('\n')
 	}
+	// multiline values are written below the entry
+	for _, block := range blocks {
+		for _, line := range strings.Split(strings.TrimRight(block, "\n"), "\n") {
+			buf.WriteString(indent)
+			buf.WriteString(indent)
+			buf.WriteString(line)
+			buf.WriteRune('\n')
+		}
+	}
 	buf.WriteTo(writer)
 }
@@ -0,0 +1,178 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SinkStatus is the state of a sink in the logging pipeline.
+type SinkStatus struct {
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	// SpoolDepth is the number of writes waiting on the sink
+	SpoolDepth int `json:"spoolDepth"`
+	// Failures is the number of consecutive failed writes
+	Failures int `json:"failures"`
+	// Dropped is the number of entries the sink dropped, eg while
+	// disconnected
+	Dropped       uint64    `json:"dropped"`
+	LastError     string    `json:"lastError,omitempty"`
+	LastErrorTime time.Time `json:"lastErrorTime"`
+}
+
+// Healthy determines if the sink is connected and its last write
+// succeeded.
+func (ss SinkStatus) Healthy() bool {
+	return ss.Connected && ss.Failures == 0
+}
+
+// HealthReporter is implemented by writers which report the state of their
+// sinks, eg TeeWriter, NetWriter and FileWriter.
+type HealthReporter interface {
+	Health() []SinkStatus
+}
+
+// sinkHealth tracks the consecutive failures and last error of a sink.
+type sinkHealth struct {
+	mu            sync.Mutex
+	failures      int
+	lastErr       error
+	lastErrorTime time.Time
+}
+
+// record counts a failed write, or resets the failures after a successful
+// one. It returns err.
+func (sh *sinkHealth) record(err error) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if err == nil {
+		sh.failures = 0
+		return nil
+	}
+	sh.failures++
+	sh.lastErr = err
+	sh.lastErrorTime = time.Now()
+	return err
+}
+
+// status returns the status of the sink name.
+func (sh *sinkHealth) status(name string, connected bool, depth int, dropped uint64) SinkStatus {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	ss := SinkStatus{
+		Name:          name,
+		Connected:     connected,
+		SpoolDepth:    depth,
+		Failures:      sh.failures,
+		Dropped:       dropped,
+		LastErrorTime: sh.lastErrorTime,
+	}
+	if sh.lastErr != nil {
+		ss.LastError = sh.lastErr.Error()
+	}
+	return ss
+}
+
+// PipelineStatus is the state of the logging pipeline.
+type PipelineStatus struct {
+	Healthy  bool          `json:"healthy"`
+	Sinks    []SinkStatus  `json:"sinks"`
+	Degraded []Degradation `json:"degraded"`
+}
+
+// Status aggregates the state of the writers of registered loggers which
+// implement HealthReporter, including the sinks of a MultiSink and both
+// writers of a LevelSplitWriter, along with sinks which fell back to
+// stderr.
+func Status() PipelineStatus {
+	status := PipelineStatus{
+		Healthy:  true,
+		Sinks:    []SinkStatus{},
+		Degraded: Degraded(),
+	}
+	if len(status.Degraded) > 0 {
+		status.Healthy = false
+	}
+
+	seen := map[HealthReporter]bool{}
+	loggers.Lock()
+	var reporters []HealthReporter
+	for _, logger := range loggers.loggers {
+		l, ok := logger.(*DefaultLogger)
+		if !ok {
+			continue
+		}
+		writers := []io.Writer{l.getWriter()}
+		if ms, ok := l.getFormatter().(*MultiSink); ok {
+			// the logger's writer is not used
+			writers = writers[:0]
+			for _, sink := range ms.sinks {
+				writers = append(writers, sink.Writer)
+			}
+		}
+		for _, writer := range writers {
+			for _, hr := range healthReporters(writer) {
+				if isPointer(hr) {
+					if seen[hr] {
+						continue
+					}
+					seen[hr] = true
+				}
+				reporters = append(reporters, hr)
+			}
+		}
+	}
+	loggers.Unlock()
+
+	for _, hr := range reporters {
+		for _, ss := range hr.Health() {
+			if !ss.Healthy() {
+				status.Healthy = false
+			}
+			status.Sinks = append(status.Sinks, ss)
+		}
+	}
+	return status
+}
+
+// healthReporters returns the HealthReporters writing entries written to
+// writer.
+func healthReporters(writer io.Writer) []HealthReporter {
+	switch w := unwrapWriter(writer).(type) {
+	case HealthReporter:
+		return []HealthReporter{w}
+	case *LevelSplitWriter:
+		return append(healthReporters(w.stdout), healthReporters(w.stderr)...)
+	}
+	return nil
+}
+
+// Healthy determines if every sink of the logging pipeline is healthy.
+func Healthy() bool {
+	return Status().Healthy
+}
+
+// HealthHandler returns a handler for readiness probes which responds with
+// the pipeline status as JSON, and 503 Service Unavailable if it is not
+// healthy.
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := Status()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// unwrapWriter returns the writer wrapped by a ConcurrentWriter.
+func unwrapWriter(writer io.Writer) io.Writer {
+	if cw, ok := writer.(*ConcurrentWriter); ok {
+		return cw.writer
+	}
+	return writer
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ByteSize is a number of bytes. HappyDevFormatter prints it in binary
+// units, eg "1.4 MiB", while JSONFormatter logs the raw number so it can be
+// aggregated.
+type ByteSize int64
+
+// Bytes returns n as a ByteSize value.
+//
+// Example
+//
+//	logger.Info("uploaded", "size", log.Bytes(n))
+func Bytes(n int64) ByteSize {
+	return ByteSize(n)
+}
+
+func (b ByteSize) happyValue() (string, string) {
+	return humanizeBytes(int64(b)), ""
+}
+
+// PerSecond is a rate per second. HappyDevFormatter prints it with SI
+// suffixes, eg "2.3k/s", while JSONFormatter logs the raw number.
+type PerSecond float64
+
+// Rate returns the rate of n events over duration d.
+//
+// Example
+//
+//	logger.Info("processed", "rate", log.Rate(float64(count), time.Since(start)))
+func Rate(n float64, d time.Duration) PerSecond {
+	if d <= 0 {
+		return 0
+	}
+	return PerSecond(n / d.Seconds())
+}
+
+func (r PerSecond) happyValue() (string, string) {
+	return humanizeRate(float64(r)), ""
+}
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+func humanizeBytes(n int64) string {
+	// the magnitude is unsigned so -math.MinInt64 doesn't overflow
+	sign, u := "", uint64(n)
+	if n < 0 {
+		sign = "-"
+		u = -u
+	}
+	if u < 1024 {
+		return sign + strconv.FormatUint(u, 10) + " B"
+	}
+	f := float64(u)
+	unit := -1
+	for f >= 1024 && unit < len(byteUnits)-1 {
+		f /= 1024
+		unit++
+	}
+	return sign + trimZeroDecimal(strconv.FormatFloat(f, 'f', 1, 64)) + " " + byteUnits[unit]
+}
+
+var rateUnits = []string{"", "k", "M", "G", "T"}
+
+func humanizeRate(f float64) string {
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+	unit := 0
+	for f >= 1000 && unit < len(rateUnits)-1 {
+		f /= 1000
+		unit++
+	}
+	return sign + trimZeroDecimal(strconv.FormatFloat(f, 'f', 1, 64)) + rateUnits[unit] + "/s"
+}
+
+func trimZeroDecimal(s string) string {
+	return strings.TrimSuffix(s, ".0")
+}
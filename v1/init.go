@@ -36,6 +36,16 @@ func Suppress(quiet bool) {
 
 var silent bool
 
+// SetQuiet suppresses every entry except Fatal and more severe entries,
+// which are still logged, flushed and reported before panicking. Use it in
+// batch tools run by schedulers which treat any output as a failure. Call
+// it before logging from other goroutines.
+func SetQuiet(quiet bool) {
+	quietMode = quiet
+}
+
+var quietMode bool
+
 // internalLog is the logger used by logxi itself
 var InternalLog Logger
 
@@ -52,6 +62,60 @@ func (lm *loggerMap) set(name string, logger Logger) {
 	lm.loggers[name] = logger
 }
 
+// Unregister removes the named logger from the registry along with any
+// stats and interned copy kept for its name. Frameworks which create
+// per-connection or per-tenant named loggers should unregister them when done
+// so they can be garbage collected. An unregistered logger remains usable but
+// no longer follows configuration changes.
+func Unregister(name string) {
+	loggers.Lock()
+	delete(loggers.loggers, name)
+	loggers.Unlock()
+
+	stats.Lock()
+	delete(stats.loggers, name)
+	stats.Unlock()
+
+	internedNames.Lock()
+	delete(internedNames.names, name)
+	internedNames.Unlock()
+}
+
+// SetLevelByName sets the level of registered loggers whose name matches
+// pattern, a LOGXI pattern such as "models" or "dat*". Loggers created
+// afterwards with a matching name use the level too. It takes effect
+// immediately and is safe to call while logging, so a running process can
+// be made quieter or noisier, eg from an admin endpoint. Loggers disabled
+// when created are NullLog and can't be enabled.
+//
+// Example
+//
+//	log.SetLevelByName("dat*", log.LevelDebug)
+func SetLevelByName(pattern string, level int) {
+	loggers.Lock()
+	defer loggers.Unlock()
+
+	// copy so getLogLevel never reads a map being written
+	updateSettings(func(s *settings) {
+		nameLevelMap := make(map[string]int, len(s.logxiNameLevelMap)+1)
+		for k, v := range s.logxiNameLevelMap {
+			nameLevelMap[k] = v
+		}
+		nameLevelMap[pattern] = level
+		s.logxiNameLevelMap = nameLevelMap
+	})
+
+	for name, logger := range loggers.loggers {
+		// wildcards don't apply to InternalLog
+		if name == "__logxi" && pattern != name {
+			continue
+		}
+		if matchName(pattern, name) {
+			logger.SetLevel(level)
+		}
+	}
+}
+
 // The assignment character between key-value pairs
 var AssignmentChar = ": "
 
@@ -62,11 +126,8 @@ var Separator = " "
 const ltsvAssignmentChar = ":"
 const ltsvSeparator = "\t"
 
-// logxiEnabledMap maps log name patterns to levels
-var logxiNameLevelMap map[string]int
-
-// logxiFormat is the formatter kind to create
-var logxiFormat string
+const logfmtAssignmentChar = "="
+const logfmtSeparator = " "
 
 var colorableStdout io.Writer
 var defaultContextLines = 2
@@ -80,49 +141,60 @@ var defaultLogxiColorsEnv string
 var defaultTimeFormat string
 var disableCallstack bool
 var disableCheckKeys bool
-var disableColors bool
 var home string
-var isPretty bool
 var isTerminal bool
 var isWindows = runtime.GOOS == "windows"
 var pkgMutex sync.Mutex
 var pool = NewBufferPool()
-var timeFormat string
 var wd string
 var pid = os.Getpid()
 var pidStr = strconv.Itoa(os.Getpid())
 
 // KeyMapping is the key map used to print built-in log entry fields.
 type KeyMapping struct {
-	Level     string
-	Message   string
-	Name      string
-	PID       string
-	Time      string
-	CallStack string
+	Level       string
+	Message     string
+	Name        string
+	PID         string
+	Time        string
+	CallStack   string
+	BootID      string
+	Fingerprint string
+	WriteTime   string
+	Uptime      string
+	Checksum    string
+	Version     string
+	Caller      string
 }
 
 // KeyMap is the key map to use when printing log statements.
 var KeyMap = &KeyMapping{
-	Level:     "_l",
-	Message:   "_m",
-	Name:      "_n",
-	PID:       "_p",
-	Time:      "_t",
-	CallStack: "_c",
+	Level:       "_l",
+	Message:     "_m",
+	Name:        "_n",
+	PID:         "_p",
+	Time:        "_t",
+	CallStack:   "_c",
+	BootID:      "_b",
+	Fingerprint: "_f",
+	WriteTime:   "_w",
+	Uptime:      "_u",
+	Checksum:    "_x",
+	Version:     "_v",
+	Caller:      "_s",
 }
 
 var logxiKeys []string
 
 func setDefaults(isTerminal bool) {
 	var err error
-	contextLines = defaultContextLines
+	updateSettings(func(s *settings) { s.contextLines = defaultContextLines })
 	wd, err = os.Getwd()
 	if err != nil {
 		InternalLog.Error("Could not get working directory")
 	}
 
-	logxiKeys = []string{KeyMap.Level, KeyMap.Message, KeyMap.Name, KeyMap.Time, KeyMap.CallStack, KeyMap.PID}
+	logxiKeys = []string{KeyMap.Level, KeyMap.Message, KeyMap.Name, KeyMap.Time, KeyMap.CallStack, KeyMap.PID, KeyMap.BootID, KeyMap.Fingerprint, KeyMap.WriteTime, KeyMap.Uptime, KeyMap.Checksum, KeyMap.Version, KeyMap.Caller}
 
 	if isTerminal {
 		defaultLogxiEnv = "*=WRN"
@@ -136,16 +208,20 @@ func setDefaults(isTerminal bool) {
 		defaultFormat = FormatJSON
 		defaultLevel = LevelError
 		defaultTimeFormat = "2006-01-02T15:04:05-0700"
-		disableColors = true
+		// colors are stripped per destination if stderr is a terminal
+		if !stderrTerminal {
+			updateSettings(func(s *settings) { s.disableColors = true })
+		}
 	}
 
 	if isWindows {
 		home = os.Getenv("HOMEPATH")
 		if os.Getenv("ConEmuANSI") == "ON" {
-			defaultLogxiColorsEnv = "key=cyan+h,value,misc=blue+h,source=yellow,TRC,DBG,WRN=yellow+h,INF=green+h,ERR=red+h"
+			defaultLogxiColorsEnv = Themes["conemu"]
 		} else {
-			colorableStdout = NewConcurrentWriter(colorable.NewColorableStdout())
-			defaultLogxiColorsEnv = "ERR=red,misc=cyan,key=cyan"
+			colorableConsole = colorable.NewColorableStdout()
+			colorableStdout = NewConcurrentWriter(colorableConsole)
+			defaultLogxiColorsEnv = Themes["windows"]
 		}
 		// DefaultScheme is a color scheme optimized for dark background
 		// but works well with light backgrounds
@@ -153,9 +229,9 @@ func setDefaults(isTerminal bool) {
 		home = os.Getenv("HOME")
 		term := os.Getenv("TERM")
 		if term == "xterm-256color" {
-			defaultLogxiColorsEnv = "key=cyan+h,value,misc=blue,source=88,TRC,DBG,WRN=yellow,INF=green+h,ERR=red+h,message=magenta+h"
+			defaultLogxiColorsEnv = Themes["xterm256"]
 		} else {
-			defaultLogxiColorsEnv = "key=cyan+h,value,misc=blue,source=magenta,TRC,DBG,WRN=yellow,INF=green,ERR=red+h"
+			defaultLogxiColorsEnv = Themes["ansi"]
 		}
 	}
 }
@@ -179,6 +255,7 @@ func init() {
 	colorableStdout = NewConcurrentWriter(os.Stdout)
 
 	isTerminal = isatty.IsTerminal(os.Stdout.Fd())
+	stderrTerminal = isatty.IsTerminal(os.Stderr.Fd())
 
 	// the internal logger to report errors
 	if isTerminal {
@@ -193,8 +270,13 @@ func init() {
 	RegisterFormatFactory(FormatHappy, formatFactory)
 	RegisterFormatFactory(FormatText, formatFactory)
 	RegisterFormatFactory(FormatJSON, formatFactory)
+	RegisterFormatFactory(FormatGELF, formatFactory)
+	RegisterFormatFactory(FormatECS, formatFactory)
+	RegisterFormatFactory(FormatStackdriver, formatFactory)
 	ProcessEnv(readFromEnviron())
 
 	// package logger for users
 	DefaultLog = New("~")
+
+	Epoch()
 }
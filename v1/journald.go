@@ -0,0 +1,186 @@
+package log
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JournaldSocket is the socket of the native journald protocol.
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldFormatter formats entries for the native journald protocol.
+// Key-value pairs are journal fields, eg "userId" is USERID, the level is
+// PRIORITY and the caller is CODE_FILE, CODE_LINE and CODE_FUNC, so
+// `journalctl -o verbose` shows structured entries. Write them with a
+// JournaldSink.
+//
+// Example
+//
+//	sink, err := log.NewJournaldSink()
+//	if err != nil {
+//		panic(err)
+//	}
+//	logger := log.NewLogger3(sink, "api", log.NewJournaldFormatter("api"))
+type JournaldFormatter struct {
+	name       string
+	identifier string
+}
+
+// NewJournaldFormatter creates a formatter for the logger name. Entries are
+// identified by the name of the executable, see SYSLOG_IDENTIFIER.
+func NewJournaldFormatter(name string) *JournaldFormatter {
+	return &JournaldFormatter{name: name, identifier: filepath.Base(os.Args[0])}
+}
+
+// journalField returns key as a journal field name: upper case letters,
+// digits and underscores, not starting with an underscore, which journald
+// reserves, or a digit.
+func journalField(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "FIELD_" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// writeJournalField writes a field, using the binary form for values with
+// newlines.
+func writeJournalField(buf bufferWriter, name string, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteRune('=')
+		buf.WriteString(value)
+		buf.WriteRune('\n')
+		return
+	}
+	buf.WriteRune('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteRune('\n')
+}
+
+// Format writes an entry without its caller.
+func (jf *JournaldFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	jf.format(writer, level, msg, args, nil)
+}
+
+// FormatEntry writes an entry with its caller, if captured.
+func (jf *JournaldFormatter) FormatEntry(writer io.Writer, entry *Entry) {
+	jf.format(writer, entry.Level, entry.Msg, entry.Fields, entry.Caller)
+}
+
+func (jf *JournaldFormatter) format(writer io.Writer, level int, msg string, args []interface{}, caller *Frame) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+
+	writeJournalField(buf, "MESSAGE", msg)
+	writeJournalField(buf, "PRIORITY", strconv.Itoa(SyslogSeverity(level)))
+	writeJournalField(buf, "SYSLOG_IDENTIFIER", jf.identifier)
+	writeJournalField(buf, "LOGXI_NAME", jf.name)
+	if caller != nil {
+		writeJournalField(buf, "CODE_FILE", caller.File)
+		writeJournalField(buf, "CODE_LINE", strconv.Itoa(caller.Line))
+		writeJournalField(buf, "CODE_FUNC", caller.Function)
+	}
+
+	if len(args) == 1 {
+		args = []interface{}{singleArgKey, args[0]}
+	}
+	if len(args)%2 != 0 {
+		writeJournalField(buf, warnImbalancedKey, syslogValue(args))
+		args = nil
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok || key == "" {
+			key = badKeyAtIndex(i)
+		}
+		writeJournalField(buf, journalField(key), syslogValue(args[i+1]))
+	}
+	buf.WriteTo(writer)
+}
+
+// JournaldSink is a concurrent safe writer sending each write to journald
+// as a datagram. Entries larger than the socket's datagram limit, usually
+// a few hundred KB, fail with an error.
+type JournaldSink struct {
+	path string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	health sinkHealth
+}
+
+// NewJournaldSink connects to the journald socket, JournaldSocket. It fails
+// when the process doesn't run under systemd.
+func NewJournaldSink() (*JournaldSink, error) {
+	return newJournaldSink(JournaldSocket)
+}
+
+func newJournaldSink(path string) (*JournaldSink, error) {
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		return nil, err
+	}
+	return &JournaldSink{path: path, conn: conn}, nil
+}
+
+func (js *JournaldSink) Write(p []byte) (int, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if js.conn == nil {
+		conn, err := net.Dial("unixgram", js.path)
+		if err != nil {
+			return 0, js.health.record(err)
+		}
+		js.conn = conn
+	}
+	if _, err := js.conn.Write(p); err != nil {
+		// journald may have restarted
+		js.conn.Close()
+		js.conn = nil
+		return 0, js.health.record(err)
+	}
+	js.health.record(nil)
+	return len(p), nil
+}
+
+// Health reports whether the sink is connected and the last error.
+func (js *JournaldSink) Health() []SinkStatus {
+	js.mu.Lock()
+	connected := js.conn != nil
+	js.mu.Unlock()
+	return []SinkStatus{js.health.status("journald", connected, 0, 0)}
+}
+
+// Close closes the connection. Later writes reconnect.
+func (js *JournaldSink) Close() error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if js.conn == nil {
+		return nil
+	}
+	err := js.conn.Close()
+	js.conn = nil
+	return err
+}
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"io"
 	"reflect"
-	"runtime/debug"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 type bufferWriter interface {
@@ -17,20 +17,26 @@ type bufferWriter interface {
 }
 
 // JSONFormatter is a fast, efficient JSON formatter optimized for logging.
+// Each entry is a single JSON object on its own line, suitable for shipping
+// to Elasticsearch, Splunk and the like. Select it with LOGXI_FORMAT=JSON or
+// LOGXI_FORMAT=json.
 //
-// * log entry keys are not escaped
+// * log entry keys are only escaped when they need to be
 //   Who uses complex keys when coding? Checked by HappyDevFormatter in case user does.
 //   Nested object keys are escaped by json.Marshal().
 // * Primitive types uses strconv
 // * Logger reserved key values (time, log name, level) require no conversion
 // * sync.Pool buffer for bytes.Buffer
 type JSONFormatter struct {
-	name string
+	// name is the logger name written by Format. Loggers pass their name
+	// per entry so a formatter can be shared.
+	name   string
+	floats FloatFormat
 }
 
 // NewJSONFormatter creates a new instance of JSONFormatter.
 func NewJSONFormatter(name string) *JSONFormatter {
-	return &JSONFormatter{name: name}
+	return &JSONFormatter{name: name, floats: cfg().floatFormat}
 }
 
 func (jf *JSONFormatter) writeString(buf bufferWriter, s string) {
@@ -44,11 +50,98 @@ func (jf *JSONFormatter) writeString(buf bufferWriter, s string) {
 }
 
 func (jf *JSONFormatter) writeError(buf bufferWriter, err error) {
-	jf.writeString(buf, err.Error())
-	jf.set(buf, KeyMap.CallStack, string(debug.Stack()))
+	if errs := unwrapErrors(err); errs != nil {
+		jf.writeErrors(buf, errs)
+	} else {
+		jf.writeString(buf, stripANSI(err.Error()))
+	}
+	buf.WriteString(`, "`)
+	buf.WriteString(KeyMap.CallStack)
+	buf.WriteString(`":`)
+	jf.writeFrames(buf, callerFrames())
+	jf.set(buf, KeyMap.Fingerprint, fingerprint(err, stackFrames(0, false)))
 	return
 }
 
+// SetFloatFormat sets how this formatter renders floats.
+func (jf *JSONFormatter) SetFloatFormat(ff FloatFormat) {
+	jf.floats = ff
+}
+
+// writeFloat writes f, non-finite floats as null or a string since JSON
+// has no NaN.
+func (jf *JSONFormatter) writeFloat(buf bufferWriter, f float64, bitSize int) {
+	s, finite := jf.floats.format(f, bitSize)
+	switch {
+	case finite:
+		buf.WriteString(s)
+	case jf.floats.NaNString:
+		jf.writeString(buf, s)
+	default:
+		buf.WriteString("null")
+	}
+}
+
+// writeGroup writes a group as a nested object.
+func (jf *JSONFormatter) writeGroup(buf bufferWriter, g *FieldGroup) {
+	buf.WriteRune('{')
+	first := true
+	g.each(func(key string, val interface{}) {
+		if !first {
+			buf.WriteString(", ")
+		}
+		first = false
+		jf.writeString(buf, key)
+		buf.WriteRune(':')
+		jf.appendValue(buf, val)
+	})
+	buf.WriteRune('}')
+}
+
+// writeErrors writes the errors of a multi-error as an array of {"msg"}
+// objects, including the stack of errors which recorded one.
+func (jf *JSONFormatter) writeErrors(buf bufferWriter, errs []error) {
+	buf.WriteRune('[')
+	for i, err := range errs {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(`{"msg":`)
+		jf.writeString(buf, stripANSI(err.Error()))
+		if frames := errorFrames(err); len(frames) > 0 {
+			buf.WriteString(`, "`)
+			buf.WriteString(KeyMap.CallStack)
+			buf.WriteString(`":`)
+			jf.writeFrames(buf, frames)
+		}
+		buf.WriteRune('}')
+	}
+	buf.WriteRune(']')
+}
+
+// writeFrames writes a stack as an array of {"func", "file", "line"}
+// objects.
+func (jf *JSONFormatter) writeFrames(buf bufferWriter, frames []*frameInfo) {
+	buf.WriteRune('[')
+	for i, frame := range frames {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		filename := frame.filename
+		if cfg().shortPaths {
+			filename = shortenPath(filename)
+		}
+		buf.WriteString(`{"func":`)
+		jf.writeString(buf, frame.method)
+		buf.WriteString(`, "file":`)
+		jf.writeString(buf, filename)
+		buf.WriteString(`, "line":`)
+		buf.WriteString(strconv.Itoa(frame.lineno))
+		buf.WriteRune('}')
+	}
+	buf.WriteRune(']')
+}
+
 func (jf *JSONFormatter) appendValue(buf bufferWriter, val interface{}) {
 	if val == nil {
 		buf.WriteString("null")
@@ -75,14 +168,23 @@ func (jf *JSONFormatter) appendValue(buf bufferWriter, val interface{}) {
 		buf.WriteString(strconv.FormatUint(value.Uint(), 10))
 
 	case reflect.Float32:
-		buf.WriteString(strconv.FormatFloat(value.Float(), 'g', -1, 32))
+		jf.writeFloat(buf, value.Float(), 32)
 
 	case reflect.Float64:
-		buf.WriteString(strconv.FormatFloat(value.Float(), 'g', -1, 64))
+		jf.writeFloat(buf, value.Float(), 64)
 
 	default:
 		var err error
 
+		if g, ok := val.(*FieldGroup); ok {
+			jf.writeGroup(buf, g)
+			return
+		}
+		if a, ok := val.(*Array); ok {
+			buf.WriteString(a.json(jf.floats))
+			return
+		}
+
 		// always show error stack even at cost of some performance. there's
 		// nothing worse than looking at production logs without a clue
 		if err, ok := val.(error); ok {
@@ -91,7 +193,9 @@ func (jf *JSONFormatter) appendValue(buf bufferWriter, val interface{}) {
 		}
 
 		var b []byte
-		if stringer, ok := val.(fmt.Stringer); ok {
+		if _, ok := val.(structuredValue); ok {
+			b, err = json.Marshal(val)
+		} else if stringer, ok := val.(fmt.Stringer); ok {
 			b, err = json.Marshal(stringer.String())
 		} else {
 			b, err = json.Marshal(val)
@@ -119,16 +223,45 @@ func (jf *JSONFormatter) appendValue(buf bufferWriter, val interface{}) {
 	}
 }
 
+// structuredValue is implemented by the values of this package which
+// marshal to JSON rather than their String, eg DiffValue and TableValue.
+// Other values implementing fmt.Stringer, eg time.Time, log their String.
+type structuredValue interface {
+	happyValuer
+	json.Marshaler
+}
+
 func (jf *JSONFormatter) set(buf bufferWriter, key string, val interface{}) {
 	// WARNING: assumes this is not first key
-	buf.WriteString(`, "`)
-	buf.WriteString(key)
-	buf.WriteString(`":`)
+	if needsEscape(key) {
+		buf.WriteString(`, `)
+		jf.writeString(buf, key)
+		buf.WriteString(`:`)
+	} else {
+		buf.WriteString(`, "`)
+		buf.WriteString(key)
+		buf.WriteString(`":`)
+	}
 	jf.appendValue(buf, val)
 }
 
+// needsEscape determines if s must be escaped to be a JSON string.
+func needsEscape(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == '"' || c == '\\' {
+			return true
+		}
+	}
+	return !utf8.ValidString(s)
+}
+
 // Format formats log entry as JSON.
 func (jf *JSONFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	jf.formatNamed(writer, level, jf.name, msg, args)
+}
+
+// formatNamed formats a log entry of the logger name as JSON.
+func (jf *JSONFormatter) formatNamed(writer io.Writer, level int, name string, msg string, args []interface{}) {
 	buf := pool.Get()
 	defer pool.Put(buf)
 
@@ -136,9 +269,15 @@ func (jf *JSONFormatter) Format(writer io.Writer, level int, msg string, args []
 	const colon = `":"`
 
 	buf.WriteString(`{"`)
+	if cfg().showSchema {
+		buf.WriteString(KeyMap.Version)
+		buf.WriteString(`":`)
+		buf.WriteString(strconv.Itoa(SchemaVersion))
+		buf.WriteString(`, "`)
+	}
 	buf.WriteString(KeyMap.Time)
 	buf.WriteString(`":"`)
-	buf.WriteString(time.Now().Format(timeFormat))
+	buf.WriteString(time.Now().Format(cfg().timeFormat))
 
 	buf.WriteString(`", "`)
 	buf.WriteString(KeyMap.PID)
@@ -146,20 +285,47 @@ func (jf *JSONFormatter) Format(writer io.Writer, level int, msg string, args []
 	buf.WriteString(pidStr)
 
 	buf.WriteString(`", "`)
+	buf.WriteString(KeyMap.BootID)
+	buf.WriteString(`":"`)
+	buf.WriteString(BootID)
+	buf.WriteRune('"')
+
+	if cfg().showUptime {
+		buf.WriteString(`, "`)
+		buf.WriteString(KeyMap.Uptime)
+		buf.WriteString(`":`)
+		buf.WriteString(uptime())
+	}
+
+	buf.WriteString(`, "`)
 	buf.WriteString(KeyMap.Level)
 	buf.WriteString(`":"`)
 	buf.WriteString(LevelMap[level])
+	if cfg().datadogMode {
+		buf.WriteString(`", "status":"`)
+		buf.WriteString(datadogStatus(level))
+	}
 
 	buf.WriteString(`", "`)
 	buf.WriteString(KeyMap.Name)
-	buf.WriteString(`":"`)
-	buf.WriteString(jf.name)
+	buf.WriteString(`":`)
+	if needsEscape(name) {
+		jf.writeString(buf, name)
+	} else {
+		buf.WriteRune('"')
+		buf.WriteString(name)
+		buf.WriteRune('"')
+	}
 
-	buf.WriteString(`", "`)
+	buf.WriteString(`, "`)
 	buf.WriteString(KeyMap.Message)
 	buf.WriteString(`":`)
 	jf.appendValue(buf, msg)
 
+	if cfg().logCaller && capturesCaller(level) {
+		jf.set(buf, KeyMap.Caller, callerString())
+	}
+
 	var lenArgs = len(args)
 	if lenArgs > 0 {
 		if lenArgs == 1 {
@@ -170,6 +336,9 @@ func (jf *JSONFormatter) Format(writer io.Writer, level int, msg string, args []
 					if key == "" {
 						// show key is invalid
 						jf.set(buf, badKeyAtIndex(i), args[i+1])
+					} else if cfg().datadogMode {
+						key, val := datadogField(key, args[i+1])
+						jf.set(buf, key, val)
 					} else {
 						jf.set(buf, key, args[i+1])
 					}
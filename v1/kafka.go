@@ -0,0 +1,252 @@
+package log
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KafkaMessage is a message published by a KafkaSink.
+type KafkaMessage struct {
+	Topic string
+	// Key is the partitioning key, eg the logger name. It is nil for
+	// messages written to the sink directly.
+	Key   []byte
+	Value []byte
+}
+
+// KafkaProducer publishes a batch of messages. logxi doesn't depend on a
+// Kafka client, adapt the one your pipeline uses, eg
+//
+//	type producer struct{ w *kafka.Writer } // github.com/segmentio/kafka-go
+//
+//	func (p producer) Produce(messages []log.KafkaMessage) error {
+//		batch := make([]kafka.Message, len(messages))
+//		for i, m := range messages {
+//			batch[i] = kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value}
+//		}
+//		return p.w.WriteMessages(context.Background(), batch...)
+//	}
+type KafkaProducer interface {
+	Produce(messages []KafkaMessage) error
+}
+
+// KafkaOptions configures a KafkaSink.
+type KafkaOptions struct {
+	// BatchSize is the maximum number of messages per batch, 100 if zero
+	BatchSize int
+	// BatchTimeout is how long a partial batch waits for more messages,
+	// 1 second if zero
+	BatchTimeout time.Duration
+	// QueueSize is the number of messages waiting to be batched, 10000 if
+	// zero. Messages are dropped when the queue is full.
+	QueueSize int
+}
+
+// KafkaSink publishes formatted entries to a Kafka topic in batches, in
+// the background, so logging doesn't wait for brokers. Loggers created
+// with Logger write JSON keyed by their name, Writer writes with another
+// key or formatter. Flush waits for queued messages, eg before exiting.
+//
+// Example
+//
+//	kafka := log.NewKafkaSink(producer{w}, "logs", log.KafkaOptions{})
+//	logger := kafka.Logger("api")
+type KafkaSink struct {
+	producer KafkaProducer
+	topic    string
+	opts     KafkaOptions
+
+	queue   chan KafkaMessage
+	pending sync.WaitGroup
+	dropped uint64
+	health  sinkHealth
+
+	// flush publishes the partial batch
+	flush chan struct{}
+	// mu is held for reading while a message is queued so Close can't stop
+	// run between the check of closed and the send
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewKafkaSink creates a sink publishing to topic with producer.
+func NewKafkaSink(producer KafkaProducer, topic string, opts KafkaOptions) *KafkaSink {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.BatchTimeout <= 0 {
+		opts.BatchTimeout = time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	ks := &KafkaSink{
+		producer: producer,
+		topic:    topic,
+		opts:     opts,
+		queue:    make(chan KafkaMessage, opts.QueueSize),
+		flush:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go ks.run()
+	return ks
+}
+
+// Logger creates a logger writing JSON messages keyed by name.
+func (ks *KafkaSink) Logger(name string) Logger {
+	return NewLogger3(ks.Writer(name), name, NewJSONFormatter(name))
+}
+
+// Writer returns a writer publishing each write as a message keyed by
+// key, eg the logger name.
+func (ks *KafkaSink) Writer(key string) *KafkaWriter {
+	return &KafkaWriter{sink: ks, key: []byte(key)}
+}
+
+// Write publishes p as a message without a key.
+func (ks *KafkaSink) Write(p []byte) (int, error) {
+	return ks.publish(nil, p)
+}
+
+func (ks *KafkaSink) publish(key []byte, p []byte) (int, error) {
+	value := p
+	if len(value) > 0 && value[len(value)-1] == '\n' {
+		value = value[:len(value)-1]
+	}
+	msg := KafkaMessage{Topic: ks.topic, Key: key, Value: append([]byte(nil), value...)}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.closed {
+		return 0, ErrWriterClosed
+	}
+	ks.pending.Add(1)
+	select {
+	case ks.queue <- msg:
+		return len(p), nil
+	default:
+		ks.pending.Done()
+		atomic.AddUint64(&ks.dropped, 1)
+		return 0, ks.health.record(errors.New("logxi: Kafka queue is full"))
+	}
+}
+
+// run publishes batches when they are full or BatchTimeout after their
+// first message.
+func (ks *KafkaSink) run() {
+	batch := make([]KafkaMessage, 0, ks.opts.BatchSize)
+	timer := time.NewTimer(ks.opts.BatchTimeout)
+	timer.Stop()
+	publish := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := ks.health.record(ks.producer.Produce(batch)); err != nil {
+			atomic.AddUint64(&ks.dropped, uint64(len(batch)))
+		}
+		for range batch {
+			ks.pending.Done()
+		}
+		batch = make([]KafkaMessage, 0, ks.opts.BatchSize)
+	}
+	add := func(msg KafkaMessage) {
+		batch = append(batch, msg)
+		if len(batch) >= ks.opts.BatchSize {
+			publish()
+		}
+	}
+	// drain publishes the queued messages
+	drain := func() {
+		for {
+			select {
+			case msg := <-ks.queue:
+				add(msg)
+			default:
+				publish()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case msg := <-ks.queue:
+			if len(batch) == 0 {
+				timer.Reset(ks.opts.BatchTimeout)
+			}
+			add(msg)
+		case <-timer.C:
+			publish()
+		case <-ks.flush:
+			timer.Stop()
+			drain()
+		case <-ks.done:
+			timer.Stop()
+			drain()
+			return
+		}
+	}
+}
+
+// Flush publishes queued messages without waiting for their batches to
+// fill and waits until they are published.
+func (ks *KafkaSink) Flush(timeout time.Duration) error {
+	select {
+	case ks.flush <- struct{}{}:
+	default:
+	}
+	done := make(chan struct{})
+	go func() {
+		ks.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return ErrFlushTimeout
+	}
+}
+
+// Close publishes queued messages and stops the sink. Later writes fail
+// with ErrWriterClosed.
+func (ks *KafkaSink) Close() error {
+	ks.mu.Lock()
+	if !ks.closed {
+		ks.closed = true
+		close(ks.done)
+	}
+	ks.mu.Unlock()
+	return ks.Flush(FlushTimeout)
+}
+
+// Health reports whether the sink is open, the number of queued messages,
+// the messages dropped and the last error.
+func (ks *KafkaSink) Health() []SinkStatus {
+	ks.mu.RLock()
+	connected := !ks.closed
+	ks.mu.RUnlock()
+	return []SinkStatus{ks.health.status("kafka "+ks.topic, connected, len(ks.queue), ks.Dropped())}
+}
+
+// Dropped returns the number of messages dropped because the queue was full
+// or publishing failed.
+func (ks *KafkaSink) Dropped() uint64 {
+	return atomic.LoadUint64(&ks.dropped)
+}
+
+// KafkaWriter is a writer of a KafkaSink publishing messages with a key.
+type KafkaWriter struct {
+	sink *KafkaSink
+	key  []byte
+}
+
+func (kw *KafkaWriter) Write(p []byte) (int, error) {
+	return kw.sink.publish(kw.key, p)
+}
+
+// Flush waits until the sink's queued messages are published.
+func (kw *KafkaWriter) Flush(timeout time.Duration) error {
+	return kw.sink.Flush(timeout)
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// SlowWriteInterval is the minimum interval between warnings about slow
+// writes to the same sink.
+var SlowWriteInterval = time.Minute
+
+// LatencyStats are the write latencies of a sink.
+type LatencyStats struct {
+	Writes     int64         `json:"writes"`
+	SlowWrites int64         `json:"slowWrites"`
+	Total      time.Duration `json:"total"`
+	Max        time.Duration `json:"max"`
+	Last       time.Duration `json:"last"`
+}
+
+// Mean is the mean write latency.
+func (ls LatencyStats) Mean() time.Duration {
+	if ls.Writes == 0 {
+		return 0
+	}
+	return ls.Total / time.Duration(ls.Writes)
+}
+
+// LatencyWriter measures the latency of every write to a sink. When writes
+// take longer than a threshold, a warning with the number of slow writes
+// and the slowest latency is logged to InternalLog at most once per
+// SlowWriteInterval, so a slow NFS mount or throttled collector is easy to
+// diagnose.
+//
+// Example
+//
+//	tee := log.NewTeeWriter().
+//		Add("stdout", os.Stdout).
+//		Add("nfs", log.NewLatencyWriter("nfs", file, 50*time.Millisecond))
+type LatencyWriter struct {
+	name      string
+	writer    io.Writer
+	threshold time.Duration
+
+	mu    sync.Mutex
+	stats LatencyStats
+	// slow writes since the last warning
+	slow     int64
+	slowMax  time.Duration
+	lastWarn time.Time
+}
+
+// NewLatencyWriter creates a writer which warns when writes to writer take
+// longer than threshold.
+func NewLatencyWriter(name string, writer io.Writer, threshold time.Duration) *LatencyWriter {
+	return &LatencyWriter{name: name, writer: writer, threshold: threshold}
+}
+
+func (lw *LatencyWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := lw.writer.Write(p)
+	elapsed := time.Since(start)
+
+	lw.mu.Lock()
+	lw.stats.Writes++
+	lw.stats.Total += elapsed
+	lw.stats.Last = elapsed
+	if elapsed > lw.stats.Max {
+		lw.stats.Max = elapsed
+	}
+	var warn bool
+	var slow int64
+	var slowMax time.Duration
+	if elapsed > lw.threshold {
+		lw.stats.SlowWrites++
+		lw.slow++
+		if elapsed > lw.slowMax {
+			lw.slowMax = elapsed
+		}
+		if now := time.Now(); now.Sub(lw.lastWarn) >= SlowWriteInterval {
+			warn, slow, slowMax = true, lw.slow, lw.slowMax
+			lw.lastWarn = now
+			lw.slow, lw.slowMax = 0, 0
+		}
+	}
+	lw.mu.Unlock()
+
+	if warn {
+		InternalLog.Warn("Slow writes to log sink",
+			"sink", lw.name,
+			"threshold", lw.threshold,
+			"slowWrites", slow,
+			"max", slowMax)
+	}
+	return n, err
+}
+
+// Stats returns the write latencies since the writer was created.
+func (lw *LatencyWriter) Stats() LatencyStats {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.stats
+}
+
+// Health reports the health of the sink if it reports its own, otherwise
+// the sink is connected.
+func (lw *LatencyWriter) Health() []SinkStatus {
+	if hr, ok := unwrapWriter(lw.writer).(HealthReporter); ok {
+		return hr.Health()
+	}
+	return []SinkStatus{{Name: lw.name, Connected: true}}
+}
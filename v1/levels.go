@@ -0,0 +1,101 @@
+package log
+
+// RegisterLevel registers a custom level, eg a level between WRN and INF.
+// name is used by formatters, by LOGXI, eg "LOGXI=*=NTC", and by
+// LOGXI_COLORS to color the level. Lower levels are more severe. Register
+// levels in an init func before loggers are created.
+//
+// Example
+//
+//	func init() {
+//		log.RegisterLevel(log.LevelNotice, "NTC")
+//	}
+//
+//	logger.Log(log.LevelNotice, "disk usage high", []interface{}{"pct", 85})
+func RegisterLevel(level int, name string) {
+	if name == "" {
+		panic("name is empty string")
+	}
+	switch level {
+	case 0, LevelEnv, LevelOff, LevelAll:
+		panic("level is reserved")
+	}
+	LevelMap[level] = name
+	LevelAtoi[name] = level
+
+	// LOGXI and LOGXI_COLORS may refer to the level
+	config := cfg().config
+	ProcessLogxiEnv(config.Levels)
+	ProcessLogxiColorsEnv(config.Colors)
+	clearFormatterCache()
+}
+
+// ParseLevel parses a level name or number, eg "DBG", "debug", "Debug" or
+// "7". Names are matched ignoring case. It does not allocate so it is cheap
+// to call when reloading configuration.
+func ParseLevel(s string) (int, bool) {
+	if level, ok := LevelAtoi[s]; ok {
+		return level, true
+	}
+	for name, level := range LevelAtoi {
+		if equalFoldASCII(name, s) {
+			return level, true
+		}
+	}
+	return atoiLevel(s)
+}
+
+// equalFoldASCII compares strings ignoring ASCII case, which unlike
+// locale-aware folding treats "i" and "I" the same everywhere.
+func equalFoldASCII(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
+
+// atoiLevel parses a numeric level. Unlike strconv.Atoi it doesn't
+// allocate an error.
+func atoiLevel(s string) (int, bool) {
+	digits := s
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > 6 {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(digits); i++ {
+		c := digits[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if len(s) > len(digits) {
+		n = -n
+	}
+	// 0 is not a level, see LevelEmergency
+	return n, n != 0
+}
+
+// builtinLevel determines if level is one of the levels logxi names.
+func builtinLevel(level int) bool {
+	switch level {
+	case LevelOff, LevelFatal, LevelError, LevelWarn, LevelInfo, LevelDebug, LevelTrace, LevelAll:
+		return true
+	}
+	return false
+}
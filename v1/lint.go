@@ -0,0 +1,162 @@
+package log
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LintCardinality is the number of distinct messages of a logger sharing a
+// template, eg "user 42 logged in" and "user 7 logged in", after which the
+// template is reported as high cardinality.
+var LintCardinality = 10
+
+// LintWarning reports a message which appears to contain variable data.
+// Messages should be constant templates with variable data in key-value
+// pairs, so entries aggregate downstream and messages can be translated.
+type LintWarning struct {
+	Name string
+	Msg  string
+	// Reason describes the variable data found, eg "number"
+	Reason string
+	// Caller is the frame which logged the message, if captured
+	Caller *Frame
+}
+
+// lintHook holds the func(LintWarning) set with SetLintHook
+var lintHook atomic.Value
+
+// SetLintHook enables lint mode, calling hook once per message template of
+// a logger which appears to contain variable data. A nil hook restores the
+// default, which reports warnings to InternalLog when the lint option of
+// LOGXI_FORMAT is set.
+//
+// Example
+//
+//	log.SetLintHook(func(w log.LintWarning) {
+//		t.Errorf("%s:%d logs dynamic message %q (%s)", w.Caller.File, w.Caller.Line, w.Msg, w.Reason)
+//	})
+func SetLintHook(hook func(LintWarning)) {
+	lintHook.Store(hook)
+}
+
+func currentLintHook() func(LintWarning) {
+	hook, _ := lintHook.Load().(func(LintWarning))
+	return hook
+}
+
+// isLinting reports whether messages are linted.
+func isLinting() bool {
+	return cfg().lintMode || currentLintHook() != nil
+}
+
+// lintPatterns find variable data in messages, in order of precedence
+var lintPatterns = []struct {
+	reason string
+	re     *regexp.Regexp
+}{
+	{"uuid", regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)},
+	{"ip address", regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)},
+	{"email", regexp.MustCompile(`\b[\w.+-]+@[\w-]+\.[\w.]+\b`)},
+	{"url", regexp.MustCompile(`\b[a-z]+://\S+`)},
+	{"key=value", regexp.MustCompile(`\b\w+=\S+`)},
+	{"quoted value", regexp.MustCompile(`"[^"]*"|'[^']*'`)},
+	{"hex id", regexp.MustCompile(`(?i)\b(0x)?[0-9a-f]{8,}\b`)},
+	{"number", regexp.MustCompile(`\d+`)},
+}
+
+// lintReason returns why msg appears to contain variable data, "" if it
+// doesn't. Numbers must be 3 digits or longer, so messages such as
+// "retry 2 of 5" or "oauth2 token" pass.
+func lintReason(msg string) string {
+	for _, p := range lintPatterns {
+		for _, match := range p.re.FindAllString(msg, -1) {
+			switch p.reason {
+			case "hex id":
+				if strings.IndexAny(match, "0123456789") < 0 {
+					// a word such as "deadbeef"
+					continue
+				}
+			case "number":
+				if len(match) < 3 {
+					continue
+				}
+			}
+			return p.reason
+		}
+	}
+	return ""
+}
+
+// lintTemplateRe matches the tokens replaced by a placeholder when grouping
+// messages by template
+var lintTemplateRe = regexp.MustCompile(`"[^"]*"|'[^']*'|[\w.@:/+-]*\d[\w.@:/+-]*`)
+
+var lintState = struct {
+	sync.Mutex
+	// reported are the templates reported per logger
+	reported map[uint64]bool
+	// messages are the distinct messages per template
+	messages map[uint64]map[uint64]bool
+}{reported: map[uint64]bool{}, messages: map[uint64]map[uint64]bool{}}
+
+// lintMessage reports msg if it appears to contain variable data or its
+// template has more than LintCardinality distinct messages. Each template
+// of a logger is reported once.
+func lintMessage(name string, msg string, skip int) {
+	template := lintTemplateRe.ReplaceAllString(msg, "_")
+	tfp := messageFingerprint(name, template)
+	reason := lintReason(msg)
+
+	lintState.Lock()
+	if lintState.reported[tfp] || len(lintState.reported) >= MaxSeenMessages {
+		lintState.Unlock()
+		return
+	}
+	if reason == "" && template != msg {
+		seen := lintState.messages[tfp]
+		if seen == nil {
+			if len(lintState.messages) >= MaxSeenMessages {
+				lintState.Unlock()
+				return
+			}
+			seen = map[uint64]bool{}
+			lintState.messages[tfp] = seen
+		}
+		seen[messageFingerprint(name, msg)] = true
+		if len(seen) > LintCardinality {
+			reason = "high cardinality"
+		}
+	}
+	if reason == "" {
+		lintState.Unlock()
+		return
+	}
+	lintState.reported[tfp] = true
+	delete(lintState.messages, tfp)
+	lintState.Unlock()
+
+	warning := LintWarning{Name: name, Msg: msg, Reason: reason}
+	if frames := captureFrames(skip, 1); len(frames) > 0 {
+		warning.Caller = &Frame{Function: frames[0].method, File: frames[0].filename, Line: frames[0].lineno}
+	}
+	if hook := currentLintHook(); hook != nil {
+		hook(warning)
+		return
+	}
+	args := []interface{}{"logger", name, "msg", msg, "reason", reason}
+	if warning.Caller != nil {
+		args = append(args, "caller", warning.Caller.File+":"+strconv.Itoa(warning.Caller.Line))
+	}
+	InternalLog.Error("Message appears to contain variable data, move it to key-value pairs", args...)
+}
+
+// resetLint forgets the templates reported so far.
+func resetLint() {
+	lintState.Lock()
+	lintState.reported = map[uint64]bool{}
+	lintState.messages = map[uint64]map[uint64]bool{}
+	lintState.Unlock()
+}
@@ -0,0 +1,106 @@
+package log
+
+import "strings"
+
+// legacyLevels maps level words found in legacy lines to levels
+var legacyLevels = map[string]int{
+	"trace":    LevelTrace,
+	"trc":      LevelTrace,
+	"debug":    LevelDebug,
+	"dbg":      LevelDebug,
+	"info":     LevelInfo,
+	"inf":      LevelInfo,
+	"notice":   LevelNotice,
+	"warn":     LevelWarn,
+	"warning":  LevelWarn,
+	"wrn":      LevelWarn,
+	"error":    LevelError,
+	"err":      LevelError,
+	"fatal":    LevelFatal,
+	"ftl":      LevelFatal,
+	"critical": LevelCritical,
+	"crit":     LevelCritical,
+	"panic":    LevelFatal,
+}
+
+// LogWriter is an io.Writer which logs each line written to it, eg to
+// redirect the standard library logger during an incremental migration
+//
+//	stdlog.SetFlags(0) // logxi adds the time
+//	stdlog.SetOutput(log.NewLogWriter(logger, log.LevelInfo))
+type LogWriter struct {
+	logger Logger
+	level  int
+
+	// InferLevels infers the level of legacy lines from a level prefix like
+	// "ERROR:", "[WARN]", "INFO" or "level=debug", which is removed from the
+	// message. Lines without one are logged at the writer's level.
+	InferLevels bool
+}
+
+// NewLogWriter creates a writer which logs lines to logger at level.
+// Entries are logged with Log so fatal lines never panic.
+func NewLogWriter(logger Logger, level int) *LogWriter {
+	return &LogWriter{logger: logger, level: level}
+}
+
+func (lw *LogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(p), "\n") {
+		line = strings.TrimRight(line, "\r ")
+		if line == "" {
+			continue
+		}
+		level := lw.level
+		if lw.InferLevels {
+			if inferred, msg, ok := inferLevel(line); ok {
+				level, line = inferred, msg
+			}
+		}
+		lw.logger.Log(level, line, nil)
+	}
+	return len(p), nil
+}
+
+// inferLevel finds the level of a legacy line and returns the line without
+// it.
+func inferLevel(line string) (int, string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return 0, line, false
+	}
+
+	// logfmt lines, eg time=... level=debug msg=...
+	for i, field := range fields {
+		if !strings.HasPrefix(field, "level=") && !strings.HasPrefix(field, "lvl=") {
+			continue
+		}
+		value := strings.Trim(field[strings.IndexByte(field, '=')+1:], `"'`)
+		if level, ok := legacyLevels[strings.ToLower(value)]; ok {
+			rest := append(fields[:i:i], fields[i+1:]...)
+			return level, strings.Join(rest, " "), true
+		}
+		return 0, line, false
+	}
+
+	// prefixes, eg ERROR: ..., [WARN] ..., INFO ...
+	word := fields[0]
+	decorated := false
+	if len(word) > 2 && word[0] == '[' && word[len(word)-1] == ']' {
+		word = word[1 : len(word)-1]
+		decorated = true
+	} else if len(word) > 1 && word[len(word)-1] == ':' {
+		word = word[:len(word)-1]
+		decorated = true
+	}
+	// undecorated words must be upper case so sentences like "Error
+	// handling is ..." are not mistaken for levels
+	if !decorated && word != strings.ToUpper(word) {
+		return 0, line, false
+	}
+	level, ok := legacyLevels[strings.ToLower(word)]
+	if !ok {
+		return 0, line, false
+	}
+	msg := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(line, " \t"), fields[0]), " \t")
+	return level, msg, true
+}
@@ -105,12 +105,14 @@ const FormatEnv = ""
 
 // LevelMap maps int enums to string level.
 var LevelMap = map[int]string{
+	LevelOff:   "OFF",
 	LevelFatal: "FTL",
 	LevelError: "ERR",
 	LevelWarn:  "WRN",
 	LevelInfo:  "INF",
 	LevelDebug: "DBG",
 	LevelTrace: "TRC",
+	LevelAll:   "ALL",
 }
 
 // LevelMap maps int enums to string level.
@@ -134,6 +136,13 @@ var LevelAtoi = map[string]int{
 	"all":   LevelAll,
 }
 
+// Namer is implemented by loggers which have a canonical name, eg the
+// loggers of this package. It is separate from Logger so other
+// implementations of Logger keep satisfying it.
+type Namer interface {
+	Name() string
+}
+
 // Logger is the interface for logging.
 type Logger interface {
 	Trace(msg string, args ...interface{})
@@ -1,13 +1,31 @@
 package log
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,19 +35,49 @@ func processEnv() {
 }
 
 func testResetEnv() {
-	disableColors = false
+	updateSettings(func(s *settings) { s.disableColors = false })
 	testBuf.Reset()
 	os.Clearenv()
 	processEnv()
 	InternalLog = testInternalLog
 }
 
+// testIsolateRegistries empties the logger, stats and deprecation
+// registries for the test so it doesn't see the loggers of other tests or
+// of a previous run with -count, and restores them afterwards.
+func testIsolateRegistries(t *testing.T) {
+	loggers.Lock()
+	savedLoggers := loggers.loggers
+	loggers.loggers = map[string]Logger{}
+	loggers.Unlock()
+	stats.Lock()
+	savedStats := stats.loggers
+	stats.loggers = map[string]*loggerStats{}
+	stats.Unlock()
+	deprecations.Lock()
+	savedDeprecations := deprecations.features
+	deprecations.features = map[string]*Deprecation{}
+	deprecations.Unlock()
+
+	t.Cleanup(func() {
+		loggers.Lock()
+		loggers.loggers = savedLoggers
+		loggers.Unlock()
+		stats.Lock()
+		stats.loggers = savedStats
+		stats.Unlock()
+		deprecations.Lock()
+		deprecations.features = savedDeprecations
+		deprecations.Unlock()
+	})
+}
+
 func TestEnvLOGXI(t *testing.T) {
 	assert := assert.New(t)
 
 	os.Setenv("LOGXI", "")
 	processEnv()
-	assert.Equal(LevelWarn, logxiNameLevelMap["*"], "Unset LOGXI defaults to *:WRN with TTY")
+	assert.Equal(LevelWarn, cfg().logxiNameLevelMap["*"], "Unset LOGXI defaults to *:WRN with TTY")
 
 	// default all to ERR
 	os.Setenv("LOGXI", "*=ERR")
@@ -79,22 +127,26 @@ func TestEnvLOGXI_FORMAT(t *testing.T) {
 	os.Setenv("LOGXI_FORMAT", "")
 	setDefaults(true)
 	processEnv()
-	assert.Equal(FormatHappy, logxiFormat, "terminal defaults to FormatHappy")
+	assert.Equal(FormatHappy, cfg().logxiFormat, "terminal defaults to FormatHappy")
 	setDefaults(false)
 	processEnv()
-	assert.Equal(FormatJSON, logxiFormat, "non terminal defaults to FormatJSON")
+	assert.Equal(FormatJSON, cfg().logxiFormat, "non terminal defaults to FormatJSON")
 
 	os.Setenv("LOGXI_FORMAT", "JSON")
 	processEnv()
-	assert.Equal(FormatJSON, logxiFormat)
+	assert.Equal(FormatJSON, cfg().logxiFormat)
 
 	os.Setenv("LOGXI_FORMAT", "json")
 	setDefaults(true)
 	processEnv()
-	assert.Equal(FormatHappy, logxiFormat, "Mismatches defaults to FormatHappy")
+	assert.Equal(FormatJSON, cfg().logxiFormat, "json is an alias of JSON")
+
+	os.Setenv("LOGXI_FORMAT", "yaml")
+	processEnv()
+	assert.Equal(FormatHappy, cfg().logxiFormat, "Mismatches defaults to FormatHappy")
 	setDefaults(false)
 	processEnv()
-	assert.Equal(FormatJSON, logxiFormat, "Mismatches defaults to FormatJSON non terminal")
+	assert.Equal(FormatJSON, cfg().logxiFormat, "Mismatches defaults to FormatJSON non terminal")
 
 	isTerminal = oldIsTerminal
 	setDefaults(isTerminal)
@@ -148,6 +200,37 @@ func TestJSON(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "bar", obj["foo"].(string))
 	assert.Equal(t, "hello", obj[KeyMap.Message].(string))
+	assert.Equal(t, BootID, obj[KeyMap.BootID].(string))
+}
+
+func TestEpoch(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	testIsolateRegistries(t)
+	oldDefault := DefaultLog
+	defer func() { DefaultLog = oldDefault }()
+
+	// logged with the default levels
+	var buf bytes.Buffer
+	DefaultLog = NewLogger3(&buf, "~", NewJSONFormatter("~"))
+	assert.False(t, DefaultLog.IsInfo())
+	Epoch()
+
+	var obj map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "logging started", obj[KeyMap.Message])
+	assert.Equal(t, LevelMap[LevelInfo], obj[KeyMap.Level])
+	assert.Equal(t, BootID, obj[KeyMap.BootID])
+	assert.Equal(t, BootID, obj["boot"])
+	assert.Equal(t, Version, obj["logxi"])
+	assert.Equal(t, cfg().config.Levels, obj["LOGXI"])
+
+	// not logged when off
+	buf.Reset()
+	DefaultLog.SetLevel(LevelOff)
+	Epoch()
+	assert.Equal(t, "", buf.String())
 }
 
 func TestJSONImbalanced(t *testing.T) {
@@ -342,3 +425,2837 @@ func TestStringer(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "bbb", obj["f"])
 }
+
+func deprecatedAPI() {
+	Deprecated("test.deprecatedAPI", "Use something else")
+}
+
+func TestDeprecated(t *testing.T) {
+	testIsolateRegistries(t)
+	deprecatedAPI()
+	deprecatedAPI()
+
+	var found *Deprecation
+	for _, d := range Deprecations() {
+		if d.Feature == "test.deprecatedAPI" {
+			found = &d
+			break
+		}
+	}
+	if assert.NotNil(t, found) {
+		assert.Equal(t, 2, found.Count)
+		assert.Contains(t, found.Caller, "logger_test.go")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	testResetEnv()
+	type config struct {
+		Host string
+		Port int
+	}
+	before := config{Host: "localhost", Port: 80}
+	after := config{Host: "localhost", Port: 8080}
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "diff", NewJSONFormatter("diff"))
+	l.SetLevel(LevelDebug)
+	l.Info("changed", "diff", Diff(before, after))
+
+	var obj map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	ops := obj["diff"].([]interface{})
+	assert.Len(t, ops, 1)
+	op := ops[0].(map[string]interface{})
+	assert.Equal(t, "replace", op["op"])
+	assert.Equal(t, "Port", op["path"])
+	assert.Equal(t, float64(8080), op["value"])
+
+	buf.Reset()
+	l = NewLogger3(&buf, "diff", NewHappyDevFormatter("diff"))
+	l.SetLevel(LevelDebug)
+	l.Info("changed", "diff", Diff("a\nb\nc", "a\nc\nd"))
+	assert.Contains(t, buf.String(), "+1 -1")
+	assert.Contains(t, buf.String(), "- b")
+	assert.Contains(t, buf.String(), "+ d")
+
+	// replaced values without a path print no assignment
+	buf.Reset()
+	l.Info("changed", "port", Diff(80, 8080))
+	assert.Contains(t, buf.String(), "- 80")
+	assert.Contains(t, buf.String(), "+ 8080")
+
+	// other values log their String
+	buf.Reset()
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	l = NewLogger3(&buf, "diff", NewJSONFormatter("diff"))
+	l.SetLevel(LevelDebug)
+	l.Info("changed", "at", at)
+	assert.Contains(t, buf.String(), `"at":"`+at.String()+`"`)
+}
+
+func TestTable(t *testing.T) {
+	testResetEnv()
+	table := Table([]string{"id", "state"}, [][]interface{}{{1, "idle"}, {2, "busy"}})
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "table", NewJSONFormatter("table"))
+	l.SetLevel(LevelDebug)
+	l.Info("workers", "workers", table)
+
+	var obj map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	rows := obj["workers"].([]interface{})
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "busy", rows[1].(map[string]interface{})["state"])
+
+	os.Setenv("LOGXI_COLORS", "*=off")
+	processEnv()
+	defer testResetEnv()
+	buf.Reset()
+	l = NewLogger3(&buf, "table", NewHappyDevFormatter("table"))
+	l.SetLevel(LevelDebug)
+	l.Info("workers", "workers", table)
+	assert.Contains(t, buf.String(), "workers: 2 rows\n")
+	assert.Contains(t, buf.String(), "    id  state\n")
+	assert.Contains(t, buf.String(), "    2   busy\n")
+}
+
+func TestLatencies(t *testing.T) {
+	samples := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	sv := Latencies(samples)
+	assert.Equal(t, 50*time.Millisecond, sv.P50)
+	assert.Equal(t, 95*time.Millisecond, sv.P95)
+	assert.Equal(t, 100*time.Millisecond, sv.Max)
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "summary", NewJSONFormatter("summary"))
+	l.SetLevel(LevelDebug)
+	l.Info("latency", "latency", sv)
+
+	var obj map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	latency := obj["latency"].(map[string]interface{})
+	assert.Equal(t, float64(100), latency["count"])
+	assert.Equal(t, float64(99), latency["p99_ms"])
+}
+
+func TestHumanize(t *testing.T) {
+	assert.Equal(t, "512 B", humanizeBytes(512))
+	assert.Equal(t, "1.4 MiB", humanizeBytes(1468006))
+	assert.Equal(t, "2 GiB", humanizeBytes(2<<30))
+	assert.Equal(t, "-512 B", humanizeBytes(-512))
+	assert.Equal(t, "-8 EiB", humanizeBytes(math.MinInt64))
+	assert.Equal(t, "8 EiB", humanizeBytes(math.MaxInt64))
+	assert.Equal(t, "2.3k/s", humanizeRate(float64(Rate(23000, 10*time.Second))))
+	assert.Equal(t, "12/s", humanizeRate(12))
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "humanize", NewJSONFormatter("humanize"))
+	l.SetLevel(LevelDebug)
+	l.Info("uploaded", "size", Bytes(1468006), "rate", Rate(23000, 10*time.Second))
+
+	var obj map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1468006), obj["size"])
+	assert.Equal(t, float64(2300), obj["rate"])
+}
+
+func TestExpandValues(t *testing.T) {
+	testResetEnv()
+	os.Setenv("LOGXI_COLORS", "*=off")
+	os.Setenv("LOGXI_FORMAT", "happy,expand,maxdepth=2")
+	processEnv()
+	defer testResetEnv()
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "expand", NewHappyDevFormatter("expand"))
+	l.SetLevel(LevelDebug)
+	nested := map[string]interface{}{
+		"db": map[string]interface{}{
+			"host":    "localhost",
+			"options": map[string]interface{}{"ssl": true},
+		},
+	}
+	l.Info("config", "config", nested)
+	s := buf.String()
+	assert.Contains(t, s, "config: {1 keys}")
+	assert.Contains(t, s, `"host": "localhost"`)
+	assert.Contains(t, s, `"options": {…}`)
+}
+
+func TestShortenPath(t *testing.T) {
+	modFile := filepath.Join(string(os.PathSeparator)+"home", "ci", "go", "pkg", "mod", "github.com", "pkg", "errors@v0.9.1", "errors.go")
+	assert.Equal(t, "github.com/pkg/errors@v0.9.1/errors.go", shortenPath(modFile))
+	assert.Equal(t, "/app/main.go", shortenPath("/app/main.go"))
+
+	stack := "goroutine 1 [running]:\nmain.main()\n\t" + modFile + ":12 +0x1d\n"
+	assert.Equal(t, "goroutine 1 [running]:\nmain.main()\n\tgithub.com/pkg/errors@v0.9.1/errors.go:12 +0x1d\n", shortenStack([]byte(stack)))
+}
+
+func logFingerprintedError(l Logger, err error) {
+	l.Error("failed", "err", err)
+}
+
+func TestFingerprint(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "fingerprint", NewJSONFormatter("fingerprint"))
+	l.SetLevel(LevelDebug)
+
+	var fingerprints []string
+	for _, msg := range []string{"user 1 not found", "user 2 not found"} {
+		buf.Reset()
+		logFingerprintedError(l, errors.New(msg))
+		var obj map[string]interface{}
+		err := json.Unmarshal(buf.Bytes(), &obj)
+		assert.NoError(t, err)
+		fingerprints = append(fingerprints, obj[KeyMap.Fingerprint].(string))
+	}
+	assert.NotEmpty(t, fingerprints[0])
+	assert.Equal(t, fingerprints[0], fingerprints[1], "same error type and stack")
+}
+
+func TestSLORules(t *testing.T) {
+	AddSLORule(SLORule{Name: "api*", Level: LevelError, Key: "status", Value: "500", SLO: "availability"})
+	defer ClearSLORules()
+
+	var buf bytes.Buffer
+	var obj map[string]interface{}
+	l := NewLogger3(&buf, "api.users", NewJSONFormatter("api.users"))
+	l.SetLevel(LevelDebug)
+
+	l.Error("failed", "status", 500)
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "availability", obj[SLOKey])
+
+	buf.Reset()
+	obj = nil
+	l.Warn("slow", "status", 500)
+	err = json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	assert.Nil(t, obj[SLOKey], "Warn is less severe than rule level")
+}
+
+func TestTopLoggers(t *testing.T) {
+	testIsolateRegistries(t)
+	EnableStats(true)
+	defer EnableStats(false)
+
+	var buf bytes.Buffer
+	noisy := NewLogger3(&buf, "stats-noisy", NewJSONFormatter("stats-noisy"))
+	noisy.SetLevel(LevelDebug)
+	quiet := NewLogger3(&buf, "stats-quiet", NewJSONFormatter("stats-quiet"))
+	quiet.SetLevel(LevelDebug)
+	for i := 0; i < 10; i++ {
+		noisy.Info("noise")
+	}
+	quiet.Info("hello")
+
+	top := TopLoggers(2, time.Minute)
+	if assert.Len(t, top, 2) {
+		assert.Equal(t, "stats-noisy", top[0].Name)
+		assert.Equal(t, int64(10), top[0].Entries)
+		assert.True(t, top[0].Bytes > top[1].Bytes)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dr := NewDryRun("*=WRN,models=DBG", FormatJSON, 2)
+	models := dr.Logger("models")
+	server := dr.Logger("server")
+
+	models.Debug("query")
+	models.Info("connected")
+	models.Info("connected")
+	server.Info("filtered by proposed level")
+	server.Error("oops")
+
+	counts := dr.Counts()
+	if assert.Len(t, counts, 3) {
+		assert.Equal(t, DryRunCount{Name: "models", Level: LevelInfo, Entries: 2, Bytes: counts[0].Bytes}, counts[0])
+		assert.Equal(t, LevelDebug, counts[1].Level)
+		assert.Equal(t, "server", counts[2].Name)
+		assert.Equal(t, LevelError, counts[2].Level)
+	}
+	samples := dr.Samples()
+	assert.Len(t, samples, 2)
+	assert.Contains(t, samples[1], "oops")
+}
+
+type failingWriter struct{}
+
+func (fw failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTeeWriter(t *testing.T) {
+	testResetEnv()
+	var good bytes.Buffer
+	tee := NewTeeWriter().Add("good", &good).Add("bad", failingWriter{})
+
+	_, err := tee.Write([]byte("one\n"))
+	_, err = tee.Write([]byte("two\n"))
+	assert.Equal(t, "one\ntwo\n", good.String())
+	if teeErr, ok := err.(*TeeError); assert.True(t, ok) {
+		assert.Len(t, teeErr.Errors, 1)
+		assert.Equal(t, "bad", teeErr.Errors[0].Sink)
+		assert.Equal(t, 2, teeErr.Errors[0].Failures)
+	}
+	assert.Contains(t, testBuf.String(), "Tee sink writes failed")
+	assert.Contains(t, testBuf.String(), "disk full")
+}
+
+func TestFormatterReuse(t *testing.T) {
+	testResetEnv()
+	var buf bytes.Buffer
+	f1, err := createFormatter("conn", FormatJSON)
+	assert.NoError(t, err)
+	f2, err := createFormatter("conn", FormatJSON)
+	assert.NoError(t, err)
+	assert.True(t, f1 == f2, "stateless formatters are shared")
+
+	h1, _ := createFormatter("conn", FormatHappy)
+	h2, _ := createFormatter("conn", FormatHappy)
+	assert.False(t, h1 == h2, "HappyDevFormatter is never shared")
+
+	l := NewLogger3(&buf, "conn", f1)
+	l.SetLevel(LevelDebug)
+	l.Info("hello")
+	assert.Contains(t, buf.String(), `"_n":"conn"`)
+
+	// loggers with different names share the formatter of a kind
+	for _, kind := range []string{FormatJSON, FormatText} {
+		buf.Reset()
+		fa, _ := createFormatter("reuse-a", kind)
+		fb, _ := createFormatter("reuse-b", kind)
+		assert.True(t, fa == fb, kind+" formatters are shared by name")
+		a := NewLogger3(&buf, "reuse-a", fa)
+		b := NewLogger3(&buf, "reuse-b", fb)
+		a.Error("from a")
+		b.Error("from b")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.Contains(t, lines[0], "reuse-a")
+			assert.Contains(t, lines[1], "reuse-b")
+		}
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf, "tenant-42")
+
+	loggers.Lock()
+	assert.NotNil(t, loggers.loggers["tenant-42"])
+	loggers.Unlock()
+
+	Unregister("tenant-42")
+	loggers.Lock()
+	assert.Nil(t, loggers.loggers["tenant-42"])
+	loggers.Unlock()
+
+	l.SetLevel(LevelDebug)
+	l.Info("still usable")
+	assert.Contains(t, buf.String(), "still usable")
+}
+
+func TestSanitizeName(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "topic\n\x1b[31m\"orders\"", NewJSONFormatter("topic\"orders\""))
+	assert.Equal(t, "topic[31m\"orders\"", l.(Namer).Name())
+
+	l.SetLevel(LevelDebug)
+	l.Info("hello")
+	var obj map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	assert.NoError(t, err)
+	assert.Equal(t, l.(Namer).Name(), obj[KeyMap.Name], "the logger's name is logged")
+
+	oldMax := MaxNameLength
+	MaxNameLength = 4
+	defer func() { MaxNameLength = oldMax }()
+	assert.Equal(t, "你", sanitizeName("你好"))
+
+	// interned names are released by Unregister
+	InternNames = true
+	defer func() { InternNames = false }()
+	NewLogger3(&buf, "ntrn", NewJSONFormatter("ntrn"))
+	internedNames.Lock()
+	assert.Equal(t, "ntrn", internedNames.names["ntrn"])
+	internedNames.Unlock()
+	Unregister("ntrn")
+	internedNames.Lock()
+	_, ok := internedNames.names["ntrn"]
+	internedNames.Unlock()
+	assert.False(t, ok)
+
+	// other implementations of Logger don't need a name
+	var _ Logger = struct{ Logger }{}
+	_, ok = interface{}(struct{ Logger }{}).(Namer)
+	assert.False(t, ok)
+}
+
+type blockingWriter struct {
+	unblock chan struct{}
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.unblock
+	return len(p), nil
+}
+
+func TestTimeoutWriter(t *testing.T) {
+	bw := &blockingWriter{unblock: make(chan struct{})}
+	tw := NewTimeoutWriter(bw, 10*time.Millisecond)
+	_, err := tw.Write([]byte("hung"))
+	assert.True(t, IsWriteCanceled(err))
+	close(bw.unblock)
+
+	// connections are interrupted with a deadline
+	client, server := net.Pipe()
+	defer server.Close()
+	tw = NewTimeoutWriter(client, 10*time.Millisecond)
+	_, err = tw.Write([]byte("nobody reading"))
+	assert.True(t, IsWriteCanceled(err))
+
+	// the interrupt's deadline doesn't outlive the write
+	go io.Copy(ioutil.Discard, server)
+	for i := 0; i < 20; i++ {
+		_, err = tw.Write([]byte("read"))
+		assert.NoError(t, err)
+	}
+
+	tw.Close()
+	_, err = tw.Write([]byte("closed"))
+	assert.True(t, IsWriteCanceled(err))
+}
+
+func TestRingBuffer(t *testing.T) {
+	ring := NewRingBuffer(3)
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "ring", NewJSONFormatter("ring"))
+	l.SetLevel(LevelDebug)
+	ring.Attach(l)
+
+	l.Debug("one", "user", 1)
+	l.Info("two", "user", 2)
+	l.Error("three", "user", 2)
+	l.Warn("four", "user", 3)
+
+	assert.Len(t, ring.Entries(), 3, "oldest entry is dropped")
+	assert.Equal(t, "two", ring.Entries()[0].Msg)
+	assert.Equal(t, 4, strings.Count(buf.String(), "\n"), "entries are still written")
+
+	result := ring.Query(RingQuery{Level: LevelWarn})
+	if assert.Len(t, result, 2) {
+		assert.Equal(t, "three", result[0].Msg)
+	}
+	result = ring.Query(RingQuery{Fields: map[string]string{"user": "2"}, Limit: 1})
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, "three", result[0].Msg)
+	}
+	assert.Len(t, ring.Query(RingQuery{Name: "other*"}), 0)
+}
+
+func TestCrashReporter(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "crash", NewJSONFormatter("crash"))
+	l.SetLevel(LevelDebug)
+	ring := NewRingBuffer(10)
+	ring.Attach(l)
+
+	var name string
+	var bundle []byte
+	SetCrashReporter(&CrashReporter{
+		Ring: ring,
+		Upload: func(n string, b []byte) error {
+			name, bundle = n, b
+			return nil
+		},
+	})
+	defer SetCrashReporter(nil)
+
+	l.Info("request", "user", 1)
+	assert.Panics(t, func() {
+		l.Fatal("out of disk", "err", errors.New("ENOSPC"))
+	})
+
+	assert.Contains(t, name, BootID)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(bundle, &obj))
+	assert.Equal(t, "out of disk", obj["reason"])
+	assert.Contains(t, obj["goroutines"], "goroutine ")
+	entries := obj["entries"].([]interface{})
+	if assert.Len(t, entries, 2) {
+		fields := entries[1].(map[string]interface{})["fields"].(map[string]interface{})
+		assert.Equal(t, "ENOSPC", fields["err"])
+	}
+}
+
+func TestNameColors(t *testing.T) {
+	cs := parseTheme("misc=blue,name=hash,name.db*=magenta,name.dbx=cyan")
+	assert.Equal(t, cs.Names["db*"], cs.nameColor("db.conn"))
+	assert.Equal(t, cs.Names["dbx"], cs.nameColor("dbx"))
+	assert.Equal(t, cs.nameColor("api"), cs.nameColor("api"), "hash is stable")
+	assert.True(t, cs.NameHash)
+
+	cs = parseTheme("misc=blue")
+	assert.Equal(t, cs.Misc, cs.nameColor("api"))
+}
+
+func TestAbbreviateName(t *testing.T) {
+	assert.Equal(t, "app", abbreviateName("app", 8))
+	assert.Equal(t, "a.b.service", abbreviateName("app.billing.service", 12))
+	assert.Equal(t, "a.b.srvc", abbreviateName("app.billing.service", 8))
+	assert.Equal(t, "a.b.sr", abbreviateName("app.billing.service", 6))
+	assert.Equal(t, "app.billing.service", abbreviateName("app.billing.service", 0))
+}
+
+func TestAuditChanges(t *testing.T) {
+	testResetEnv()
+	defer testInternalLog.SetLevel(LevelError)
+	os.Setenv("LOGXI", "*=WRN,__logxi=INF")
+	processEnv()
+	assert.Equal(t, LevelInfo, testInternalLog.(*DefaultLogger).getLevel())
+
+	testBuf.Reset()
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "audited", NewTextFormatter("audited"))
+	l.SetLevel(LevelDebug)
+	assert.Contains(t, testBuf.String(), "Level changed")
+	assert.Contains(t, testBuf.String(), "before: WRN after: DBG")
+	assert.Contains(t, testBuf.String(), "logger_test.go")
+
+	testBuf.Reset()
+	os.Setenv("LOGXI_FORMAT", "happy,maxcol=100")
+	processEnv()
+	assert.Contains(t, testBuf.String(), "Configuration changed setting: LOGXI_FORMAT")
+	assert.NotContains(t, testBuf.String(), "setting: LOGXI ")
+}
+
+func TestLogWriterInferLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "legacy", NewTextFormatter("legacy"))
+	l.SetLevel(LevelAll)
+	lw := NewLogWriter(l, LevelInfo)
+	lw.Write([]byte("ERROR: disk full\n"))
+	assert.Contains(t, buf.String(), "_l: INF _m: ERROR: disk full", "inference is opt-in")
+
+	lw.InferLevels = true
+	var cases = []struct {
+		line  string
+		level string
+		msg   string
+	}{
+		{"ERROR: disk full", "ERR", "disk full"},
+		{"[WARN] retrying", "WRN", "retrying"},
+		{"DEBUG cache miss", "DBG", "cache miss"},
+		{`time=now level="debug" msg=hi`, "DBG", "time=now msg=hi"},
+		{"Error handling is hard", "INF", "Error handling is hard"},
+		{"plain line", "INF", "plain line"},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		lw.Write([]byte(c.line + "\n"))
+		assert.Contains(t, buf.String(), "_l: "+c.level+" _m: "+c.msg, c.line)
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	oldStdout := colorableStdout
+	defer setStdout(oldStdout)
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	logFlags := RegisterFlags(fs)
+	filename := filepath.Join(t.TempDir(), "app.log")
+	err := fs.Parse([]string{"-log-level", "DBG", "-log-format", "JSON", "-log-file", filename})
+	assert.NoError(t, err)
+
+	l := New("flagged")
+	assert.NoError(t, logFlags.Apply())
+	assert.True(t, l.IsDebug(), "registered loggers get the new level")
+	l.Debug("to file")
+
+	b, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "to file")
+	assert.Equal(t, "JSON", cfg().logxiFormat)
+
+	// applying again closes the file writer it replaces
+	first := colorableStdout.(*FileWriter)
+	logFlags.File = filepath.Join(t.TempDir(), "app2.log")
+	assert.NoError(t, logFlags.Apply())
+	_, err = first.Write([]byte("late\n"))
+	assert.Equal(t, ErrWriterClosed, err)
+	l.Info("to second file")
+	b, err = ioutil.ReadFile(logFlags.File)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "to second file")
+
+	logFlags.Level = "LOUD"
+	assert.Error(t, logFlags.Apply())
+}
+
+func TestContext(t *testing.T) {
+	l := NewLogger3(ioutil.Discard, "ctx", NewTextFormatter("ctx"))
+	ctx := NewContext(context.Background(), l)
+	assert.Equal(t, l, FromContext(ctx))
+	assert.Equal(t, DefaultLog, FromContext(context.Background()))
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("LOG_BROKER", "kafka-1:9092")
+	defer os.Unsetenv("LOG_BROKER")
+	os.Unsetenv("LOG_MISSING")
+
+	s, err := ExpandEnv("kafka://${LOG_BROKER}/logs?pass=$ecret", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "kafka://kafka-1:9092/logs?pass=$ecret", s)
+
+	s, err = ExpandEnv("${LOG_MISSING:-INF}", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "INF", s)
+
+	s, err = ExpandEnv("x${LOG_MISSING}y", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "xy", s)
+
+	conf := &Configuration{Levels: "*=${LOG_MISSING}"}
+	err = conf.Expand(true)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "LOG_MISSING")
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "logxi.json")
+	ioutil.WriteFile(filename, []byte(`{
+		"levels": "*=WRN",
+		"format": "JSON",
+		"environments": {
+			"dev": {"levels": "*=DBG", "format": "happy"},
+			"prod": {"levels": "*=${LOGXI_TEST_LEVEL:-ERR}"}
+		}
+	}`), 0644)
+	defer os.Unsetenv("LOGXI_ENV")
+
+	os.Setenv("LOGXI_ENV", "")
+	conf, err := LoadConfigFile(filename, true)
+	assert.NoError(t, err)
+	assert.Equal(t, Configuration{Levels: "*=WRN", Format: "JSON"}, *conf)
+
+	os.Setenv("LOGXI_ENV", "dev")
+	conf, err = LoadConfigFile(filename, true)
+	assert.NoError(t, err)
+	assert.Equal(t, Configuration{Levels: "*=DBG", Format: "happy"}, *conf)
+
+	os.Setenv("LOGXI_ENV", "prod")
+	conf, err = LoadConfigFile(filename, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "*=ERR", conf.Levels)
+	assert.Equal(t, "JSON", conf.Format, "unset settings are inherited")
+
+	os.Setenv("LOGXI_ENV", "staging")
+	_, err = LoadConfigFile(filename, true)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dev, prod")
+}
+
+func TestFallbackToStderr(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	oldStdout := colorableStdout
+	defer setStdout(oldStdout)
+	defer func() { degradations.sinks = map[string]Degradation{} }()
+
+	dir := t.TempDir()
+	logFlags := &Flags{File: filepath.Join(dir, "missing", "app.log")}
+	assert.NoError(t, logFlags.Apply())
+	assert.NoError(t, logFlags.Apply())
+	assert.True(t, IsDegraded())
+	assert.Equal(t, stderrFallback, colorableStdout)
+	assert.Equal(t, FormatJSON, cfg().logxiFormat)
+	if assert.Len(t, Degraded(), 1) {
+		assert.Contains(t, Degraded()[0].Sink, "app.log")
+	}
+
+	rec := httptest.NewRecorder()
+	StatsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "true", rec.Header().Get("X-Logxi-Degraded"))
+	assert.Contains(t, rec.Body.String(), "DEGRADED file ")
+
+	// the sink recovers once it can be created
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "missing"), 0755))
+	assert.NoError(t, logFlags.Apply())
+	assert.False(t, IsDegraded())
+	assert.Len(t, Degraded(), 0)
+	if fw, ok := colorableStdout.(*FileWriter); ok {
+		fw.Close()
+	}
+}
+
+type flakyWriter struct {
+	err error
+}
+
+func (fw *flakyWriter) Write(p []byte) (int, error) {
+	if fw.err != nil {
+		return 0, fw.err
+	}
+	return len(p), nil
+}
+
+func TestStatus(t *testing.T) {
+	testResetEnv()
+	testIsolateRegistries(t)
+	bad := &flakyWriter{err: errors.New("connection refused")}
+	tee := NewTeeWriter().Add("stdout", ioutil.Discard).Add("collector", bad)
+	l := NewLogger3(NewConcurrentWriter(tee), "health", NewJSONFormatter("health"))
+	l.SetLevel(LevelAll)
+	defer Unregister("health")
+
+	assert.True(t, Healthy())
+	l.Info("hello")
+	status := Status()
+	assert.False(t, status.Healthy)
+	if assert.Len(t, status.Sinks, 2) {
+		assert.True(t, status.Sinks[0].Healthy())
+		assert.Equal(t, "collector", status.Sinks[1].Name)
+		assert.Equal(t, "connection refused", status.Sinks[1].LastError)
+	}
+
+	rec := httptest.NewRecorder()
+	HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	bad.err = nil
+	l.Info("recovered")
+	assert.True(t, Healthy())
+}
+
+func TestStatusSinks(t *testing.T) {
+	testResetEnv()
+	testIsolateRegistries(t)
+
+	// a collector which is down, written through a MultiSink
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	nw, err := NewNetWriter("tcp", addr, WithBackoff(time.Hour, time.Hour))
+	assert.NoError(t, err)
+	defer nw.Close()
+	l := NewLogger3(ioutil.Discard, "collected", NewMultiSink(Sink{Writer: nw, Formatter: NewJSONFormatter("collected")}))
+	l.SetLevel(LevelAll)
+	l.Info("dropped")
+
+	// a file behind a LevelSplitWriter
+	fw, err := NewFileWriter(filepath.Join(t.TempDir(), "errors.log"), PartitionNone)
+	assert.NoError(t, err)
+	NewLogger3(NewLevelSplitWriter(ioutil.Discard, fw, LevelWarn), "split", NewJSONFormatter("split"))
+
+	sinks := map[string]SinkStatus{}
+	for _, ss := range Status().Sinks {
+		sinks[ss.Name] = ss
+	}
+	collector := sinks["tcp://"+addr]
+	assert.False(t, collector.Connected)
+	assert.Equal(t, uint64(1), collector.Dropped)
+	assert.Equal(t, ErrNetDisconnected.Error(), collector.LastError)
+	file, ok := sinks["file "+fw.Path()]
+	assert.True(t, ok)
+	assert.True(t, file.Healthy())
+	assert.False(t, Healthy())
+
+	fw.Close()
+	for _, ss := range Status().Sinks {
+		if ss.Name == "file "+fw.Path() {
+			assert.False(t, ss.Healthy(), "closed")
+		}
+	}
+}
+
+type slowWriter struct {
+	delay time.Duration
+}
+
+func (sw slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(sw.delay)
+	return len(p), nil
+}
+
+func TestLatencyWriter(t *testing.T) {
+	testResetEnv()
+	testInternalLog.SetLevel(LevelWarn)
+	defer testInternalLog.SetLevel(LevelError)
+
+	lw := NewLatencyWriter("nfs", slowWriter{5 * time.Millisecond}, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		lw.Write([]byte("entry\n"))
+	}
+	stats := lw.Stats()
+	assert.Equal(t, int64(3), stats.Writes)
+	assert.Equal(t, int64(3), stats.SlowWrites)
+	assert.True(t, stats.Mean() >= 5*time.Millisecond)
+	assert.Equal(t, 1, strings.Count(testBuf.String(), "Slow writes to log sink"), "warnings are throttled")
+	assert.Contains(t, testBuf.String(), "sink: nfs")
+}
+
+func TestAsyncWriterBlocking(t *testing.T) {
+	var buf bytes.Buffer
+	gate := make(chan struct{})
+	sink := writerFunc(func(p []byte) (int, error) {
+		<-gate
+		return buf.Write(p)
+	})
+	aw := NewAsyncWriter(sink, 10)
+	api := NewLogger3(aw, "async.api", NewTextFormatter("async.api")).(*DefaultLogger)
+	audit := NewLogger3(aw, "async.audit", NewTextFormatter("async.audit")).(*DefaultLogger)
+	api.SetLevel(LevelAll)
+	audit.SetLevel(LevelAll)
+	audit.SetBlocking(true)
+
+	api.Info("queued")
+	assert.Equal(t, 0, buf.Len(), "async loggers don't wait")
+
+	done := make(chan struct{})
+	go func() {
+		audit.Info("durable")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("blocking logger returned before its entry was written")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(gate)
+	<-done
+	assert.Contains(t, buf.String(), "queued")
+	assert.Contains(t, buf.String(), "durable")
+	assert.True(t, strings.Index(buf.String(), "queued") < strings.Index(buf.String(), "durable"), "order is kept")
+
+	assert.NoError(t, aw.Close())
+	_, err := aw.Write([]byte("late"))
+	assert.Equal(t, ErrWriterClosed, err)
+}
+
+func TestFlushOnFatal(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fatal.log")
+	file, err := os.Create(filename)
+	assert.NoError(t, err)
+	aw := NewAsyncWriter(file, 100)
+	defer aw.Close()
+	l := NewLogger3(aw, "flushed", NewTextFormatter("flushed"))
+	defer Unregister("flushed")
+	l.SetLevel(LevelAll)
+
+	for i := 0; i < 50; i++ {
+		l.Info("queued", "i", i)
+	}
+	assert.Panics(t, func() {
+		l.Fatal("dying")
+	})
+	b, _ := ioutil.ReadFile(filename)
+	assert.Equal(t, 51, strings.Count(string(b), "\n"), "queued entries are written before panicking")
+
+	assert.Panics(t, func() {
+		defer Recover(l)
+		panic("boom")
+	})
+	b, _ = ioutil.ReadFile(filename)
+	assert.Contains(t, string(b), "Recovered from panic")
+	assert.Contains(t, string(b), "boom")
+}
+
+// funcWriter is comparable but holds a func, which panics as a map key
+type funcWriter struct {
+	io.Writer
+}
+
+func (fw funcWriter) Health() []SinkStatus {
+	return []SinkStatus{{Name: "func", Connected: true}}
+}
+
+func TestFuncWriterNotMapKey(t *testing.T) {
+	testResetEnv()
+	testIsolateRegistries(t)
+	var buf bytes.Buffer
+	l := NewLogger3(funcWriter{writerFunc(buf.Write)}, "func", NewJSONFormatter("func"))
+	l.SetLevel(LevelAll)
+
+	assert.NotPanics(t, func() {
+		l.Info("hello")
+		assert.NoError(t, Flush(time.Second))
+		assert.True(t, Healthy())
+		configArgs()
+		setConfigWriters(nil)
+	})
+	assert.Contains(t, buf.String(), "hello")
+}
+
+func TestAsyncWriteTime(t *testing.T) {
+	var buf bytes.Buffer
+	aw := NewAsyncWriter(&buf, 10)
+	aw.SetWriteTime(true)
+	l := NewLogger3(aw, "stamped", NewJSONFormatter("stamped"))
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+	assert.NoError(t, aw.Close())
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.NotEmpty(t, obj[KeyMap.Time])
+	assert.NotEmpty(t, obj[KeyMap.WriteTime])
+
+	now := time.Now()
+	stamped := string(stampWriteTime([]byte("_m: hi\n"), now))
+	assert.Equal(t, "_m: hi"+Separator+"_w"+AssignmentChar+now.Format(cfg().timeFormat)+"\n", stamped)
+}
+
+func TestUptime(t *testing.T) {
+	ProcessLogxiFormatEnv("uptime")
+	defer ProcessLogxiFormatEnv("")
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "uptime", NewJSONFormatter("uptime"))
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	up, ok := obj[KeyMap.Uptime].(float64)
+	assert.True(t, ok)
+	assert.True(t, up > 0)
+
+	buf.Reset()
+	l = NewLogger3(&buf, "uptime", NewTextFormatter("uptime"))
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+	assert.Contains(t, buf.String(), Separator+KeyMap.Uptime+AssignmentChar)
+}
+
+func TestFileWriterPartition(t *testing.T) {
+	dir := t.TempDir()
+	fw, err := NewFileWriter(filepath.Join(dir, "app.log"), PartitionDay)
+	assert.NoError(t, err)
+	_, err = fw.Write([]byte("hello\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, fw.Close())
+
+	now := time.Now()
+	expected := filepath.Join(dir, now.Format("2006"), now.Format("01"), now.Format("02"), "app.log")
+	assert.Equal(t, expected, fw.Path())
+	b, err := ioutil.ReadFile(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(b))
+	_, err = fw.Write([]byte("closed\n"))
+	assert.Equal(t, ErrWriterClosed, err)
+
+	ts := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, filepath.Join("2020", "W53"), PartitionWeek.dir(ts))
+	assert.Equal(t, "", PartitionNone.dir(ts))
+
+	_, err = ParsePartition("hourly")
+	assert.Error(t, err)
+}
+
+func TestFileWriterSync(t *testing.T) {
+	fw, err := NewFileWriter(filepath.Join(t.TempDir(), "audit.log"), PartitionNone)
+	assert.NoError(t, err)
+	defer fw.Close()
+	fw.SetSync(SyncInterval, time.Millisecond)
+	l := NewLogger3(fw, "audit", NewJSONFormatter("audit"))
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+	fw.mu.Lock()
+	assert.NotNil(t, fw.syncTimer, "interval sync is pending")
+	fw.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	fw.mu.Lock()
+	assert.Nil(t, fw.syncTimer)
+	fw.mu.Unlock()
+
+	fw.SetSync(SyncError, 0)
+	l.Error("failed")
+	b, err := ioutil.ReadFile(fw.Path())
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "failed")
+
+	policy, interval, err := ParseSyncPolicy("250ms")
+	assert.NoError(t, err)
+	assert.Equal(t, SyncInterval, policy)
+	assert.Equal(t, 250*time.Millisecond, interval)
+	_, _, err = ParseSyncPolicy("always")
+	assert.Error(t, err)
+}
+
+func TestChecksumWriter(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(NewChecksumWriter(&buf), "checked", NewJSONFormatter("checked"))
+	l.SetLevel(LevelAll)
+	l.Info("hello", "key", 1)
+	line := buf.Bytes()
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(line, &obj))
+	assert.Len(t, obj[KeyMap.Checksum], 8)
+	assert.NoError(t, VerifyChecksum(line))
+	corrupt := bytes.Replace(line, []byte("hello"), []byte("jello"), 1)
+	assert.Error(t, VerifyChecksum(corrupt))
+	assert.Equal(t, ErrNoChecksum, VerifyChecksum(line[:len(line)/2]))
+
+	buf.Reset()
+	l = NewLogger3(NewChecksumWriter(&buf), "checked", NewTextFormatter("checked"))
+	l.SetLevel(LevelAll)
+	l.Info("hello", "key", 1)
+	assert.NoError(t, VerifyChecksum(buf.Bytes()))
+}
+
+func TestSchemaVersion(t *testing.T) {
+	ProcessLogxiFormatEnv("schema")
+	defer ProcessLogxiFormatEnv("")
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "schema", NewJSONFormatter("schema"))
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, float64(SchemaVersion), obj[KeyMap.Version])
+
+	buf.Reset()
+	l = NewLogger3(&buf, "schema", NewTextFormatter("schema"))
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+	assert.Contains(t, buf.String(), Separator+KeyMap.Version+AssignmentChar+strconv.Itoa(SchemaVersion))
+}
+
+func TestJSONFormatterEscaping(t *testing.T) {
+	ProcessLogxiFormatEnv("json")
+	defer ProcessLogxiFormatEnv("")
+	assert.Equal(t, FormatJSON, cfg().logxiFormat)
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "escaped", NewJSONFormatter("escaped"))
+	l.SetLevel(LevelAll)
+	l.Info("a \"quoted\"\nmessage", "we\"ird", "tab\there", "bytes", []byte{0xff}, "map", map[string]string{"k\"": "v"})
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"), "one object per line")
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "a \"quoted\"\nmessage", obj[KeyMap.Message])
+	assert.Equal(t, "tab\there", obj["we\"ird"])
+	assert.Equal(t, map[string]interface{}{"k\"": "v"}, obj["map"])
+}
+
+func TestCanonicalHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "canonical", NewJSONFormatter("canonical"))
+	l.SetLevel(LevelAll)
+
+	handler := CanonicalHandler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cl := CanonicalFromContext(r.Context())
+		cl.Add("user", "alice")
+		stop := cl.Time("db")
+		stop()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"), "one entry per request")
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "WRN", obj[KeyMap.Level])
+	assert.Equal(t, "GET", obj["method"])
+	assert.Equal(t, "/users/1", obj["path"])
+	assert.Equal(t, float64(404), obj["status"])
+	assert.Equal(t, "alice", obj["user"])
+	assert.Contains(t, obj, "db_ms")
+	assert.Contains(t, obj, "duration_ms")
+
+	// no canonical line in context is harmless
+	CanonicalFromContext(context.Background()).Add("ignored", true)
+}
+
+func TestSetDefaultAndWith(t *testing.T) {
+	old := DefaultLog
+	defer SetDefault(old)
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "default", NewJSONFormatter("default"))
+	l.SetLevel(LevelAll)
+	SetDefault(l)
+
+	Info("plain")
+	reqLog := With("requestID", "r1")
+	reqLog.Info("started", "step", 1)
+	With("ignored", true)
+	assert.Equal(t, "default", reqLog.(Namer).Name())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &obj))
+	assert.Equal(t, "r1", obj["requestID"])
+	assert.Equal(t, float64(1), obj["step"])
+
+	// Warn and Error return the error like Logger's
+	err := errors.New("card declined")
+	assert.Equal(t, err, Warn("retrying", "err", err))
+	assert.Equal(t, err, Error("charge failed", "err", err))
+	assert.Equal(t, "charge failed", Error("charge failed").Error())
+
+	SetDefault(nil)
+	assert.Equal(t, NullLog, DefaultLog)
+}
+
+func TestLogfmt(t *testing.T) {
+	ProcessLogxiFormatEnv("logfmt")
+	defer ProcessLogxiFormatEnv("")
+	assert.Equal(t, FormatText, cfg().logxiFormat)
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "logfmt", NewTextFormatter("logfmt"))
+	l.SetLevel(LevelAll)
+	l.Info("hello world", "plain", "v", "spaced", "a b", "eq", "x=y", "quoted", `say "hi"`, "multi", "a\nb", "empty", "", "bad key", 1)
+	line := buf.String()
+	assert.Equal(t, 1, strings.Count(line, "\n"))
+	assert.Contains(t, line, ` _m="hello world"`)
+	assert.Contains(t, line, ` plain=v`)
+	assert.Contains(t, line, ` spaced="a b"`)
+	assert.Contains(t, line, ` eq="x=y"`)
+	assert.Contains(t, line, ` quoted="say \"hi\""`)
+	assert.Contains(t, line, ` multi="a\nb"`)
+	assert.Contains(t, line, ` empty=""`)
+	assert.Contains(t, line, ` bad_key=1`)
+
+	buf.Reset()
+	l.Error("failed", "err", errors.New("boom"))
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"), "call stack is quoted")
+	assert.Contains(t, buf.String(), " err=boom _f=")
+}
+
+type upperFormatter struct{ name string }
+
+func (uf *upperFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	writer.Write([]byte(strings.ToUpper(uf.name+" "+msg) + "\n"))
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	defer func() {
+		delete(formatterCreators, "upper")
+		ProcessLogxiFormatEnv("")
+	}()
+
+	// selected before it is registered, as happens when the environment
+	// is processed in init
+	ProcessLogxiFormatEnv("upper")
+	assert.NotEqual(t, "upper", cfg().logxiFormat)
+	RegisterFormatter("upper", func(name string) Formatter {
+		return &upperFormatter{name: name}
+	})
+	assert.Equal(t, "upper", cfg().logxiFormat)
+
+	var buf bytes.Buffer
+	l := NewLogger(&buf, "custom")
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+	assert.Equal(t, "CUSTOM HELLO\n", buf.String())
+}
+
+func TestStructuredStack(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "stack", NewJSONFormatter("stack"))
+	l.SetLevel(LevelAll)
+	l.Error("failed", "err", errors.New("\x1b[31mred\x1b[0m failure"))
+	assert.NotContains(t, buf.String(), "\\u001b")
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "red failure", obj["err"])
+	frames, ok := obj[KeyMap.CallStack].([]interface{})
+	if assert.True(t, ok, "call stack is an array of frames") && assert.NotEmpty(t, frames) {
+		frame := frames[0].(map[string]interface{})
+		assert.Contains(t, frame, "func")
+		assert.Contains(t, frame, "file")
+		assert.Contains(t, frame, "line")
+	}
+	assert.Contains(t, framesString(obj[KeyMap.CallStack]), "TestStructuredStack()")
+
+	buf.Reset()
+	l = NewLogger3(&buf, "stack", NewTextFormatter("stack"))
+	l.SetLevel(LevelAll)
+	l.Error("failed", "err", errors.New("\x1b[31mred\x1b[0m failure"))
+	assert.NotContains(t, buf.String(), "\x1b")
+}
+
+func logThroughWrapper(l Logger, msg string, args ...interface{}) {
+	l.Error(msg, args...)
+}
+
+func TestFrameFilter(t *testing.T) {
+	SetFrameFilter(func(f Frame) bool {
+		return !strings.HasSuffix(f.Function, ".logThroughWrapper")
+	})
+	defer SetFrameFilter(nil)
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "filtered", NewJSONFormatter("filtered"))
+	l.SetLevel(LevelAll)
+	logThroughWrapper(l, "failed", "err", errors.New("boom"))
+	assert.NotContains(t, buf.String(), "logThroughWrapper")
+	assert.Contains(t, buf.String(), "TestFrameFilter")
+
+	buf.Reset()
+	l = NewLogger3(&buf, "filtered", NewTextFormatter("filtered"))
+	l.SetLevel(LevelAll)
+	logThroughWrapper(l, "failed", "err", errors.New("boom"))
+	assert.NotContains(t, buf.String(), "logThroughWrapper")
+	assert.Contains(t, buf.String(), "TestFrameFilter")
+}
+
+type entryRecorder struct {
+	entries []Entry
+}
+
+func (er *entryRecorder) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	panic("FormatEntry is preferred")
+}
+
+func (er *entryRecorder) FormatEntry(writer io.Writer, e *Entry) {
+	copied := *e
+	copied.Fields = append([]interface{}(nil), e.Fields...)
+	copied.Stack = append([]Frame(nil), e.Stack...)
+	er.entries = append(er.entries, copied)
+}
+
+func TestEntryFormatter(t *testing.T) {
+	er := &entryRecorder{}
+	l := NewLogger3(ioutil.Discard, "entries", er)
+	l.SetLevel(LevelAll)
+	newFieldLogger(l, []interface{}{"bound", 1}).Info("hello", "key", "value")
+	l.Error("failed", "err", errors.New("boom"))
+
+	if assert.Len(t, er.entries, 2) {
+		info := er.entries[0]
+		assert.Equal(t, "entries", info.Name)
+		assert.Equal(t, LevelInfo, info.Level)
+		assert.Equal(t, "hello", info.Msg)
+		assert.Equal(t, []interface{}{"bound", 1, "key", "value"}, info.Fields)
+		assert.False(t, info.Time.IsZero())
+		assert.Nil(t, info.Caller)
+
+		failed := er.entries[1]
+		assert.NotNil(t, failed.Caller)
+		assert.NotEmpty(t, failed.Stack)
+	}
+}
+
+func TestTraceLevel(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=DBG,proto=TRC")
+	processEnv()
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "proto", NewTextFormatter("proto"))
+	assert.True(t, l.IsTrace())
+	l.Trace("frame", "bytes", 12)
+	assert.Contains(t, buf.String(), KeyMap.Level+AssignmentChar+"TRC")
+	assert.Contains(t, buf.String(), "frame")
+
+	other := NewLogger3(&buf, "other", NewTextFormatter("other"))
+	assert.True(t, other.IsDebug())
+	assert.False(t, other.IsTrace())
+}
+
+func TestCallerAndStackLevels(t *testing.T) {
+	defer ProcessLogxiFormatEnv("")
+
+	ProcessLogxiFormatEnv("JSON")
+	e := newEntry(LevelWarn, "caller", "warn", nil, stackOptions{})
+	assert.NotNil(t, e.Caller, "warnings capture the caller by default")
+	assert.Empty(t, e.Stack)
+	e.release()
+
+	ProcessLogxiFormatEnv("JSON,caller=ERR,stack=FTL")
+	e = newEntry(LevelWarn, "caller", "warn", nil, stackOptions{})
+	assert.Nil(t, e.Caller)
+	e.release()
+	e = newEntry(LevelError, "caller", "error", nil, stackOptions{})
+	assert.NotNil(t, e.Caller)
+	assert.Empty(t, e.Stack)
+	e.release()
+
+	ProcessLogxiFormatEnv("JSON,caller=DBG")
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "caller", NewJSONFormatter("caller"))
+	l.SetLevel(LevelAll)
+	l.Info("hello")
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Contains(t, obj[KeyMap.Caller], ".go:")
+}
+
+type fakeCapturer struct{}
+
+func (fakeCapturer) Capture(skip, depth int) []Frame {
+	return []Frame{
+		{Function: "app.logWrapper", File: "/app/logging.go", Line: 10},
+		{Function: "app.handler", File: "/app/handler.go", Line: 20},
+		{Function: "app.main", File: "/app/main.go", Line: 30},
+	}
+}
+
+func TestStackCapturer(t *testing.T) {
+	SetStackCapturer(fakeCapturer{})
+	defer SetStackCapturer(nil)
+
+	er := &entryRecorder{}
+	l := NewLogger3(ioutil.Discard, "captured", er)
+	l.SetLevel(LevelAll)
+	l.(*DefaultLogger).SetStackCapture(1, 1)
+	l.Error("failed")
+	if assert.Len(t, er.entries, 1) {
+		assert.Equal(t, []Frame{{Function: "app.handler", File: "/app/handler.go", Line: 20}}, er.entries[0].Stack)
+		assert.Equal(t, "/app/handler.go", er.entries[0].Caller.File)
+	}
+
+	SetStackCapturer(nil)
+	frames := CallersCapturer{}.Capture(0, 1)
+	if assert.Len(t, frames, 1) {
+		assert.Contains(t, frames[0].Function, "TestStackCapturer")
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	testResetEnv()
+	defer func() {
+		delete(LevelMap, LevelNotice)
+		delete(LevelAtoi, "NTC")
+		delete(LevelAtoi, "ntc")
+		testResetEnv()
+	}()
+	os.Setenv("LOGXI", "*=NTC,quiet=OFF")
+	os.Setenv("LOGXI_COLORS", "NTC=cyan")
+	processEnv()
+	RegisterLevel(LevelNotice, "NTC")
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "notice", NewTextFormatter("notice"))
+	assert.True(t, l.IsWarn())
+	assert.False(t, l.IsInfo())
+	l.Log(LevelNotice, "disk usage high", []interface{}{"pct", 85})
+	assert.Contains(t, buf.String(), KeyMap.Level+AssignmentChar+"NTC")
+
+	buf.Reset()
+	l = NewLogger3(&buf, "notice", NewJSONFormatter("notice"))
+	l.Log(LevelNotice, "disk usage high", nil)
+	assert.Contains(t, buf.String(), `"NTC"`)
+
+	buf.Reset()
+	l = NewLogger3(&buf, "notice", NewHappyDevFormatter("notice"))
+	l.Log(LevelNotice, "disk usage high", nil)
+	assert.Contains(t, buf.String(), "disk usage high")
+	assert.NotEmpty(t, cfg().theme.Levels[LevelNotice])
+
+	assert.Equal(t, NullLog, NewLogger3(&buf, "quiet", NewTextFormatter("quiet")))
+	assert.Panics(t, func() { RegisterLevel(LevelOff, "NONE") })
+}
+
+func TestSetLevelByName(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=ERR")
+	processEnv()
+
+	var buf bytes.Buffer
+	w := NewConcurrentWriter(&buf)
+	models := NewLogger3(w, "models", NewTextFormatter("models"))
+	api := NewLogger3(w, "api", NewTextFormatter("api"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			models.Debug("query")
+		}
+	}()
+	SetLevelByName("mod*", LevelDebug)
+	wg.Wait()
+
+	assert.True(t, models.IsDebug())
+	assert.False(t, api.IsWarn())
+	assert.True(t, NewLogger3(w, "modules", NewTextFormatter("modules")).IsDebug(), "later loggers use the level")
+
+	SetLevelByName("models", LevelOff)
+	buf.Reset()
+	models.Error("silenced")
+	assert.Empty(t, buf.String())
+}
+
+type callersErr struct {
+	pcs []uintptr
+}
+
+func newCallersErr() *callersErr {
+	pcs := make([]uintptr, 32)
+	return &callersErr{pcs: pcs[:runtime.Callers(1, pcs)]}
+}
+
+func (e *callersErr) Error() string      { return "disk full" }
+func (e *callersErr) Callers() []uintptr { return e.pcs }
+
+func TestMultiError(t *testing.T) {
+	err := errors.Join(errors.New("timeout"), errors.Join(newCallersErr()))
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "multi", NewJSONFormatter("multi"))
+	l.Error("save failed", "err", err)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	errs, ok := obj["err"].([]interface{})
+	assert.True(t, ok, "multi-errors are arrays")
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "timeout", errs[0].(map[string]interface{})["msg"])
+	assert.Nil(t, errs[0].(map[string]interface{})[KeyMap.CallStack])
+	assert.Equal(t, "disk full", errs[1].(map[string]interface{})["msg"])
+	assert.Contains(t, fmt.Sprint(errs[1].(map[string]interface{})[KeyMap.CallStack]), "newCallersErr")
+
+	buf.Reset()
+	l = NewLogger3(&buf, "multi", NewTextFormatter("multi"))
+	l.Error("save failed", "err", err)
+	assert.Contains(t, buf.String(), "err.0"+AssignmentChar+"timeout")
+	assert.Contains(t, buf.String(), "err.1"+AssignmentChar+"disk full")
+	assert.Contains(t, buf.String(), "newCallersErr")
+
+	buf.Reset()
+	l = NewLogger3(&buf, "multi", NewHappyDevFormatter("multi"))
+	l.Error("save failed", "err", err)
+	assert.Contains(t, buf.String(), "timeout")
+	assert.Contains(t, buf.String(), "err.1")
+}
+
+type queryError struct {
+	query string
+	err   error
+}
+
+func (e *queryError) Error() string { return "query " + e.query + ": " + e.err.Error() }
+func (e *queryError) Unwrap() error { return e.err }
+
+func TestErrChain(t *testing.T) {
+	err := fmt.Errorf("api: %w", fmt.Errorf("timeout: %w", context.DeadlineExceeded))
+	assert.Equal(t, "api: timeout: context deadline exceeded", errChain(err))
+	assert.Equal(t, "", errChain(errors.New("plain")))
+
+	err = fmt.Errorf("load user: %w", &queryError{query: "id=42", err: errors.New("no rows")})
+	assert.Equal(t, "load user: *log.queryError: no rows", errChain(err))
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "chain", NewJSONFormatter("chain"))
+	l.Error("failed", "err", err)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "load user: *log.queryError: no rows", obj["err_chain"])
+}
+
+func TestSignalLevels(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=ERR,proto=TRC")
+	processEnv()
+
+	var buf bytes.Buffer
+	api := NewLogger3(&buf, "api", NewTextFormatter("api"))
+	proto := NewLogger3(&buf, "proto", NewTextFormatter("proto"))
+
+	raiseLevels(LevelDebug)
+	assert.True(t, api.IsDebug())
+	assert.False(t, api.IsTrace())
+	assert.True(t, proto.IsTrace(), "more verbose loggers are not lowered")
+
+	restoreLevels()
+	assert.False(t, api.IsWarn())
+	assert.True(t, proto.IsTrace())
+}
+
+func TestGroup(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "group", NewJSONFormatter("group"))
+	l.SetLevel(LevelAll)
+	l.Info("query", Group("db", "host", "pg1", Group("conn", "pool", 4)), "rows", 12)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	db, ok := obj["db"].(map[string]interface{})
+	assert.True(t, ok, "groups are nested objects")
+	assert.Equal(t, "pg1", db["host"])
+	assert.Equal(t, float64(4), db["conn"].(map[string]interface{})["pool"])
+	assert.Equal(t, float64(12), obj["rows"])
+
+	buf.Reset()
+	l = NewLogger3(&buf, "group", NewTextFormatter("group"))
+	l.SetLevel(LevelAll)
+	l.Info("query", Group("db", "host", "pg1", Group("conn", "pool", 4)))
+	assert.Contains(t, buf.String(), "db.host"+AssignmentChar+"pg1")
+	assert.Contains(t, buf.String(), "db.conn.pool"+AssignmentChar+"4")
+
+	buf.Reset()
+	defer SetDefault(DefaultLog)
+	SetDefault(l)
+	err := errors.New("timeout")
+	assert.Equal(t, err, WithGroup("db").Error("query failed", "err", err))
+	assert.Contains(t, buf.String(), "db.err"+AssignmentChar+"timeout")
+
+	buf.Reset()
+	l = NewLogger3(&buf, "group", NewHappyDevFormatter("group"))
+	l.SetLevel(LevelAll)
+	l.Info("query", Group("db", "host", "pg1"))
+	assert.Contains(t, buf.String(), "db.host")
+}
+
+func TestAdminHandler(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=ERR")
+	processEnv()
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "admin", NewTextFormatter("admin"))
+	h := AdminHandler()
+
+	req := httptest.NewRequest("PUT", "/?name=adm*&level=DBG&format=JSON", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var configs []LoggerConfig
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &configs))
+	assert.Contains(t, configs, LoggerConfig{Name: "admin", Level: "DBG", Format: FormatJSON})
+
+	assert.True(t, l.IsDebug())
+	l.Debug("hello")
+	assert.True(t, strings.HasPrefix(buf.String(), "{"), "format changed to JSON")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/?name=admin&level=LOUD", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("DELETE", "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAdminHandlerWhileLogging(t *testing.T) {
+	l := NewLogger3(ioutil.Discard, "adminrace", NewTextFormatter("adminrace"))
+	h := AdminHandler()
+
+	// run with -race
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			format := []string{"JSON", "text", "happy"}[i%3]
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("PUT", "/?name=adminrace&format="+format, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		l.Error("formatting", "i", i)
+	}
+	<-done
+}
+
+func TestArrays(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "array", NewJSONFormatter("array"))
+	l.SetLevel(LevelAll)
+	l.Info("listening", Ints("ports", []int{80, 443}), Strings("tags", nil), "host", "edge")
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, []interface{}{float64(80), float64(443)}, obj["ports"])
+	assert.Equal(t, []interface{}{}, obj["tags"])
+	assert.Equal(t, "edge", obj["host"])
+
+	buf.Reset()
+	l = NewLogger3(&buf, "array", NewTextFormatter("array"))
+	l.SetLevel(LevelAll)
+	l.Info("listening", Ints("ports", []int{80, 443}), Strings("tags", []string{"a b"}))
+	assert.Contains(t, buf.String(), "ports"+AssignmentChar+"[80,443]")
+	assert.Contains(t, buf.String(), `tags`+AssignmentChar+`["a b"]`)
+
+	// floats are rendered like scalar floats, NaN and infinities included
+	samples := []float64{1.5, math.NaN(), math.Inf(1), math.Inf(-1)}
+	buf.Reset()
+	jf := NewJSONFormatter("array")
+	l = NewLogger3(&buf, "array", jf)
+	l.SetLevel(LevelAll)
+	l.Info("sampled", Float64s("samples", samples))
+	obj = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, []interface{}{1.5, nil, nil, nil}, obj["samples"])
+
+	buf.Reset()
+	jf.SetFloatFormat(FloatFormat{Precision: 2, NaNString: true})
+	l.Info("sampled", Float64s("samples", samples))
+	assert.Contains(t, buf.String(), `"samples":[1.50,"NaN","+Inf","-Inf"]`)
+
+	buf.Reset()
+	l = NewLogger3(&buf, "array", NewTextFormatter("array"))
+	l.SetLevel(LevelAll)
+	l.Info("sampled", Float64s("samples", samples))
+	assert.Contains(t, buf.String(), "samples"+AssignmentChar+"[1.5,null,null,null]")
+	assert.Equal(t, "[1.5,null,null,null]", Float64s("samples", samples).String())
+}
+
+func TestLOGXIGlobs(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=INF,-noisy*,worker-??=DBG,api.*.db=TRC,api.*=WRN,noisy-core=ERR")
+	processEnv()
+
+	assert.Equal(t, LevelInfo, getLogLevel("models"))
+	assert.Equal(t, LevelOff, getLogLevel("noisy-cache"))
+	assert.Equal(t, LevelError, getLogLevel("noisy-core"), "exact names win over exclusions")
+	assert.Equal(t, LevelDebug, getLogLevel("worker-07"))
+	assert.Equal(t, LevelInfo, getLogLevel("worker-107"))
+	assert.Equal(t, LevelTrace, getLogLevel("api.users.db"), "more specific patterns win")
+	assert.Equal(t, LevelWarn, getLogLevel("api.users"))
+
+	assert.True(t, matchName("a*b*c", "aXXbYYc"))
+	assert.False(t, matchName("a*b*c", "aXXbYY"))
+	assert.True(t, matchName("a?c", "abc"))
+}
+
+func TestFloatFormat(t *testing.T) {
+	defer ProcessLogxiFormatEnv("")
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "floats", NewJSONFormatter("floats"))
+	l.SetLevel(LevelAll)
+	l.Info("stats", "nan", math.NaN(), "big", 1e21, "ratio", 0.5)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj), "NaN is logged as valid JSON")
+	assert.Nil(t, obj["nan"])
+	assert.Contains(t, buf.String(), `"big":1e+21`)
+
+	ProcessLogxiFormatEnv("JSON,precision=2,fixed,nan=string")
+	buf.Reset()
+	l = NewLogger3(&buf, "floats", NewJSONFormatter("floats"))
+	l.SetLevel(LevelAll)
+	l.Info("stats", "nan", math.Inf(-1), "big", 1e21, "ratio", float32(0.5))
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "-Inf", obj["nan"])
+	assert.Contains(t, buf.String(), `"big":1000000000000000000000.00`)
+	assert.Contains(t, buf.String(), `"ratio":0.50`)
+
+	buf.Reset()
+	tf := NewTextFormatter("floats")
+	tf.SetFloatFormat(DefaultFloatFormat)
+	l = NewLogger3(&buf, "floats", tf)
+	l.SetLevel(LevelAll)
+	l.Info("stats", "nan", math.NaN(), "ratio", 0.25)
+	assert.Contains(t, buf.String(), "nan"+AssignmentChar+"null")
+	assert.Contains(t, buf.String(), "ratio"+AssignmentChar+"0.25")
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	dir, err := ioutil.TempDir("", "logxi-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer setConfigWriters(nil)
+
+	logfile := filepath.Join(dir, "models.log")
+	config := filepath.Join(dir, "logxi.yaml")
+	yaml := "# logxi\nlevels: \"*=ERR,models=DBG\"\nformat: JSON\nwriters:\n  models: " + logfile + "\n"
+	assert.NoError(t, ioutil.WriteFile(config, []byte(yaml), 0644))
+
+	stop, err := WatchConfig(config, 10*time.Millisecond)
+	assert.NoError(t, err)
+	defer stop()
+
+	models := New("models")
+	assert.True(t, models.IsDebug())
+	models.Debug("query")
+	b, _ := ioutil.ReadFile(logfile)
+	assert.Contains(t, string(b), `"_m":"query"`)
+
+	// ensure the modification time changes
+	time.Sleep(20 * time.Millisecond)
+	yaml = "levels: '*=ERR,models=WRN'\nformat: JSON\n"
+	assert.NoError(t, ioutil.WriteFile(config, []byte(yaml), 0644))
+	for i := 0; i < 100 && models.IsDebug(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(t, models.IsDebug(), "reloaded")
+	assert.True(t, models.IsWarn())
+
+	_, err = parseYAML([]byte("levels:\n  - a\n"))
+	assert.Error(t, err)
+}
+
+func TestLoadConfigWhileLogging(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	dir := t.TempDir()
+	defer setConfigWriters(nil)
+
+	config := filepath.Join(dir, "logxi.yaml")
+	formats := []string{"JSON", "logfmt", "LTSV", "happy,maxcol=40"}
+	l := New("reloaded")
+	l.SetLevel(LevelAll)
+
+	// run with -race
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			yaml := "levels: '*=ERR'\nformat: " + formats[i%len(formats)] + "\nwriters:\n  reloaded: " + filepath.Join(dir, "reloaded.log") + "\n"
+			assert.NoError(t, ioutil.WriteFile(config, []byte(yaml), 0644))
+			assert.NoError(t, LoadConfig(config))
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		l.Error("reloading", "i", i)
+		if i%10 == 0 {
+			// read the configuration being reloaded
+			configArgs()
+			epochArgs()
+		}
+	}
+	<-done
+}
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]int{
+		"DBG": LevelDebug, "dbg": LevelDebug, "Debug": LevelDebug, "DEBUG": LevelDebug,
+		"7": LevelDebug, "10": LevelTrace, "Off": LevelOff, "-1000": LevelOff, "all": LevelAll,
+	}
+	for s, expected := range cases {
+		level, ok := ParseLevel(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, expected, level, s)
+	}
+	for _, s := range []string{"", "0", "LOUD", "7x", "-"} {
+		_, ok := ParseLevel(s)
+		assert.False(t, ok, s)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		ParseLevel("Debug")
+		ParseLevel("LOUD")
+	})
+	assert.Equal(t, float64(0), allocs)
+
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=Warn,proto=10")
+	processEnv()
+	assert.Equal(t, LevelWarn, getLogLevel("api"))
+	assert.Equal(t, LevelTrace, getLogLevel("proto"))
+}
+
+func TestNewOptions(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=ERR,-quiet")
+	processEnv()
+
+	var buf bytes.Buffer
+	l := New("options",
+		WithWriter(&buf),
+		WithLevel(LevelDebug),
+		WithFormatter(NewJSONFormatter("options")),
+		WithFields("region", "eu"))
+	assert.True(t, l.IsDebug())
+	l.Debug("hello")
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "eu", obj["region"])
+
+	assert.Equal(t, NullLog, New("quiet", WithWriter(&buf)))
+	assert.True(t, New("quiet", WithWriter(&buf), WithLevel(LevelInfo)).IsInfo(), "explicit levels win")
+}
+
+func TestOffAndAllLevels(t *testing.T) {
+	testResetEnv()
+	defer testResetEnv()
+	os.Setenv("LOGXI", "*=OFF,capture=ALL,__logxi=OFF")
+	processEnv()
+
+	var buf bytes.Buffer
+	assert.Equal(t, NullLog, NewLogger3(&buf, "library", NewTextFormatter("library")))
+	l := NewLogger3(&buf, "capture", NewTextFormatter("capture"))
+	assert.True(t, l.IsTrace())
+	l.Log(LevelAll-1, "very verbose", nil)
+	assert.Contains(t, buf.String(), "very verbose")
+
+	buf.Reset()
+	l.SetLevel(LevelOff)
+	l.Error("silenced")
+	assert.Empty(t, buf.String(), "OFF suppresses errors too")
+
+	assert.Equal(t, "OFF", LevelMap[LevelOff])
+	assert.Equal(t, "ALL", LevelMap[LevelAll])
+
+	InternalLog.SetLevel(internalLogLevel())
+	assert.False(t, InternalLog.IsWarn())
+	testBuf.Reset()
+	InternalLog.Error("silenced")
+	assert.Empty(t, testBuf.String())
+}
+
+func TestQuiet(t *testing.T) {
+	SetQuiet(true)
+	defer SetQuiet(false)
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "quiet", NewTextFormatter("quiet"))
+	l.SetLevel(LevelAll)
+	l.Error("hidden")
+	l.Warn("hidden")
+	l.Info("hidden")
+	assert.Empty(t, buf.String())
+
+	assert.Panics(t, func() {
+		l.Fatal("crashed")
+	})
+	assert.Contains(t, buf.String(), "crashed")
+}
+
+func TestLevelWriters(t *testing.T) {
+	old := colorableStdout
+	defer setStdout(old)
+
+	var stdout, stderr bytes.Buffer
+	SetLevelWriters(&stdout, &stderr, LevelWarn)
+	l := New("split", WithLevel(LevelAll), WithFormatter(NewTextFormatter("split")))
+	l.Info("starting")
+	l.Warn("slow")
+	l.Error("failed")
+	assert.Contains(t, stdout.String(), "starting")
+	assert.NotContains(t, stdout.String(), "slow")
+	assert.Contains(t, stderr.String(), "slow")
+	assert.Contains(t, stderr.String(), "failed")
+	assert.NotContains(t, stderr.String(), "starting")
+}
+
+// recordingLogger stands in for a logger of another copy of logxi
+type recordingLogger struct {
+	NullLogger
+	msgs []string
+	args [][]interface{}
+}
+
+func (rl *recordingLogger) Log(level int, msg string, args []interface{}) {
+	rl.msgs = append(rl.msgs, msg)
+	rl.args = append(rl.args, args)
+}
+
+func (rl *recordingLogger) IsDebug() bool {
+	return true
+}
+
+func TestAdopt(t *testing.T) {
+	assert.Equal(t, "", namespaceOf("github.com/mgutz/logxi/v1"))
+	assert.Equal(t, "EXAMPLE_COM_APP", namespaceOf("example.com/app/vendor/github.com/mgutz/logxi/v1"))
+	assert.Equal(t, "github.com/mgutz/logxi/v1", ModulePath())
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "vendored", NewTextFormatter("vendored"))
+	l.SetLevel(LevelError)
+
+	rl := &recordingLogger{}
+	Adopt(rl)
+	assert.True(t, l.IsDebug())
+	l.Debug("forwarded", "k", 1)
+	Adopt(nil)
+
+	assert.False(t, l.IsDebug())
+	l.Error("local")
+	assert.Equal(t, []string{"forwarded"}, rl.msgs)
+	assert.Equal(t, []interface{}{"logger", "vendored", "k", 1}, rl.args[0])
+	assert.NotContains(t, buf.String(), "forwarded")
+	assert.Contains(t, buf.String(), "local")
+
+	assert.Panics(t, func() {
+		Adopt(l)
+	})
+}
+
+func TestMultiSink(t *testing.T) {
+	var console, file bytes.Buffer
+	sink := NewMultiSink(
+		Sink{Writer: &console, Formatter: NewTextFormatter("multi"), Level: LevelWarn},
+		Sink{Writer: &file, Formatter: NewJSONFormatter("multi"), Level: LevelDebug},
+	)
+	assert.Equal(t, LevelDebug, sink.Level())
+
+	l := New("multi", WithFormatter(sink), WithLevel(sink.Level()))
+	l.Debug("connecting", "host", "db")
+	l.Warn("slow")
+
+	assert.NotContains(t, console.String(), "connecting")
+	assert.Contains(t, console.String(), "slow")
+	lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+	assert.Len(t, lines, 2)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &obj))
+	assert.Equal(t, "connecting", obj[KeyMap.Message])
+	assert.Equal(t, "db", obj["host"])
+
+	assert.Equal(t, LevelAll, NewMultiSink(Sink{Writer: &console, Formatter: NewTextFormatter("multi")}).Level())
+}
+
+// levelRecorder records the levels of entries written with WriteLevel
+type levelRecorder struct {
+	levels []int
+}
+
+func (lr *levelRecorder) Write(p []byte) (int, error) {
+	return lr.WriteLevel(0, p)
+}
+
+func (lr *levelRecorder) WriteLevel(level int, p []byte) (int, error) {
+	lr.levels = append(lr.levels, level)
+	return len(p), nil
+}
+
+func TestAsyncWriterLevels(t *testing.T) {
+	lr := &levelRecorder{}
+	aw := NewAsyncWriter(lr, 10)
+	l := NewLogger3(aw, "async.levels", NewTextFormatter("async.levels"))
+	defer Unregister("async.levels")
+	l.SetLevel(LevelAll)
+	l.Info("queued")
+	l.Error("failed")
+	assert.NoError(t, aw.Flush(time.Second))
+	assert.Equal(t, []int{LevelInfo, LevelError}, lr.levels)
+	assert.NoError(t, aw.Close())
+}
+
+func TestSelfTest(t *testing.T) {
+	old := colorableStdout
+	defer setStdout(old)
+	var buf bytes.Buffer
+	setStdout(&buf)
+	os.Setenv("LOGXI", "*=WRN")
+	ProcessEnv(readFromEnviron())
+	defer func() {
+		os.Setenv("LOGXI", "")
+		ProcessEnv(readFromEnviron())
+	}()
+
+	results, err := SelfTest("selftest")
+	assert.NoError(t, err)
+	assert.Len(t, results, 6)
+	assert.Equal(t, "FTL", results[0].Label)
+	assert.True(t, results[0].Enabled)
+	assert.Equal(t, LevelTrace, results[5].Level)
+	assert.False(t, results[5].Enabled)
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+		assert.True(t, r.Bytes > 0)
+	}
+	assert.Equal(t, 6, strings.Count(buf.String(), "logxi self-test"))
+}
+
+func TestRotatingFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	rw, err := NewRotatingFileWriter(filename, RotateOptions{MaxSize: 10, MaxBackups: 2})
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err := rw.Write([]byte("entry 123\n"))
+		assert.NoError(t, err)
+	}
+	backups := rw.Backups()
+	assert.Len(t, backups, 2, "old backups are removed")
+	b, _ := ioutil.ReadFile(filename)
+	assert.Equal(t, "entry 123\n", string(b))
+	assert.NoError(t, rw.Close())
+	_, err = rw.Write([]byte("late"))
+	assert.Equal(t, ErrWriterClosed, err)
+
+	// daily rotation with compression
+	filename = filepath.Join(dir, "daily.log")
+	rw, err = NewRotatingFileWriter(filename, RotateOptions{Daily: true, Compress: true})
+	assert.NoError(t, err)
+	day := time.Date(2024, 5, 17, 23, 59, 0, 0, time.Local)
+	rw.now = func() time.Time { return day }
+	rw.opened = day
+	rw.Write([]byte("friday\n"))
+	day = day.Add(2 * time.Minute)
+	rw.Write([]byte("saturday\n"))
+	assert.NoError(t, rw.Close())
+
+	backups = rw.Backups()
+	assert.Len(t, backups, 1)
+	assert.True(t, strings.HasSuffix(backups[0], "daily-2024-05-18T00-01-00.000.log.gz"))
+	f, err := os.Open(backups[0])
+	assert.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	b, _ = ioutil.ReadAll(gz)
+	assert.Equal(t, "friday\n", string(b))
+
+	// a file removed behind the writer's back is recreated
+	filename = filepath.Join(dir, "removed.log")
+	rw, err = NewRotatingFileWriter(filename, RotateOptions{MaxSize: 10})
+	assert.NoError(t, err)
+	defer rw.Close()
+	_, err = rw.Write([]byte("entry 123\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.Remove(filename))
+	_, err = rw.Write([]byte("entry 456\n"))
+	assert.NoError(t, err)
+	b, _ = ioutil.ReadFile(filename)
+	assert.Equal(t, "entry 456\n", string(b))
+	assert.Len(t, rw.Backups(), 0)
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	fw, err := NewFileWriter(filename, PartitionNone)
+	assert.NoError(t, err)
+	defer fw.Close()
+	aw := NewAsyncWriter(fw, 10)
+	defer aw.Close()
+	l := NewLogger3(aw, "reopened", NewTextFormatter("reopened"))
+	defer Unregister("reopened")
+	l.SetLevel(LevelAll)
+
+	l.Info("before")
+	assert.NoError(t, aw.Flush(time.Second))
+	assert.NoError(t, os.Rename(filename, filename+".1"))
+	l.Info("moved")
+	assert.NoError(t, aw.Flush(time.Second))
+	assert.NoError(t, Reopen())
+	l.Info("after")
+	assert.NoError(t, aw.Flush(time.Second))
+
+	b, _ := ioutil.ReadFile(filename + ".1")
+	assert.Contains(t, string(b), "before")
+	assert.Contains(t, string(b), "moved")
+	b, _ = ioutil.ReadFile(filename)
+	assert.NotContains(t, string(b), "moved")
+	assert.Contains(t, string(b), "after")
+}
+
+func TestFirstSeen(t *testing.T) {
+	ProcessLogxiFormatEnv("firstseen")
+	defer ProcessLogxiFormatEnv("")
+	resetSeenMessages()
+	defer resetSeenMessages()
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "novel", NewJSONFormatter("novel"))
+	l.SetLevel(LevelAll)
+	l.Warn("disk full", "pct", 99)
+	l.Warn("disk full", "pct", 98)
+	l.Warn("disk full")
+	l.Info("not tracked")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var first, second, third, info map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &third))
+	assert.NoError(t, json.Unmarshal([]byte(lines[3]), &info))
+	assert.Equal(t, true, first[FirstSeenKey])
+	assert.Nil(t, second[FirstSeenKey])
+	assert.Equal(t, 2.0, second[SeenKey])
+	assert.Equal(t, 3.0, third[SeenKey])
+	assert.Nil(t, info[FirstSeenKey])
+
+	ProcessLogxiFormatEnv("firstseen=ERR")
+	buf.Reset()
+	l.Warn("another warning")
+	assert.NotContains(t, buf.String(), FirstSeenKey)
+}
+
+func TestSyslog(t *testing.T) {
+	assert.Equal(t, 0, SyslogSeverity(LevelEmergency))
+	assert.Equal(t, 3, SyslogSeverity(LevelError))
+	assert.Equal(t, 7, SyslogSeverity(LevelTrace))
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+	sink, err := NewSyslogSink("udp", pc.LocalAddr().String())
+	assert.NoError(t, err)
+	defer sink.Close()
+
+	sf := NewSyslogFormatter("api server", FacilityLocal0)
+	l := NewLogger3(sink, "syslog", sf)
+	l.SetLevel(LevelAll)
+	l.Warn("disk full", "path", `/var "log"]`, "pct", 99)
+
+	b := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(b)
+	assert.NoError(t, err)
+	msg := string(b[:n])
+	assert.True(t, strings.HasPrefix(msg, "<132>1 "), msg)
+	assert.Contains(t, msg, " "+pidStr+" api_server [logxi@32473 ")
+	assert.Contains(t, msg, `path="/var \"log\"\]"`)
+	assert.Contains(t, msg, `pct="99"`)
+	assert.True(t, strings.HasSuffix(msg, "] disk full"), msg)
+
+	// octet counting over TCP
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		b, _ := ioutil.ReadAll(io.LimitReader(conn, 10))
+		received <- string(b)
+	}()
+	tcp, err := NewSyslogSink("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	tcp.Write([]byte("<14>1 hi\n"))
+	assert.Equal(t, "8 <14>1 hi", <-received)
+	tcp.Close()
+}
+
+func TestJournald(t *testing.T) {
+	assert.Equal(t, "USERID", journalField("userId"))
+	assert.Equal(t, "REQ_PATH", journalField("_req.path"))
+	assert.Equal(t, "FIELD_2XX", journalField("2xx"))
+
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip("unixgram sockets are not supported:", err)
+	}
+	defer pc.Close()
+	sink, err := newJournaldSink(path)
+	assert.NoError(t, err)
+	defer sink.Close()
+
+	l := NewLogger3(sink, "journal", NewJournaldFormatter("journal"))
+	l.SetLevel(LevelAll)
+	l.Warn("disk full", "mountPoint", "/var", "detail", "line 1\nline 2")
+
+	b := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := pc.Read(b)
+	assert.NoError(t, err)
+	datagram := string(b[:n])
+	assert.Contains(t, datagram, "MESSAGE=disk full\n")
+	assert.Contains(t, datagram, "PRIORITY=4\n")
+	assert.Contains(t, datagram, "LOGXI_NAME=journal\n")
+	assert.Contains(t, datagram, "MOUNTPOINT=/var\n")
+	assert.Contains(t, datagram, "CODE_FILE=")
+	assert.Contains(t, datagram, "DETAIL\n\x0d\x00\x00\x00\x00\x00\x00\x00line 1\nline 2\n")
+}
+
+func TestEscalation(t *testing.T) {
+	AddEscalationRule(EscalationRule{Name: "esc*", Level: LevelWarn, Count: 2, Window: time.Minute, To: LevelError})
+	AddEscalationRule(EscalationRule{Name: "*", Level: LevelInfo, Key: "status", Value: "503", To: LevelWarn})
+	defer ClearEscalationRules()
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "escalated", NewTextFormatter("escalated"))
+	l.SetLevel(LevelError)
+	l.Warn("retrying")
+	l.Warn("retrying")
+	assert.Equal(t, 0, buf.Len(), "warnings under the count are discarded")
+	l.Warn("retrying")
+	assert.Contains(t, buf.String(), "ERR")
+	assert.Contains(t, buf.String(), EscalatedKey+AssignmentChar+"WRN")
+	l.Warn("other")
+	assert.NotContains(t, buf.String(), "other", "messages are counted separately")
+
+	buf.Reset()
+	l.SetLevel(LevelWarn)
+	l.Info("upstream", "status", 503)
+	l.Info("upstream", "status", 200)
+	assert.Contains(t, buf.String(), "WRN")
+	assert.Equal(t, 1, strings.Count(buf.String(), "upstream"))
+
+	ClearEscalationRules()
+	buf.Reset()
+	l.SetLevel(LevelError)
+	for i := 0; i < 5; i++ {
+		l.Warn("retrying")
+	}
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestContextExtractors(t *testing.T) {
+	defer ClearContextExtractors()
+	type userKey struct{}
+	AddContextField("user", userKey{})
+	AddContextExtractor(func(ctx context.Context) []interface{} {
+		if deadline, ok := ctx.Deadline(); ok {
+			return []interface{}{"deadline", deadline.Unix()}
+		}
+		return nil
+	})
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "ctx", NewJSONFormatter("ctx"))
+	ctx := NewContext(context.WithValue(context.Background(), userKey{}, "u1"), l)
+	FromContext(ctx).Error("denied", "path", "/admin")
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "u1", obj["user"])
+	assert.Equal(t, "/admin", obj["path"])
+	_, ok := obj["deadline"]
+	assert.False(t, ok)
+
+	// contexts without extracted values return the carried logger
+	assert.Equal(t, l, FromContext(NewContext(context.Background(), l)))
+}
+
+func TestEventLogType(t *testing.T) {
+	assert.Equal(t, uint16(eventLogError), eventLogType(LevelEmergency))
+	assert.Equal(t, uint16(eventLogError), eventLogType(LevelFatal))
+	assert.Equal(t, uint16(eventLogError), eventLogType(LevelError))
+	assert.Equal(t, uint16(eventLogWarning), eventLogType(LevelWarn))
+	assert.Equal(t, uint16(eventLogInformation), eventLogType(LevelNotice))
+	assert.Equal(t, uint16(eventLogInformation), eventLogType(LevelDebug))
+}
+
+func TestBaggage(t *testing.T) {
+	var buf bytes.Buffer
+	l := newFieldLogger(NewLogger3(&buf, "svc", NewJSONFormatter("svc")), []interface{}{"tenant", "acme co", "order", 42, "secret", "x"})
+	assert.Equal(t, "tenant=acme%20co,order=42", Baggage(l, "tenant", "order", "missing"))
+
+	header := http.Header{}
+	header.Set(BaggageHeader, "order=1,vendor=v;prop=1")
+	InjectBaggage(header, l, "tenant", "order")
+	assert.Equal(t, "tenant=acme%20co,order=42,vendor=v;prop=1", header.Get(BaggageHeader))
+
+	handler := BaggageHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Error("received")
+	}), "tenant", "order")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header = header
+	r = r.WithContext(NewContext(r.Context(), NewLogger3(&buf, "downstream", NewJSONFormatter("downstream"))))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "acme co", obj["tenant"])
+	assert.Equal(t, "42", obj["order"])
+	_, ok := obj["vendor"]
+	assert.False(t, ok)
+}
+
+func TestNetWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// the collector is down
+	nw, err := NewNetWriter("tcp", addr, WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	assert.NoError(t, err)
+	defer nw.Close()
+	_, err = nw.Write([]byte("dropped\n"))
+	assert.Equal(t, ErrNetDisconnected, err)
+	assert.Equal(t, uint64(1), nw.Dropped())
+
+	ln, err = net.Listen("tcp", addr)
+	assert.NoError(t, err)
+	defer ln.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for !nw.Connected() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, nw.Connected())
+
+	conn, err := ln.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = nw.Write([]byte("sent\n"))
+	assert.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "sent\n", line)
+
+	_, err = NewNetWriter("pigeon", addr)
+	assert.Error(t, err)
+}
+
+func TestGELF(t *testing.T) {
+	var buf bytes.Buffer
+	gf := NewGELFFormatter("api")
+	gf.Format(&buf, LevelWarn, "slow", []interface{}{"id", "r1", "ms", 250, "user name", "bob", "ok", true})
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "1.1", obj["version"])
+	assert.Equal(t, "slow", obj["short_message"])
+	assert.Equal(t, float64(4), obj["level"])
+	assert.Equal(t, "api", obj["_logger"])
+	assert.Equal(t, "r1", obj["_id_"])
+	assert.Equal(t, float64(250), obj["_ms"])
+	assert.Equal(t, "bob", obj["_user_name"])
+	assert.Equal(t, "true", obj["_ok"])
+	_, ok := obj["full_message"]
+	assert.False(t, ok)
+
+	buf.Reset()
+	e := newEntry(LevelError, "api", "failed", nil, stackOptions{})
+	e.Stack = append(e.Stack[:0], Frame{Function: "main.run", File: "main.go", Line: 12})
+	gf.FormatEntry(&buf, e)
+	e.release()
+	obj = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "failed\nmain.run\n\tmain.go:12", obj["full_message"])
+
+	// chunked over UDP
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+	defer func(size int) { GELFChunkSize = size }(GELFChunkSize)
+	GELFChunkSize = 100
+	nw, err := NewNetWriter("udp", pc.LocalAddr().String(), WithGELF())
+	assert.NoError(t, err)
+	defer nw.Close()
+	msg := `{"short_message":"` + strings.Repeat("x", 250) + `"}`
+	_, err = nw.Write([]byte(msg + "\n"))
+	assert.NoError(t, err)
+
+	var payload []byte
+	datagram := make([]byte, 200)
+	// 88 bytes of payload per chunk
+	for i := 0; i < 4; i++ {
+		pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(datagram)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x1e, 0x0f}, datagram[:2])
+		assert.Equal(t, []byte{byte(i), 4}, datagram[10:12])
+		payload = append(payload, datagram[12:n]...)
+	}
+	assert.Equal(t, msg, string(payload))
+}
+
+func TestDumpConfig(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l Logger) { DefaultLog = l }(DefaultLog)
+	DefaultLog = NewLogger3(&buf, "dump", NewJSONFormatter("dump"))
+	DefaultLog.SetLevel(LevelInfo)
+	ProcessLogxiEnv("*=WRN,dump*=DBG")
+	defer ProcessLogxiEnv(cfg().config.Levels)
+	aw := NewAsyncWriter(os.Stderr, 16)
+	defer aw.Close()
+	NewLogger3(aw, "dumpAsync", NewJSONFormatter("dumpAsync"))
+
+	DumpConfig()
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "logging config", obj[KeyMap.Message])
+	assert.Equal(t, map[string]interface{}{"*": "WRN", "dump*": "DBG"}, obj["levels"])
+	assert.Equal(t, FormatJSON, obj["format"])
+	registered := obj["loggers"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"level": "DBG", "format": FormatJSON}, registered["dumpAsync"])
+	assert.Contains(t, obj["sinks"], "async(16) stderr")
+}
+
+func TestECS(t *testing.T) {
+	var buf bytes.Buffer
+	ef := NewECSFormatter("api")
+	ef.SetNamespace("app")
+	e := newEntry(LevelError, "api", "charge failed", []interface{}{"order", 42, "err", errors.New("card declined"), "retry", errors.New("later")}, stackOptions{})
+	e.Stack = append(e.Stack[:0], Frame{Function: "main.charge", File: "main.go", Line: 7})
+	e.caller = e.Stack[0]
+	e.Caller = &e.caller
+	ef.FormatEntry(&buf, e)
+	e.release()
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "error", obj["log.level"])
+	assert.Equal(t, "charge failed", obj["message"])
+	assert.Equal(t, "api", obj["log.logger"])
+	assert.Equal(t, ECSVersion, obj["ecs.version"])
+	assert.Equal(t, "main.go", obj["log.origin.file.name"])
+	assert.Equal(t, float64(7), obj["log.origin.file.line"])
+	assert.Equal(t, "card declined", obj["error.message"])
+	assert.Equal(t, "main.charge\n\tmain.go:7", obj["error.stack_trace"])
+	assert.Equal(t, map[string]interface{}{"order": float64(42), "retry": "later"}, obj["app"])
+	_, err := time.Parse(time.RFC3339, obj["@timestamp"].(string))
+	assert.NoError(t, err)
+
+	buf.Reset()
+	ef.Format(&buf, LevelWarn, "slow", nil)
+	obj = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "warn", obj["log.level"])
+	_, ok := obj["app"]
+	assert.False(t, ok)
+}
+
+func TestProfileLabels(t *testing.T) {
+	l := newFieldLogger(NewLogger3(ioutil.Discard, "prof", NewJSONFormatter("prof")), []interface{}{"request_id", "r1", "attempt", 2})
+	ctx := NewContext(context.Background(), l)
+	called := false
+	WithProfileLabels(ctx, []string{"request_id", "attempt", "missing"}, func(ctx context.Context) {
+		called = true
+		value, ok := pprof.Label(ctx, "request_id")
+		assert.True(t, ok)
+		assert.Equal(t, "r1", value)
+		value, _ = pprof.Label(ctx, "attempt")
+		assert.Equal(t, "2", value)
+		_, ok = pprof.Label(ctx, "missing")
+		assert.False(t, ok)
+	})
+	assert.True(t, called)
+
+	handler := ProfileLabelsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, _ := pprof.Label(r.Context(), "tenant")
+		fmt.Fprint(w, value)
+	}), "tenant")
+	handler = BaggageHandler(handler, "tenant")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(BaggageHeader, "tenant=acme")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "acme", w.Body.String())
+}
+
+func TestStackdriver(t *testing.T) {
+	var buf bytes.Buffer
+	sf := NewStackdriverFormatter("api")
+	sf.SetLabelKeys("tenant")
+	e := newEntry(LevelWarn, "api", "slow", []interface{}{"tenant", 7, "ms", 250, "err", errors.New("timeout")}, stackOptions{})
+	e.caller = Frame{Function: "main.run", File: "main.go", Line: 3}
+	e.Caller = &e.caller
+	sf.FormatEntry(&buf, e)
+	e.release()
+
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "WARNING", obj["severity"])
+	assert.Equal(t, "slow", obj["message"])
+	assert.Equal(t, map[string]interface{}{"file": "main.go", "line": "3", "function": "main.run"}, obj["logging.googleapis.com/sourceLocation"])
+	assert.Equal(t, map[string]interface{}{"logger": "api", "tenant": "7"}, obj["logging.googleapis.com/labels"])
+	assert.Equal(t, float64(250), obj["ms"])
+	assert.Equal(t, "timeout", obj["err"])
+	_, ok := obj["tenant"]
+	assert.False(t, ok)
+
+	buf.Reset()
+	sf.Format(&buf, LevelFatal, "down", nil)
+	obj = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "CRITICAL", obj["severity"])
+}
+
+func TestTraceAnnotations(t *testing.T) {
+	EnableTraceAnnotations(true)
+	defer EnableTraceAnnotations(false)
+	var out bytes.Buffer
+	assert.NoError(t, trace.Start(&out))
+
+	handler := CanonicalHandler(NewLogger3(ioutil.Discard, "traced", NewJSONFormatter("traced")), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stop := CanonicalFromContext(r.Context()).Time("dbQuery")
+		stop()
+		_, ok := FromContext(r.Context()).(*traceLogger)
+		assert.True(t, ok)
+		FromContext(r.Context()).Error("tracedFailure")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	trace.Stop()
+
+	assert.Contains(t, out.String(), "http GET")
+	assert.Contains(t, out.String(), "dbQuery")
+	assert.Contains(t, out.String(), "tracedFailure")
+
+	// not annotated unless a trace is recorded
+	_, ok := FromContext(context.Background()).(*traceLogger)
+	assert.False(t, ok)
+}
+
+func TestDatadog(t *testing.T) {
+	ProcessLogxiFormatEnv("JSON,datadog")
+	defer ProcessLogxiFormatEnv("")
+	type spanKey struct{}
+	AddContextExtractor(DatadogExtractor(func(ctx context.Context) (uint64, uint64, bool) {
+		id, ok := ctx.Value(spanKey{}).(uint64)
+		return 1<<63 + 1, id, ok
+	}))
+	defer ClearContextExtractors()
+
+	var buf bytes.Buffer
+	l := NewLogger3(&buf, "dd", NewJSONFormatter("dd"))
+	ctx := NewContext(context.WithValue(context.Background(), spanKey{}, uint64(7)), l)
+	FromContext(ctx).Error("failed")
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "error", obj["status"])
+	assert.Equal(t, "9223372036854775809", obj[DatadogTraceIDKey])
+	assert.Equal(t, "7", obj[DatadogSpanIDKey])
+
+	buf.Reset()
+	l.Error("bound", "trace_id", 12, "span_id", 34)
+	obj = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, "12", obj[DatadogTraceIDKey])
+	assert.Equal(t, "34", obj[DatadogSpanIDKey])
+	_, ok := obj["trace_id"]
+	assert.False(t, ok)
+}
+
+func TestLogxiShow(t *testing.T) {
+	ProcessLogxiShowEnv("l,m,err")
+	defer ProcessLogxiShowEnv("")
+	var buf bytes.Buffer
+	NewHappyDevFormatter("show").Format(&buf, LevelInfo, "saved", []interface{}{"err", "none", "id", 1, "user", "bob", "rows", 3})
+	line := stripANSI(strings.SplitN(buf.String(), "\n", 2)[0])
+	assert.Contains(t, line, "INF")
+	assert.Contains(t, line, "saved")
+	assert.NotContains(t, line, time.Now().Format("2006"))
+	assert.Contains(t, line, "err")
+	assert.Contains(t, line, "+3 fields")
+	assert.NotContains(t, line, "bob")
+	assert.NotContains(t, line, "show")
+
+	ProcessLogxiShowEnv("")
+	buf.Reset()
+	NewHappyDevFormatter("show").Format(&buf, LevelInfo, "saved", []interface{}{"user", "bob"})
+	assert.Contains(t, buf.String(), "bob")
+	assert.NotContains(t, buf.String(), "fields")
+}
+
+func TestSentry(t *testing.T) {
+	bodies := make(chan string, 4)
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/42/envelope/", r.URL.Path)
+		auth = r.Header.Get("X-Sentry-Auth")
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer server.Close()
+
+	_, err := NewSentrySink("not a dsn", SentryOptions{})
+	assert.Error(t, err)
+	sentry, err := NewSentrySink(strings.Replace(server.URL, "://", "://pubkey@", 1)+"/42", SentryOptions{Environment: "test"})
+	assert.NoError(t, err)
+	l := NewLogger3(ioutil.Discard, "sentried", NewMultiSink(Sink{Writer: sentry, Formatter: sentry, Level: LevelError}))
+	l.SetLevel(LevelAll)
+	l.Warn("ignored")
+	l.Error("charge failed", "order", 42, "err", errors.New("card declined"))
+	assert.NoError(t, sentry.Flush(2*time.Second))
+	assert.Contains(t, auth, "sentry_key=pubkey")
+
+	lines := strings.Split(strings.TrimSpace(<-bodies), "\n")
+	assert.Equal(t, 3, len(lines))
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+	assert.Equal(t, "error", event["level"])
+	assert.Equal(t, "charge failed", event["message"])
+	assert.Equal(t, "sentried", event["logger"])
+	assert.Equal(t, "test", event["environment"])
+	assert.Equal(t, map[string]interface{}{"order": float64(42), "err": "card declined"}, event["extra"])
+	exception := event["exception"].(map[string]interface{})["values"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "card declined", exception["value"])
+	frames := exception["stacktrace"].(map[string]interface{})["frames"].([]interface{})
+	found := false
+	for _, frame := range frames {
+		found = found || frame.(map[string]interface{})["function"] == "github.com/mgutz/logxi/v1.TestSentry"
+	}
+	assert.True(t, found)
+	assert.Equal(t, 0, len(bodies))
+
+	// writes not formatted by the sink are rejected
+	_, err = sentry.Write([]byte("short\n"))
+	assert.Error(t, err)
+	_, err = sentry.Write([]byte(`{"_m":"formatted as JSON by another formatter"}` + "\n"))
+	assert.Error(t, err)
+
+	// Close sends queued events and refuses later ones
+	l.Error("closing")
+	assert.NoError(t, sentry.Close())
+	assert.Contains(t, <-bodies, `"message":"closing"`)
+	l.Error("closed")
+	_, err = sentry.Write([]byte(`{"event_id":"0123456789abcdef0123456789abcdef"}`))
+	assert.Equal(t, ErrWriterClosed, err)
+	assert.NoError(t, sentry.Close())
+	assert.Equal(t, 0, len(bodies))
+}
+
+type kafkaProducer struct {
+	mu      sync.Mutex
+	batches [][]KafkaMessage
+}
+
+func (kp *kafkaProducer) Produce(messages []KafkaMessage) error {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	kp.batches = append(kp.batches, messages)
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	assert := assert.New(t)
+	producer := &kafkaProducer{}
+	kafka := NewKafkaSink(producer, "logs", KafkaOptions{BatchSize: 2, BatchTimeout: time.Hour})
+
+	logger := kafka.Logger("api")
+	logger.Error("one", "user", 1)
+	logger.Error("two")
+	kafka.Write([]byte("raw\n"))
+	assert.NoError(kafka.Flush(time.Second))
+
+	producer.mu.Lock()
+	batches := producer.batches
+	producer.mu.Unlock()
+	assert.Len(batches, 2)
+	assert.Len(batches[0], 2)
+	msg := batches[0][0]
+	assert.Equal("logs", msg.Topic)
+	assert.Equal("api", string(msg.Key))
+	var obj map[string]interface{}
+	assert.NoError(json.Unmarshal(msg.Value, &obj))
+	assert.Equal("one", obj[KeyMap.Message])
+	assert.Equal(float64(1), obj["user"])
+	assert.Equal([]KafkaMessage{{Topic: "logs", Value: []byte("raw")}}, batches[1])
+
+	assert.NoError(kafka.Close())
+	_, err := kafka.Write([]byte("late"))
+	assert.Equal(ErrWriterClosed, err)
+	assert.Equal(uint64(0), kafka.Dropped())
+
+	// messages accepted while closing are published
+	producer = &kafkaProducer{}
+	kafka = NewKafkaSink(producer, "logs", KafkaOptions{BatchSize: 10, BatchTimeout: time.Hour})
+	counts := make(chan int, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			n := 0
+			for j := 0; j < 100; j++ {
+				if _, err := kafka.Write([]byte("racing")); err == nil {
+					n++
+				}
+			}
+			counts <- n
+		}()
+	}
+	assert.NoError(kafka.Close())
+	accepted := 0
+	for i := 0; i < 4; i++ {
+		accepted += <-counts
+	}
+	published := 0
+	producer.mu.Lock()
+	for _, batch := range producer.batches {
+		published += len(batch)
+	}
+	producer.mu.Unlock()
+	assert.Equal(accepted, published)
+}
+
+func TestWriterTerminal(t *testing.T) {
+	assert := assert.New(t)
+	r, w, err := os.Pipe()
+	assert.NoError(err)
+	defer r.Close()
+	defer w.Close()
+
+	terminal, known := writerTerminal(NewConcurrentWriter(w))
+	assert.False(terminal)
+	assert.True(known)
+	_, known = writerTerminal(&bytes.Buffer{})
+	assert.False(known)
+
+	// a terminal stdout defaults to HappyDev, a piped writer to JSON
+	oldTerminal, oldSettings := isTerminal, cfg()
+	isTerminal = true
+	updateSettings(func(s *settings) { s.requestedFormat = "" })
+	assert.Equal(FormatJSON, writerFormat(w))
+	assert.Equal(cfg().logxiFormat, writerFormat(&bytes.Buffer{}))
+	updateSettings(func(s *settings) { s.requestedFormat = FormatHappy })
+	assert.Equal(cfg().logxiFormat, writerFormat(w))
+	isTerminal = oldTerminal
+	currentSettings.Store(oldSettings)
+
+	// colors are stripped for the destination which isn't a terminal
+	tty, file := &bytes.Buffer{}, &bytes.Buffer{}
+	terminals.Store(tty, [2]bool{true, true})
+	terminals.Store(file, [2]bool{false, true})
+	defer terminals.Delete(tty)
+	defer terminals.Delete(file)
+	updateSettings(func(s *settings) { s.disableColors = false })
+	defer currentSettings.Store(oldSettings)
+
+	assert.True(IsTerminal(tty))
+	assert.True(stripsColors(file))
+	assert.False(stripsColors(tty))
+	sw := NewLevelSplitWriter(file, tty, LevelWarn)
+	assert.True(IsTerminal(sw))
+	sw.WriteLevel(LevelError, []byte("\x1b[31merror\x1b[0m\n"))
+	sw.WriteLevel(LevelInfo, []byte("\x1b[32minfo\x1b[0m\n"))
+	assert.Equal("\x1b[31merror\x1b[0m\n", tty.String())
+	assert.Equal("info\n", file.String())
+
+	logger := NewLogger3(file, "terminal", NewTextFormatter("terminal"))
+	logger.SetLevel(LevelInfo)
+	logger.Info("\x1b[1mbold\x1b[0m")
+	assert.NotContains(file.String(), "\x1b")
+}
+
+func TestDisplayWidth(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(5, displayWidth("hello"))
+	assert.Equal(4, displayWidth("日本"))
+	assert.Equal(6, displayWidth("한국어"))
+	assert.Equal(2, displayWidth("🚀"))
+	assert.Equal(1, displayWidth("é"))
+	assert.Equal(8, displayWidth("ok ✅ 👍"))
+	assert.Equal("日本", truncateWidth("日本語", 5))
+	assert.Equal("a.日本", abbreviateName("app.日本語", 6))
+
+	// columns, not bytes, decide where lines wrap
+	oldSettings := cfg()
+	updateSettings(func(s *settings) { s.maxCol = 30 })
+	defer currentSettings.Store(oldSettings)
+	hd := NewHappyDevFormatter("width")
+	buf := &bytes.Buffer{}
+	hd.set(buf, "", "日本語のメッセージです", "")
+	assert.Equal(22, hd.col)
+	assert.NotContains(buf.String(), "\n")
+
+	_, block := Table([]string{"名前", "n"}, [][]interface{}{{"日本", 1}, {"ab", 2}}).happyValue()
+	lines := strings.Split(stripANSI(block), "\n")
+	assert.Equal(displayWidth(lines[2]), displayWidth(lines[3]))
+}
+
+func TestWindowsConsoleFallback(t *testing.T) {
+	assert := assert.New(t)
+	oldWindows, oldStdout := isWindows, colorableStdout
+	defer func() {
+		isWindows, colorableStdout, colorableConsole = oldWindows, oldStdout, nil
+		terminals.Delete(os.Stdout)
+		setDefaults(isTerminal)
+	}()
+	isWindows = true
+
+	os.Setenv("ConEmuANSI", "ON")
+	setDefaults(true)
+	assert.Equal(Themes["conemu"], defaultLogxiColorsEnv)
+
+	// the legacy console is colored through colorable
+	os.Unsetenv("ConEmuANSI")
+	setDefaults(true)
+	assert.Equal(Themes["windows"], defaultLogxiColorsEnv)
+	assert.NotNil(colorableConsole)
+	assert.Equal(colorableConsole, unwrapWriter(colorableStdout))
+	terminals.Delete(os.Stdout)
+	assert.Equal(isTerminal, IsTerminal(colorableStdout))
+}
+
+func TestLint(t *testing.T) {
+	assert := assert.New(t)
+	for msg, reason := range map[string]string{
+		"user logged in":         "",
+		"retry 2 of 5":           "",
+		"oauth2 token refreshed": "",
+		"user 4242 logged in":    "number",
+		"request 6f1c2a9e-1b2c-4d3e-8f90-1234567890ab": "uuid",
+		"connected to 10.0.0.12":                       "ip address",
+		"sent mail to ann@example.com":                 "email",
+		"fetched https://example.com/x":                "url",
+		"cache miss key=users":                         "key=value",
+		`file "report.pdf" not found`:                  "quoted value",
+		"commit 9f8e7d6c pushed":                       "hex id",
+		"deadbeef is not a hex id without digits":      "",
+	} {
+		assert.Equal(reason, lintReason(msg), msg)
+	}
+
+	var warnings []LintWarning
+	SetLintHook(func(w LintWarning) {
+		warnings = append(warnings, w)
+	})
+	defer SetLintHook(nil)
+	defer resetLint()
+	oldCardinality := LintCardinality
+	LintCardinality = 3
+	defer func() { LintCardinality = oldCardinality }()
+
+	logger := NewLogger3(&bytes.Buffer{}, "lint", NewJSONFormatter("lint"))
+	logger.Error("user 4242 logged in")
+	logger.Error("user 4343 logged in")
+	logger.Error("user logged in", "id", 4242)
+	for i := 0; i < 5; i++ {
+		logger.Error(fmt.Sprintf("shard %d down", i))
+	}
+	if assert.Len(warnings, 2) {
+		assert.Equal("user 4242 logged in", warnings[0].Msg)
+		assert.Equal("number", warnings[0].Reason)
+		assert.Equal("lint", warnings[0].Name)
+		assert.NotNil(warnings[0].Caller)
+		assert.Equal("shard 3 down", warnings[1].Msg)
+		assert.Equal("high cardinality", warnings[1].Reason)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	assert := assert.New(t)
+	buf := &bytes.Buffer{}
+	logger := NewLogger3(buf, "ttl", NewJSONFormatter("ttl"))
+	entry := func() map[string]interface{} {
+		var obj map[string]interface{}
+		assert.NoError(json.Unmarshal(buf.Bytes(), &obj))
+		buf.Reset()
+		return obj
+	}
+	expires := func(s interface{}) time.Duration {
+		at, err := time.Parse(time.RFC3339, s.(string))
+		assert.NoError(err)
+		return time.Until(at).Round(time.Hour)
+	}
+
+	logger.Error("body", TTLKey, 24*time.Hour)
+	obj := entry()
+	assert.Equal("24h0m0s", obj[TTLKey])
+	assert.Equal(24*time.Hour, expires(obj[ExpiresKey]))
+
+	// the last ttl wins over a bound one
+	newFieldLogger(logger, []interface{}{TTLKey, "1h"}).Error("body", TTLKey, "2h")
+	obj = entry()
+	assert.Equal("2h0m0s", obj[TTLKey])
+	assert.Equal(2*time.Hour, expires(obj[ExpiresKey]))
+
+	payload := []interface{}{"order", 7, "payload", Expiring("secret", time.Hour)}
+	logger.Error("placed", payload...)
+	obj = entry()
+	assert.Equal("secret", obj["payload"])
+	assert.NotContains(obj, ExpiresKey)
+	fields := obj[FieldExpiresKey].(map[string]interface{})
+	assert.Equal(time.Hour, expires(fields["payload"]))
+	assert.Equal(Expiring("secret", time.Hour), payload[3])
+
+	SetLevelTTL(LevelError, 72*time.Hour)
+	defer SetLevelTTL(LevelError, 0)
+	logger.Error("debugging")
+	obj = entry()
+	assert.Equal("72h0m0s", obj[TTLKey])
+	logger.Error("audited", TTLKey, "720h")
+	assert.Equal("720h0m0s", entry()[TTLKey])
+}
@@ -16,13 +16,15 @@ func Info(msg string, args ...interface{}) {
 }
 
 // Warn logs a warning statement. On terminals it logs file and line number.
-func Warn(msg string, args ...interface{}) {
-	DefaultLog.Warn(msg, args...)
+// It returns the first error argument, if any.
+func Warn(msg string, args ...interface{}) error {
+	return DefaultLog.Warn(msg, args...)
 }
 
-// Error logs an error statement with callstack.
-func Error(msg string, args ...interface{}) {
-	DefaultLog.Error(msg, args...)
+// Error logs an error statement with callstack. It returns the first error
+// argument, or else an error of msg.
+func Error(msg string, args ...interface{}) error {
+	return DefaultLog.Error(msg, args...)
 }
 
 // Fatal logs a fatal statement.
@@ -49,3 +51,33 @@ func IsInfo() bool {
 func IsWarn() bool {
 	return DefaultLog.IsWarn()
 }
+
+// SetDefault replaces DefaultLog, which the package-level functions log to.
+// A nil logger disables them. Call it before logging from other goroutines.
+func SetDefault(logger Logger) {
+	if logger == nil {
+		logger = NullLog
+	}
+	DefaultLog = logger
+}
+
+// With returns a logger adding args to every entry logged to DefaultLog.
+//
+// Example
+//
+//	reqLog := log.With("requestID", id)
+//	reqLog.Info("started")
+func With(args ...interface{}) Logger {
+	return newFieldLogger(DefaultLog, args)
+}
+
+// WithGroup returns a logger nesting the key-value pairs of every entry
+// logged to DefaultLog under name, see Group.
+//
+// Example
+//
+//	dbLog := log.WithGroup("db")
+//	dbLog.Info("query", "host", host) //=> db.host=pg1
+func WithGroup(name string) Logger {
+	return &groupLogger{Logger: DefaultLog, name: name}
+}
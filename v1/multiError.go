@@ -0,0 +1,83 @@
+package log
+
+import (
+	"reflect"
+	"runtime"
+)
+
+// multiError is implemented by errors which wrap several errors, eg those
+// returned by errors.Join.
+type multiError interface {
+	Unwrap() []error
+}
+
+// callersError is implemented by errors which record the program counters
+// of the stack they were created on, eg github.com/go-errors/errors.
+type callersError interface {
+	Callers() []uintptr
+}
+
+// unwrapErrors returns the errors wrapped by err, flattening nested
+// multi-errors. It returns nil if err does not wrap several errors.
+func unwrapErrors(err error) []error {
+	me, ok := err.(multiError)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	for _, e := range me.Unwrap() {
+		if e == nil {
+			continue
+		}
+		if nested := unwrapErrors(e); nested != nil {
+			errs = append(errs, nested...)
+		} else {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// errorFrames returns the stack recorded by err, if any. Errors from
+// github.com/pkg/errors are supported through their StackTrace method.
+func errorFrames(err error) []*frameInfo {
+	var pcs []uintptr
+	if ce, ok := err.(callersError); ok {
+		pcs = ce.Callers()
+	} else {
+		pcs = stackTracePCs(err)
+	}
+	if len(pcs) == 0 {
+		return nil
+	}
+	var result []*frameInfo
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			result = append(result, &frameInfo{filename: frame.File, lineno: frame.Line, method: frame.Function})
+		}
+		if !more {
+			break
+		}
+	}
+	return filterFrames(result)
+}
+
+// stackTracePCs calls a StackTrace method returning a slice of program
+// counters, as github.com/pkg/errors does, without depending on it.
+func stackTracePCs(err error) []uintptr {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil
+	}
+	st := method.Call(nil)[0]
+	if st.Kind() != reflect.Slice || st.Type().Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	pcs := make([]uintptr, st.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(st.Index(i).Uint())
+	}
+	return pcs
+}
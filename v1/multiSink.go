@@ -0,0 +1,88 @@
+package log
+
+import "io"
+
+// Sink is a destination of a MultiSink. Writer must be concurrent safe, see
+// NewConcurrentWriter.
+type Sink struct {
+	Writer    io.Writer
+	Formatter Formatter
+	// Level is the least severe level written, eg LevelWarn. Zero writes
+	// every entry the logger logs.
+	Level int
+}
+
+// MultiSink is a formatter which formats each entry once per sink with the
+// sink's formatter and writes it to the sink's writer, so one logger can
+// write colored output to the console and JSON to a file. The writer of the
+// logger is not used. The logger's level must let through the entries of
+// the most verbose sink, see Level.
+//
+// Example
+//
+//	sink := log.NewMultiSink(
+//		log.Sink{Writer: log.NewConcurrentWriter(os.Stdout), Formatter: log.NewHappyDevFormatter("app"), Level: log.LevelInfo},
+//		log.Sink{Writer: fw, Formatter: log.NewJSONFormatter("app"), Level: log.LevelDebug},
+//	)
+//	logger := log.New("app", log.WithFormatter(sink), log.WithLevel(sink.Level()))
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink creates a MultiSink writing to sinks.
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: append([]Sink(nil), sinks...)}
+}
+
+// Level returns the level of the most verbose sink.
+func (ms *MultiSink) Level() int {
+	level := LevelOff
+	for _, sink := range ms.sinks {
+		if sink.Level == 0 {
+			return LevelAll
+		}
+		if sink.Level > level {
+			level = sink.Level
+		}
+	}
+	return level
+}
+
+// Format formats an entry for every sink which accepts level.
+func (ms *MultiSink) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	for _, sink := range ms.sinks {
+		if sink.accepts(level) {
+			sink.Formatter.Format(sink.writer(level), level, msg, args)
+		}
+	}
+}
+
+// FormatEntry formats entry for every sink which accepts its level. The
+// caller and stack are captured once for all sinks.
+func (ms *MultiSink) FormatEntry(writer io.Writer, entry *Entry) {
+	for _, sink := range ms.sinks {
+		if !sink.accepts(entry.Level) {
+			continue
+		}
+		if ef, ok := sink.Formatter.(EntryFormatter); ok {
+			ef.FormatEntry(sink.writer(entry.Level), entry)
+		} else {
+			formatNamed(sink.Formatter, sink.writer(entry.Level), entry.Level, entry.Name, entry.Msg, entry.Fields)
+		}
+	}
+}
+
+func (sink *Sink) accepts(level int) bool {
+	return sink.Level == 0 || level <= sink.Level
+}
+
+// writer returns the writer of the sink, passing level to writers which
+// implement WriteLevel, eg a FileWriter.
+func (sink *Sink) writer(level int) io.Writer {
+	if lw, ok := unwrapWriter(sink.Writer).(levelWriter); ok {
+		return writerFunc(func(p []byte) (int, error) {
+			return lw.WriteLevel(level, p)
+		})
+	}
+	return sink.Writer
+}
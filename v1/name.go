@@ -0,0 +1,101 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNameLength is the maximum length in bytes of a logger name. Longer
+// names are truncated.
+var MaxNameLength = 128
+
+// InternNames interns logger names so processes creating many loggers from
+// user derived names, eg per topic, keep a single copy of each name.
+var InternNames = false
+
+var internedNames = struct {
+	sync.Mutex
+	names map[string]string
+}{names: map[string]string{}}
+
+// sanitizeName returns the canonical form of a logger name. Control
+// characters and invalid UTF-8 are removed so names can't corrupt output.
+func sanitizeName(name string) string {
+	clean := true
+	for _, r := range name {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			clean = false
+			break
+		}
+	}
+	if !clean {
+		name = strings.Map(func(r rune) rune {
+			if r == utf8.RuneError || unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, name)
+	}
+
+	if MaxNameLength > 0 && len(name) > MaxNameLength {
+		// truncate on a rune boundary
+		end := MaxNameLength
+		for end > 0 && !utf8.RuneStart(name[end]) {
+			end--
+		}
+		name = name[:end]
+	}
+
+	if InternNames {
+		internedNames.Lock()
+		if interned, ok := internedNames.names[name]; ok {
+			name = interned
+		} else {
+			internedNames.names[name] = name
+		}
+		internedNames.Unlock()
+	}
+	return name
+}
+
+// loggerName returns the name of logger, "" if it isn't a Namer.
+func loggerName(logger Logger) string {
+	if namer, ok := logger.(Namer); ok {
+		return namer.Name()
+	}
+	return ""
+}
+
+// abbreviateName shortens a dotted logger name to at most width columns.
+// Leading segments are reduced to their first letter, then vowels are
+// dropped from the last segment and finally the name is truncated, eg
+// app.billing.service => a.b.service => a.b.srvc
+func abbreviateName(name string, width int) string {
+	if width <= 0 || displayWidth(name) <= width {
+		return name
+	}
+	segments := strings.Split(name, ".")
+	last := len(segments) - 1
+	for i := 0; i < last; i++ {
+		if segments[i] != "" {
+			r, _ := utf8.DecodeRuneInString(segments[i])
+			segments[i] = string(r)
+		}
+		if displayWidth(strings.Join(segments, ".")) <= width {
+			return strings.Join(segments, ".")
+		}
+	}
+
+	if segments[last] != "" {
+		first, size := utf8.DecodeRuneInString(segments[last])
+		segments[last] = string(first) + strings.Map(func(r rune) rune {
+			if strings.ContainsRune("aeiouAEIOU", r) {
+				return -1
+			}
+			return r
+		}, segments[last][size:])
+	}
+	return truncateWidth(strings.Join(segments, "."), width)
+}
@@ -0,0 +1,266 @@
+package log
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNetDisconnected is returned by a NetWriter while it is reconnecting.
+// The entry is dropped.
+var ErrNetDisconnected = errors.New("logxi: not connected to collector")
+
+// NetOption configures a NetWriter.
+type NetOption func(*NetWriter)
+
+// WithTLS sets the TLS configuration of a "tls" NetWriter, eg to add
+// client certificates.
+func WithTLS(config *tls.Config) NetOption {
+	return func(nw *NetWriter) {
+		nw.tls = config
+	}
+}
+
+// WithBackoff sets the delay before the first reconnection attempt, which
+// doubles after each failed attempt up to max. The default is 100ms up to
+// 30s.
+func WithBackoff(min, max time.Duration) NetOption {
+	return func(nw *NetWriter) {
+		nw.minBackoff = min
+		nw.maxBackoff = max
+	}
+}
+
+// WithDialTimeout sets the timeout of connection attempts, 5s by default.
+func WithDialTimeout(timeout time.Duration) NetOption {
+	return func(nw *NetWriter) {
+		nw.dialTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the time a write may block on a slow collector
+// before the connection is dropped, 1s by default.
+func WithWriteTimeout(timeout time.Duration) NetOption {
+	return func(nw *NetWriter) {
+		nw.writeTimeout = timeout
+	}
+}
+
+// WithGELF frames GELF messages written by GELFFormatter. Over TCP and TLS
+// messages are terminated by a null byte instead of a newline, over UDP
+// messages larger than GELFChunkSize are chunked.
+func WithGELF() NetOption {
+	return func(nw *NetWriter) {
+		nw.gelf = true
+	}
+}
+
+// NetWriter is a concurrent safe writer streaming entries to a collector
+// over TCP, UDP or TLS. Over TCP and TLS entries are newline delimited,
+// over UDP each entry is a datagram.
+//
+// A failed connection is retried in the background with exponential
+// backoff. Entries written while disconnected are dropped and counted so
+// the application neither fails nor blocks when the collector restarts.
+// Wrap the writer with NewAsyncWriter so entries don't wait on the
+// network.
+//
+// Example
+//
+//	nw, err := log.NewNetWriter("tls", "collector:6514", log.WithBackoff(time.Second, time.Minute))
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer nw.Close()
+//	logger := log.NewLogger3(log.NewAsyncWriter(nw, 1024), "api", log.NewJSONFormatter("api"))
+type NetWriter struct {
+	network string
+	addr    string
+
+	tls          *tls.Config
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+	gelf         bool
+
+	mu           sync.Mutex
+	conn         net.Conn
+	reconnecting bool
+	closed       bool
+	done         chan struct{}
+
+	dropped uint64
+	health  sinkHealth
+}
+
+// NewNetWriter creates a writer for the "tcp", "udp" or "tls" collector at
+// addr and connects to it. If the collector is down the writer is returned
+// anyway and connects once the collector is up.
+func NewNetWriter(network, addr string, opts ...NetOption) (*NetWriter, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "tls":
+	default:
+		return nil, fmt.Errorf("logxi: unsupported network %q", network)
+	}
+	nw := &NetWriter{
+		network:      network,
+		addr:         addr,
+		minBackoff:   100 * time.Millisecond,
+		maxBackoff:   30 * time.Second,
+		dialTimeout:  5 * time.Second,
+		writeTimeout: time.Second,
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(nw)
+	}
+
+	conn, err := nw.dial()
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if err != nil {
+		nw.health.record(err)
+		nw.reconnect()
+	} else {
+		nw.conn = conn
+	}
+	return nw, nil
+}
+
+func (nw *NetWriter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: nw.dialTimeout}
+	if nw.network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", nw.addr, nw.tls)
+	}
+	return dialer.Dial(nw.network, nw.addr)
+}
+
+// reconnect starts reconnecting in the background unless it already is.
+// The lock must be held.
+func (nw *NetWriter) reconnect() {
+	if nw.reconnecting || nw.closed {
+		return
+	}
+	nw.reconnecting = true
+	go func() {
+		backoff := nw.minBackoff
+		for {
+			select {
+			case <-nw.done:
+				return
+			case <-time.After(backoff):
+			}
+			conn, err := nw.dial()
+			if err == nil {
+				nw.mu.Lock()
+				nw.reconnecting = false
+				if nw.closed {
+					conn.Close()
+				} else {
+					nw.conn = conn
+				}
+				nw.mu.Unlock()
+				return
+			}
+			nw.health.record(err)
+			if backoff *= 2; backoff > nw.maxBackoff {
+				backoff = nw.maxBackoff
+			}
+		}
+	}()
+}
+
+func (nw *NetWriter) Write(p []byte) (int, error) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if nw.closed {
+		return 0, ErrWriterClosed
+	}
+	if nw.conn == nil {
+		atomic.AddUint64(&nw.dropped, 1)
+		return 0, nw.health.record(ErrNetDisconnected)
+	}
+	if nw.writeTimeout > 0 {
+		nw.conn.SetWriteDeadline(time.Now().Add(nw.writeTimeout))
+	}
+	if err := nw.health.record(nw.send(p)); err != nil {
+		atomic.AddUint64(&nw.dropped, 1)
+		if err == errGELFTooLarge {
+			return 0, err
+		}
+		nw.conn.Close()
+		nw.conn = nil
+		nw.reconnect()
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// send writes p, framed if required. The lock must be held.
+func (nw *NetWriter) send(p []byte) error {
+	if !nw.gelf {
+		_, err := nw.conn.Write(p)
+		return err
+	}
+	msg := p
+	if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[:len(msg)-1]
+	}
+	if nw.network == "tls" || strings.HasPrefix(nw.network, "tcp") {
+		frame := make([]byte, 0, len(msg)+1)
+		_, err := nw.conn.Write(append(append(frame, msg...), 0))
+		return err
+	}
+	chunks, err := gelfChunks(msg, GELFChunkSize)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if _, err := nw.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of entries dropped while disconnected or
+// failing to be written.
+func (nw *NetWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&nw.dropped)
+}
+
+// Connected reports whether the writer is connected to the collector.
+func (nw *NetWriter) Connected() bool {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	return nw.conn != nil
+}
+
+// Health reports whether the writer is connected, the entries it dropped
+// and the last error, including failed connection attempts.
+func (nw *NetWriter) Health() []SinkStatus {
+	return []SinkStatus{nw.health.status(nw.network+"://"+nw.addr, nw.Connected(), 0, nw.Dropped())}
+}
+
+// Close stops reconnecting and closes the connection.
+func (nw *NetWriter) Close() error {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if nw.closed {
+		return nil
+	}
+	nw.closed = true
+	close(nw.done)
+	if nw.conn == nil {
+		return nil
+	}
+	err := nw.conn.Close()
+	nw.conn = nil
+	return err
+}
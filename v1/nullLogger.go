@@ -6,7 +6,7 @@ var NullLog = &NullLogger{}
 // NullLogger is the default logger for this package.
 type NullLogger struct{}
 
-// Trace logs a debug entry.
+// Trace logs a trace entry.
 func (l *NullLogger) Trace(msg string, args ...interface{}) {
 }
 
@@ -57,6 +57,12 @@ func (l *NullLogger) IsWarn() bool {
 	return false
 }
 
+// Name returns an empty name since NullLog is shared by all disabled
+// loggers.
+func (l *NullLogger) Name() string {
+	return ""
+}
+
 // SetLevel sets the level of this logger.
 func (l *NullLogger) SetLevel(level int) {
 }
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"math"
+	"strconv"
+)
+
+// FloatFormat determines how formatters render floats so numeric fields
+// serialize the same way across formatters. Set it for every formatter with
+// LOGXI_FORMAT, eg LOGXI_FORMAT=JSON,precision=3,fixed,nan=string, or for
+// a single formatter with SetFloatFormat.
+type FloatFormat struct {
+	// Precision is the number of decimals, -1 for the fewest digits which
+	// represent the float exactly
+	Precision int
+	// Fixed avoids scientific notation, eg 1e+21
+	Fixed bool
+	// NaNString logs NaN and infinities as the strings "NaN", "+Inf" and
+	// "-Inf" instead of null. Both are valid JSON.
+	NaNString bool
+}
+
+// DefaultFloatFormat logs floats with the fewest digits which represent
+// them exactly and non-finite floats as null.
+var DefaultFloatFormat = FloatFormat{Precision: -1}
+
+// format formats f. finite is false for NaN and infinities, in which case
+// s is "NaN", "+Inf" or "-Inf".
+func (ff FloatFormat) format(f float64, bitSize int) (s string, finite bool) {
+	switch {
+	case math.IsNaN(f):
+		return "NaN", false
+	case math.IsInf(f, 1):
+		return "+Inf", false
+	case math.IsInf(f, -1):
+		return "-Inf", false
+	}
+	if ff.Fixed || ff.Precision >= 0 {
+		return strconv.FormatFloat(f, 'f', ff.Precision, bitSize), true
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize), true
+}
+
+// text formats f for flat formats.
+func (ff FloatFormat) text(f float64, bitSize int) string {
+	s, finite := ff.format(f, bitSize)
+	if !finite && !ff.NaNString {
+		return "null"
+	}
+	return s
+}
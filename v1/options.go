@@ -0,0 +1,44 @@
+package log
+
+import "io"
+
+// Option configures a logger created with New.
+type Option func(*options)
+
+type options struct {
+	writer    io.Writer
+	level     int
+	formatter Formatter
+	fields    []interface{}
+}
+
+// WithWriter sets the writer of the logger instead of stdout. If writer is
+// not concurrent safe, wrap it with NewConcurrentWriter.
+func WithWriter(writer io.Writer) Option {
+	return func(o *options) {
+		o.writer = writer
+	}
+}
+
+// WithLevel sets the level of the logger instead of the level set by LOGXI.
+// The level is replaced when the configuration changes, eg by LoadConfig.
+func WithLevel(level int) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithFormatter sets the formatter of the logger instead of the one
+// selected by LOGXI_FORMAT.
+func WithFormatter(formatter Formatter) Option {
+	return func(o *options) {
+		o.formatter = formatter
+	}
+}
+
+// WithFields adds key-value pairs to every entry of the logger.
+func WithFields(args ...interface{}) Option {
+	return func(o *options) {
+		o.fields = append(o.fields, args...)
+	}
+}
@@ -0,0 +1,130 @@
+package parse
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"time"
+)
+
+// timeLayouts are the time formats tried by ParseTime, the defaults of
+// logxi first
+var timeLayouts = []string{
+	"2006-01-02T15:04:05-0700",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000000-0700",
+	"2006-01-02 15:04:05.000000",
+	"2006-01-02 15:04:05",
+	"15:04:05.000000",
+	"15:04:05",
+}
+
+// ParseTime parses the time of an entry in the formats logxi uses by
+// default and RFC 3339. Times without a date are on January 1, year 0.
+func ParseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Source is a labeled stream of logxi output, eg a service's log file.
+type Source struct {
+	Label  string
+	Reader io.Reader
+}
+
+// Merged is an entry of a merged stream.
+type Merged struct {
+	*Entry
+	// Source is the label of the source of the entry
+	Source string
+	// Time is the parsed time of the entry. Entries without a time get the
+	// time of the entry before them in the same source.
+	Time time.Time
+}
+
+// mergeCursor is the next entry of a source
+type mergeCursor struct {
+	index   int
+	source  Source
+	scanner *Scanner
+	next    *Merged
+}
+
+// advance reads the next entry of the source, returning false at the end.
+func (mc *mergeCursor) advance() bool {
+	if !mc.scanner.Scan() {
+		mc.next = nil
+		return false
+	}
+	entry := mc.scanner.Entry()
+	t, ok := ParseTime(entry.Time)
+	if !ok && mc.next != nil {
+		t = mc.next.Time
+	}
+	mc.next = &Merged{Entry: entry, Source: mc.source.Label, Time: t}
+	return true
+}
+
+type mergeHeap []*mergeCursor
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].next.Time.Equal(h[j].next.Time) {
+		return h[i].index < h[j].index
+	}
+	return h[i].next.Time.Before(h[j].next.Time)
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeCursor)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	mc := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return mc
+}
+
+// Merge merges the entries of sources, each in time order, into a single
+// time ordered stream, calling fn for each entry. Entries with the same time
+// are ordered by source. Merging stops at the first error of fn or of
+// parsing a source.
+//
+// Example
+//
+//	api, _ := os.Open("api.log")
+//	db, _ := os.Open("db.log")
+//	err := parse.Merge([]parse.Source{{"api", api}, {"db", db}}, func(m *parse.Merged) error {
+//		fmt.Println(m.Source, m.Raw)
+//		return nil
+//	})
+func Merge(sources []Source, fn func(*Merged) error) error {
+	h := make(mergeHeap, 0, len(sources))
+	for i, source := range sources {
+		mc := &mergeCursor{index: i, source: source, scanner: NewScanner(source.Reader)}
+		if mc.advance() {
+			h = append(h, mc)
+		} else if err := mc.scanner.Err(); err != nil {
+			return fmt.Errorf("parse: %s: %v", source.Label, err)
+		}
+	}
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		mc := h[0]
+		if err := fn(mc.next); err != nil {
+			return err
+		}
+		if mc.advance() {
+			heap.Fix(&h, 0)
+			continue
+		}
+		heap.Pop(&h)
+		if err := mc.scanner.Err(); err != nil {
+			return fmt.Errorf("parse: %s: %v", mc.source.Label, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Package parse reads entries written by logxi's JSON, logfmt and HappyDev
+// formatters, so tools such as tailers and tests share one parser.
+//
+// Example
+//
+//	scanner := parse.NewScanner(os.Stdin)
+//	for scanner.Scan() {
+//		entry := scanner.Entry()
+//		if entry.Level <= log.LevelError {
+//			fmt.Println(entry.Name, entry.Msg)
+//		}
+//	}
+//	if err := scanner.Err(); err != nil {
+//		...
+//	}
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/logxi/v1"
+)
+
+// ErrEmpty is returned when parsing a blank line.
+var ErrEmpty = errors.New("parse: empty line")
+
+// Field is a key-value pair of an entry. Values parsed from JSON have
+// their JSON type, other values are strings.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Entry is a parsed log entry.
+type Entry struct {
+	// Time is the time as formatted, see LOGXI_FORMAT
+	Time string
+	// Label is the level label, eg "ERR"
+	Label string
+	// Level is the level of Label, 0 if the label is unknown
+	Level int
+	Name  string
+	Msg   string
+	// Fields are the other key-value pairs in order, including built-in
+	// fields such as the pid
+	Fields []Field
+	// Stack is the call stack, or the source context lines HappyDev prints
+	// below an entry
+	Stack string
+	// Raw is the line the entry was parsed from
+	Raw string
+}
+
+// Get returns the value of the first field named key.
+func (e *Entry) Get(key string) (interface{}, bool) {
+	for _, field := range e.Fields {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// set sets a built-in field of e or adds the pair to its fields.
+func (e *Entry) set(key string, value interface{}) {
+	switch key {
+	case log.KeyMap.Time:
+		e.Time = fmt.Sprint(value)
+	case log.KeyMap.Level:
+		e.Label = fmt.Sprint(value)
+		e.Level, _ = log.ParseLevel(e.Label)
+	case log.KeyMap.Name:
+		e.Name = fmt.Sprint(value)
+	case log.KeyMap.Message:
+		e.Msg = fmt.Sprint(value)
+	case log.KeyMap.CallStack:
+		if s, ok := value.(string); ok {
+			e.Stack = s
+		} else {
+			b, _ := json.Marshal(value)
+			e.Stack = string(b)
+		}
+	default:
+		e.Fields = append(e.Fields, Field{Key: key, Value: value})
+	}
+}
+
+// JSON parses an entry written by JSONFormatter, keeping the order of its
+// fields.
+func JSON(line []byte) (*Entry, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, ErrEmpty
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("parse: entry is not a JSON object")
+	}
+	e := &Entry{Raw: string(line)}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("parse: %v", err)
+		}
+		key, _ := tok.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("parse: value of %q: %v", key, err)
+		}
+		e.set(key, value)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("parse: %v", err)
+	}
+	return e, nil
+}
+
+// Logfmt parses an entry written by TextFormatter in logfmt mode. Quoted
+// values are unquoted.
+func Logfmt(line []byte) (*Entry, error) {
+	s := strings.TrimSpace(string(line))
+	if s == "" {
+		return nil, ErrEmpty
+	}
+	e := &Entry{Raw: s}
+	for s != "" {
+		i := strings.IndexByte(s, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("parse: expected key=value at %q", s)
+		}
+		key := s[:i]
+		s = s[i+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := quotedEnd(s)
+			if end < 0 {
+				return nil, fmt.Errorf("parse: unterminated value of %q", key)
+			}
+			unquoted, err := strconv.Unquote(s[:end])
+			if err != nil {
+				return nil, fmt.Errorf("parse: value of %q: %v", key, err)
+			}
+			value = unquoted
+			s = s[end:]
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+		e.set(key, value)
+		s = strings.TrimLeft(s, " ")
+	}
+	return e, nil
+}
+
+// quotedEnd returns the index after the closing quote of the quoted string
+// starting s or -1.
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// happyFieldRe matches the start of a key-value pair on a HappyDev line
+var happyFieldRe = regexp.MustCompile(` [^\s=]+=`)
+
+// Happy parses the first line of an entry written by HappyDevFormatter.
+// Colors are removed. Values are not quoted, so a value containing
+// " key=" is split into two fields. Use a Scanner to collect the lines
+// printed below an entry.
+func Happy(line []byte) (*Entry, error) {
+	s := strings.TrimSpace(ansiRe.ReplaceAllString(string(line), ""))
+	if s == "" {
+		return nil, ErrEmpty
+	}
+	parts := strings.SplitN(s, " ", 4)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("parse: expected time, level and name in %q", s)
+	}
+	e := &Entry{Time: parts[0], Label: parts[1], Name: parts[2], Raw: s}
+	e.Level, _ = log.ParseLevel(e.Label)
+	if len(parts) == 3 {
+		return e, nil
+	}
+
+	rest := " " + parts[3]
+	starts := happyFieldRe.FindAllStringIndex(rest, -1)
+	if len(starts) == 0 {
+		e.Msg = parts[3]
+		return e, nil
+	}
+	if starts[0][0] > 0 {
+		e.Msg = rest[1:starts[0][0]]
+	}
+	for i, start := range starts {
+		end := len(rest)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		pair := rest[start[0]+1 : end]
+		eq := strings.IndexByte(pair, '=')
+		e.Fields = append(e.Fields, Field{Key: pair[:eq], Value: pair[eq+1:]})
+	}
+	return e, nil
+}
+
+// Line parses an entry in any supported format: JSON objects, logfmt lines
+// with a level key and otherwise HappyDev lines.
+func Line(line []byte) (*Entry, error) {
+	trimmed := bytes.TrimSpace(line)
+	switch {
+	case len(trimmed) == 0:
+		return nil, ErrEmpty
+	case trimmed[0] == '{':
+		return JSON(trimmed)
+	case bytes.Contains(trimmed, []byte(log.KeyMap.Level+"=")):
+		return Logfmt(trimmed)
+	}
+	return Happy(trimmed)
+}
+
+// Scanner reads entries from a stream of logxi output. Indented lines are
+// added to the Stack of the entry above them, as HappyDevFormatter prints
+// the source context of warnings and errors.
+type Scanner struct {
+	lines *bufio.Scanner
+	parse func([]byte) (*Entry, error)
+	// next is the entry read ahead while collecting indented lines
+	next  *Entry
+	entry *Entry
+	err   error
+}
+
+// NewScanner creates a scanner detecting the format of each line, see Line.
+func NewScanner(r io.Reader) *Scanner {
+	return NewFormatScanner(r, Line)
+}
+
+// NewFormatScanner creates a scanner parsing each line with parse, eg JSON.
+func NewFormatScanner(r io.Reader, parse func([]byte) (*Entry, error)) *Scanner {
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &Scanner{lines: lines, parse: parse}
+}
+
+// Scan reads the next entry. It returns false at the end of the stream or
+// when a line can't be parsed, see Err.
+func (s *Scanner) Scan() bool {
+	s.entry = s.next
+	s.next = nil
+	for s.err == nil && s.lines.Scan() {
+		line := s.lines.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && s.entry != nil {
+			if s.entry.Stack != "" {
+				s.entry.Stack += "\n"
+			}
+			s.entry.Stack += ansiRe.ReplaceAllString(string(line), "")
+			continue
+		}
+		entry, err := s.parse(line)
+		if err != nil {
+			s.err = err
+			break
+		}
+		if s.entry == nil {
+			s.entry = entry
+			continue
+		}
+		s.next = entry
+		return true
+	}
+	if s.err == nil {
+		s.err = s.lines.Err()
+	}
+	return s.entry != nil
+}
+
+// Entry returns the entry read by Scan.
+func (s *Scanner) Entry() *Entry {
+	return s.entry
+}
+
+// Err returns the first error reading or parsing the stream.
+func (s *Scanner) Err() error {
+	return s.err
+}
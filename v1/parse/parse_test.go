@@ -0,0 +1,153 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mgutz/logxi/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.NewLogger3(&buf, "parse.json", log.NewJSONFormatter("parse.json"))
+	l.SetLevel(log.LevelAll)
+	l.Info("hello world", "user", "bob smith", "n", 3, "q", `say "hi"`)
+
+	e, err := JSON(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "INF", e.Label)
+	assert.Equal(t, log.LevelInfo, e.Level)
+	assert.Equal(t, "parse.json", e.Name)
+	assert.Equal(t, "hello world", e.Msg)
+	assert.NotEmpty(t, e.Time)
+	v, ok := e.Get("n")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v)
+	v, _ = e.Get("q")
+	assert.Equal(t, `say "hi"`, v)
+	assert.Equal(t, "user", e.Fields[len(e.Fields)-3].Key, "fields keep their order")
+
+	_, err = JSON([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestLogfmt(t *testing.T) {
+	e, err := Logfmt([]byte(`_t=2024-05-17T10:00:00+0000 _p=42 _n=svc _l=WRN _m="slow query" ms=812 sql="select \"x\" from t" empty=""`))
+	assert.NoError(t, err)
+	assert.Equal(t, "WRN", e.Label)
+	assert.Equal(t, log.LevelWarn, e.Level)
+	assert.Equal(t, "svc", e.Name)
+	assert.Equal(t, "slow query", e.Msg)
+	assert.Equal(t, []Field{{"_p", "42"}, {"ms", "812"}, {"sql", `select "x" from t`}, {"empty", ""}}, e.Fields)
+
+	_, err = Logfmt([]byte(`_l=ERR _m="unterminated`))
+	assert.Error(t, err)
+}
+
+func TestHappy(t *testing.T) {
+	e, err := Happy([]byte("\x1b[32m10:00:00.000000\x1b[0m INF svc.api hello world user=bob smith n=3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "10:00:00.000000", e.Time)
+	assert.Equal(t, log.LevelInfo, e.Level)
+	assert.Equal(t, "svc.api", e.Name)
+	assert.Equal(t, "hello world", e.Msg)
+	assert.Equal(t, []Field{{"user", "bob smith"}, {"n", "3"}}, e.Fields)
+
+	e, err = Happy([]byte("10:00:00.000000 ERR svc failed"))
+	assert.NoError(t, err)
+	assert.Equal(t, "failed", e.Msg)
+	assert.Empty(t, e.Fields)
+}
+
+func TestScanner(t *testing.T) {
+	input := strings.Join([]string{
+		`{"_l":"INF", "_n":"a", "_m":"json"}`,
+		`_l=WRN _n=b _m=logfmt`,
+		`10:00:00.000000 ERR c happy err=boom`,
+		`   in main.main(main.go:17)`,
+		`     17:  l.Error("happy")`,
+		``,
+		`10:00:01.000000 INF c last`,
+	}, "\n")
+	scanner := NewScanner(strings.NewReader(input))
+	var entries []*Entry
+	for scanner.Scan() {
+		entries = append(entries, scanner.Entry())
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Len(t, entries, 4)
+	assert.Equal(t, "json", entries[0].Msg)
+	assert.Equal(t, "logfmt", entries[1].Msg)
+	assert.Equal(t, "happy", entries[2].Msg)
+	assert.Contains(t, entries[2].Stack, "main.main(main.go:17)")
+	assert.Contains(t, entries[2].Stack, `l.Error("happy")`)
+	assert.Equal(t, "last", entries[3].Msg)
+
+	scanner = NewFormatScanner(strings.NewReader("{}\nbad"), JSON)
+	assert.True(t, scanner.Scan())
+	assert.False(t, scanner.Scan())
+	assert.Error(t, scanner.Err())
+	assert.False(t, errors.Is(scanner.Err(), ErrEmpty))
+}
+
+func TestMerge(t *testing.T) {
+	api := strings.Join([]string{
+		`{"_t":"2024-05-17T10:00:01+0000", "_l":"INF", "_n":"api", "_m":"request"}`,
+		`{"_t":"2024-05-17T10:00:03+0000", "_l":"ERR", "_n":"api", "_m":"failed"}`,
+	}, "\n")
+	db := strings.Join([]string{
+		`_t=2024-05-17T10:00:02+0000 _l=WRN _n=db _m="slow query"`,
+		`_l=WRN _n=db _m=untimed`,
+		`_t=2024-05-17T10:00:03+0000 _l=ERR _n=db _m=deadlock`,
+	}, "\n")
+
+	var got []string
+	err := Merge([]Source{{"api", strings.NewReader(api)}, {"db", strings.NewReader(db)}}, func(m *Merged) error {
+		got = append(got, m.Source+" "+m.Msg)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api request", "db slow query", "db untimed", "api failed", "db deadlock"}, got)
+
+	stop := errors.New("stop")
+	err = Merge([]Source{{"api", strings.NewReader(api)}}, func(m *Merged) error {
+		return stop
+	})
+	assert.Equal(t, stop, err)
+
+	_, ok := ParseTime("10:00:00.000000")
+	assert.True(t, ok)
+}
+
+func TestQuery(t *testing.T) {
+	warn, _ := JSON([]byte(`{"_l":"WRN","_n":"api","_m":"slow","user_id":"42","ms":250}`))
+	info, _ := JSON([]byte(`{"_l":"INF","_n":"db","_m":"query","user_id":"7","ms":12}`))
+
+	for expr, want := range map[string][2]bool{
+		`level>=WRN && fields.user_id=="42"`: {true, false},
+		`level<WRN`:                          {false, true},
+		`level == warn`:                      {true, false},
+		`ms > 100`:                           {true, false},
+		`ms >= 12 && ms < 100`:               {false, true},
+		`name =~ "^d" || msg == 'slow'`:      {true, true},
+		`!(name == "api")`:                   {false, true},
+		`missing`:                            {false, false},
+		`fields.missing != "x"`:              {true, true},
+		`user_id`:                            {true, true},
+	} {
+		q, err := ParseQuery(expr)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+		assert.Equal(t, want[0], q.Match(warn), expr)
+		assert.Equal(t, want[1], q.Match(info), expr)
+	}
+
+	for _, expr := range []string{`level >=`, `(ms > 1`, `"a"`, `name =~ "("`, `ms ? 1`, `a == "b`} {
+		_, err := ParseQuery(expr)
+		assert.Error(t, err, expr)
+	}
+}
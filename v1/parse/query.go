@@ -0,0 +1,371 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mgutz/logxi/v1"
+)
+
+// Query is a compiled filter expression over entries, eg
+//
+//	level>=WRN && fields.user_id=="42"
+//
+// Operands are fields, quoted strings and numbers. Fields are level, name,
+// msg, time and fields.KEY, or KEY for short. Operators are ==, !=, <, <=,
+// >, >=, =~ (regular expression match), &&, || and !, with parentheses for
+// grouping. A field alone is true if the entry has it.
+//
+// Levels compare by severity, so level>=WRN matches warnings and more
+// severe entries. Values compare as numbers if both are numbers, otherwise
+// as strings. Comparisons with missing fields are false, except !=.
+type Query struct {
+	expr string
+	root node
+}
+
+// ParseQuery compiles a filter expression.
+func ParseQuery(expr string) (*Query, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("parse: unexpected %q in query", p.tokens[p.pos].text)
+	}
+	return &Query{expr: expr, root: root}, nil
+}
+
+// Match reports whether entry matches the query.
+func (q *Query) Match(entry *Entry) bool {
+	return q.root.eval(entry)
+}
+
+func (q *Query) String() string {
+	return q.expr
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// operators, longest first
+var queryOps = []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!", "(", ")"}
+
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("parse: unterminated string in query")
+			}
+			s := expr[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(expr[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("parse: invalid string %s in query", expr[i:end+1])
+				}
+				s = unquoted
+			}
+			tokens = append(tokens, token{tokString, s})
+			i = end + 1
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(expr) && (expr[end] >= '0' && expr[end] <= '9' || expr[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokNumber, expr[i:end]})
+			i = end
+		case isIdentRune(rune(c)):
+			end := i
+			for end < len(expr) && (isIdentRune(rune(expr[end])) || expr[end] == '.' || expr[end] == '-') {
+				end++
+			}
+			tokens = append(tokens, token{tokIdent, expr[i:end]})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range queryOps {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("parse: unexpected %q in query", c)
+			}
+			tokens = append(tokens, token{tokOp, op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '@' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t != nil && t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.accept("||") {
+		var right node
+		if right, err = p.and(); err == nil {
+			left = orNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var right node
+		if right, err = p.unary(); err == nil {
+			left = andNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		n, err := p.unary()
+		return notNode{n}, err
+	}
+	if p.accept("(") {
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("parse: missing ) in query")
+		}
+		return n, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t == nil || t.kind != tokOp || !isComparison(t.text) {
+		if left.field == "" {
+			return nil, fmt.Errorf("parse: expected a comparison after %q in query", left.literal)
+		}
+		return existsNode{left.field}, nil
+	}
+	p.pos++
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	// level names are literals, eg level>=WRN
+	if left.field == "level" && right.field != "" {
+		right = operand{literal: right.field}
+	} else if right.field == "level" && left.field != "" {
+		left = operand{literal: left.field}
+	}
+	c := compareNode{op: t.text, left: left, right: right}
+	if t.text == "=~" {
+		if right.field != "" {
+			return nil, fmt.Errorf("parse: =~ expects a regular expression string")
+		}
+		if c.re, err = regexp.Compile(right.literal); err != nil {
+			return nil, fmt.Errorf("parse: %v", err)
+		}
+	}
+	return c, nil
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~":
+		return true
+	}
+	return false
+}
+
+// operand is a field or a literal
+type operand struct {
+	field   string
+	literal string
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.peek()
+	if t == nil {
+		return operand{}, fmt.Errorf("parse: unexpected end of query")
+	}
+	p.pos++
+	switch t.kind {
+	case tokIdent:
+		return operand{field: t.text}, nil
+	case tokString, tokNumber:
+		return operand{literal: t.text}, nil
+	}
+	return operand{}, fmt.Errorf("parse: unexpected %q in query", t.text)
+}
+
+// value returns the value of the operand in entry, false if a field is
+// missing.
+func (o operand) value(entry *Entry) (string, bool) {
+	switch o.field {
+	case "":
+		return o.literal, true
+	case "level":
+		return entry.Label, entry.Label != ""
+	case "name":
+		return entry.Name, true
+	case "msg":
+		return entry.Msg, true
+	case "time":
+		return entry.Time, entry.Time != ""
+	}
+	value, ok := entry.Get(strings.TrimPrefix(o.field, "fields."))
+	if !ok {
+		return "", false
+	}
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+	return fmt.Sprint(value), true
+}
+
+type node interface {
+	eval(entry *Entry) bool
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(entry *Entry) bool {
+	return n.left.eval(entry) || n.right.eval(entry)
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(entry *Entry) bool {
+	return n.left.eval(entry) && n.right.eval(entry)
+}
+
+type notNode struct{ n node }
+
+func (n notNode) eval(entry *Entry) bool {
+	return !n.n.eval(entry)
+}
+
+type existsNode struct{ field string }
+
+func (n existsNode) eval(entry *Entry) bool {
+	_, ok := operand{field: n.field}.value(entry)
+	return ok
+}
+
+type compareNode struct {
+	op          string
+	left, right operand
+	re          *regexp.Regexp
+}
+
+func (n compareNode) eval(entry *Entry) bool {
+	a, aok := n.left.value(entry)
+	b, bok := n.right.value(entry)
+	if !aok || !bok {
+		return n.op == "!="
+	}
+	if n.re != nil {
+		return n.re.MatchString(a)
+	}
+	var cmp int
+	if n.left.field == "level" || n.right.field == "level" {
+		// compare severity, more severe levels are lower
+		la, aok := log.ParseLevel(a)
+		lb, bok := log.ParseLevel(b)
+		if !aok || !bok {
+			return n.op == "!="
+		}
+		cmp = compareFloats(float64(lb), float64(la))
+	} else if fa, err := strconv.ParseFloat(a, 64); err == nil {
+		if fb, err := strconv.ParseFloat(b, 64); err == nil {
+			cmp = compareFloats(fa, fb)
+		} else {
+			cmp = strings.Compare(a, b)
+		}
+	} else {
+		cmp = strings.Compare(a, b)
+	}
+	switch n.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
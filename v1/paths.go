@@ -0,0 +1,57 @@
+package log
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var modCacheDir = filepath.Join("pkg", "mod") + string(os.PathSeparator)
+var goRootSrc = filepath.Join(runtime.GOROOT(), "src") + string(os.PathSeparator)
+var goPathSrc = filepath.Join(build.Default.GOPATH, "src") + string(os.PathSeparator)
+
+// shortenPath strips machine specific prefixes from filename, leaving paths
+// which are identical across build machines:
+//
+//	/home/ci/go/pkg/mod/github.com/pkg/errors@v0.9.1/errors.go => github.com/pkg/errors@v0.9.1/errors.go
+//	/usr/local/go/src/net/http/server.go                       => net/http/server.go
+//	/home/ci/go/src/github.com/mgutz/logxi/v1/logger.go        => github.com/mgutz/logxi/v1/logger.go
+func shortenPath(filename string) string {
+	if idx := strings.Index(filename, modCacheDir); idx > -1 {
+		return filepath.ToSlash(filename[idx+len(modCacheDir):])
+	}
+	if strings.HasPrefix(filename, goRootSrc) {
+		return filepath.ToSlash(filename[len(goRootSrc):])
+	}
+	if build.Default.GOPATH != "" && strings.HasPrefix(filename, goPathSrc) {
+		return filepath.ToSlash(filename[len(goPathSrc):])
+	}
+	return filename
+}
+
+// shortenStack shortens file paths in the output of runtime/debug.Stack().
+// Paths are on lines indented by a tab and followed by the line number.
+func shortenStack(stack []byte) string {
+	lines := strings.Split(string(stack), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		idx := strings.LastIndex(line, ":")
+		if idx < 0 {
+			continue
+		}
+		lines[i] = "\t" + shortenPath(line[1:idx]) + line[idx:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stackString returns the current goroutine's stack honoring shortPaths.
+func stackString(stack []byte) string {
+	if cfg().shortPaths {
+		return shortenStack(stack)
+	}
+	return string(stack)
+}
@@ -0,0 +1,191 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+const defaultMaxDepth = 4
+const defaultMaxSize = 2048
+
+// isComplex determines if a JSON decoded value should be expanded. Small flat
+// values are still printed inline.
+func isComplex(val interface{}) bool {
+	var children []interface{}
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			children = append(children, child)
+		}
+	case []interface{}:
+		children = v
+	default:
+		return false
+	}
+	size := 0
+	for _, child := range children {
+		switch c := child.(type) {
+		case map[string]interface{}, []interface{}:
+			return true
+		case string:
+			size += len(c)
+		default:
+			size += 8
+		}
+	}
+	return size > cfg().maxCol
+}
+
+// summarizeComplex returns a short inline description of an expanded value.
+func summarizeComplex(val interface{}) string {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return "{" + strconv.Itoa(len(v)) + " keys}"
+	case []interface{}:
+		return "[" + strconv.Itoa(len(v)) + " items]"
+	}
+	return ""
+}
+
+type prettyFrame struct {
+	object  bool
+	n       int
+	wantKey bool
+}
+
+// prettyJSON returns val as indented, colored JSON. Keys keep the order in
+// which they were marshaled. Values nested deeper than maxDepth are elided and
+// output stops after approximately maxSize bytes.
+func prettyJSON(val interface{}) string {
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err.Error()
+	}
+	reset := ansi.Reset
+	if cfg().disableColors {
+		reset = ""
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	buf := pool.Get()
+	defer pool.Put(buf)
+
+	var stack []*prettyFrame
+	var newline = func() {
+		buf.WriteRune('\n')
+		buf.WriteString(strings.Repeat(indent, len(stack)))
+	}
+	var valueDone = func() {
+		if len(stack) > 0 && stack[len(stack)-1].object {
+			stack[len(stack)-1].wantKey = true
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			buf.WriteString(err.Error())
+			break
+		}
+		if buf.Len() > cfg().maxSize {
+			newline()
+			buf.WriteString("…")
+			break
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			n := stack[len(stack)-1].n
+			stack = stack[:len(stack)-1]
+			if n > 0 {
+				newline()
+			}
+			buf.WriteString(d.String())
+			valueDone()
+			continue
+		}
+
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.object && top.wantKey {
+				if top.n > 0 {
+					buf.WriteRune(',')
+				}
+				newline()
+				key, _ := json.Marshal(tok)
+				buf.WriteString(cfg().theme.Key)
+				buf.Write(key)
+				buf.WriteString(reset)
+				buf.WriteString(": ")
+				top.wantKey = false
+				top.n++
+				continue
+			}
+			if !top.object {
+				if top.n > 0 {
+					buf.WriteRune(',')
+				}
+				newline()
+				top.n++
+			}
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			if len(stack) >= cfg().maxDepth {
+				// skip the nested value
+				if t == '{' {
+					buf.WriteString("{…}")
+				} else {
+					buf.WriteString("[…]")
+				}
+				for depth := 1; depth > 0; {
+					tok, err := dec.Token()
+					if err != nil {
+						break
+					}
+					if d, ok := tok.(json.Delim); ok {
+						if d == '{' || d == '[' {
+							depth++
+						} else {
+							depth--
+						}
+					}
+				}
+				valueDone()
+				continue
+			}
+			buf.WriteString(t.String())
+			stack = append(stack, &prettyFrame{object: t == '{', wantKey: t == '{'})
+		case string:
+			s, _ := json.Marshal(t)
+			buf.WriteString(cfg().theme.Value)
+			buf.Write(s)
+			buf.WriteString(reset)
+			valueDone()
+		case json.Number:
+			buf.WriteString(cfg().theme.Misc)
+			buf.WriteString(t.String())
+			buf.WriteString(reset)
+			valueDone()
+		case bool:
+			buf.WriteString(cfg().theme.Misc)
+			buf.WriteString(strconv.FormatBool(t))
+			buf.WriteString(reset)
+			valueDone()
+		case nil:
+			buf.WriteString(cfg().theme.Misc)
+			buf.WriteString("null")
+			buf.WriteString(reset)
+			valueDone()
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+)
+
+// ProfileLabels returns the fields of the logger of ctx, see FromContext,
+// named by keys as pprof labels. Values are formatted with fmt.Sprint.
+// Unbound keys are skipped.
+func ProfileLabels(ctx context.Context, keys ...string) pprof.LabelSet {
+	fields := boundFields(FromContext(ctx))
+	var labels []string
+	for _, key := range keys {
+		if value, ok := boundValue(fields, key); ok {
+			labels = append(labels, key, fmt.Sprint(value))
+		}
+	}
+	return pprof.Labels(labels...)
+}
+
+// WithProfileLabels calls fn with the goroutine labeled with the fields of
+// the logger of ctx named by keys, so CPU profiles can be sliced by the
+// identifiers in the logs. Goroutines started by fn inherit the labels.
+//
+// Example
+//
+//	log.WithProfileLabels(ctx, []string{"request_id", "handler"}, func(ctx context.Context) {
+//		process(ctx)
+//	})
+//
+//	go tool pprof -tagfocus request_id=r42 cpu.pprof
+func WithProfileLabels(ctx context.Context, keys []string, fn func(ctx context.Context)) {
+	pprof.Do(ctx, ProfileLabels(ctx, keys...), fn)
+}
+
+// ProfileLabelsHandler wraps next so each request is labeled with the
+// fields of its logger named by keys. Wrap it with the handlers binding the
+// fields, eg BaggageHandler.
+//
+// Example
+//
+//	http.Handle("/", log.BaggageHandler(log.ProfileLabelsHandler(mux, "request_id"), "request_id"))
+func ProfileLabelsHandler(next http.Handler, keys ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WithProfileLabels(r.Context(), keys, func(ctx context.Context) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
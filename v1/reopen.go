@@ -0,0 +1,68 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// reopener is implemented by writers which can reopen their file after it
+// was moved, eg by logrotate
+type reopener interface {
+	Reopen() error
+}
+
+var signalReopenOnce sync.Once
+
+// EnableSignalReopen reopens the files of registered loggers on SIGHUP, so
+// logrotate can move log files without copytruncate. Writers reopened
+// include FileWriter, RotatingFileWriter and those behind an AsyncWriter or
+// MultiSink. It does nothing on Windows.
+//
+// Example
+//
+//	func main() {
+//		log.EnableSignalReopen()
+//		...
+//	}
+//
+//	# logrotate.conf
+//	/var/log/app.log {
+//		postrotate
+//			kill -HUP $(pidof yourapp)
+//		endscript
+//	}
+func EnableSignalReopen() {
+	if reopenSignal == nil {
+		return
+	}
+	signalReopenOnce.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, reopenSignal)
+		go func() {
+			for range c {
+				Reopen()
+			}
+		}()
+	})
+}
+
+// Reopen reopens the files of registered loggers, see EnableSignalReopen.
+// Writers which fail to reopen keep writing to the old file. The first
+// error is returned and every error is logged to InternalLog.
+func Reopen() error {
+	var firstErr error
+	for _, writer := range loggerWriters() {
+		r, ok := unwrapWriter(writer).(reopener)
+		if !ok {
+			continue
+		}
+		if err := r.Reopen(); err != nil {
+			InternalLog.Error("Could not reopen log file", "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,182 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RingEntry is a structured entry kept by RingBuffer.
+type RingEntry struct {
+	Time   time.Time              `json:"time"`
+	Level  int                    `json:"level"`
+	Name   string                 `json:"name"`
+	Msg    string                 `json:"msg"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// RingQuery selects entries from a RingBuffer. Zero values match
+// everything.
+type RingQuery struct {
+	// Level matches entries of this level or more severe, eg LevelWarn
+	Level int
+	// Since and Until bound the time of entries
+	Since time.Time
+	Until time.Time
+	// Name is a logger name pattern as used in LOGXI, eg "api*"
+	Name string
+	// Fields must all be present with values which print the same
+	Fields map[string]string
+	// Limit returns only the most recent matching entries
+	Limit int
+}
+
+func (q *RingQuery) matches(e *RingEntry) bool {
+	if q.Level != 0 && e.Level > q.Level {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.Name != "" && !matchName(q.Name, e.Name) {
+		return false
+	}
+	for key, value := range q.Fields {
+		v, ok := e.Fields[key]
+		if !ok || fmt.Sprint(v) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// RingBuffer keeps the most recent entries of the loggers attached to it in
+// memory so they can be queried, eg by an embedded admin UI or a crash
+// report.
+//
+// Example
+//
+//	ring := log.NewRingBuffer(1000)
+//	logger := log.New("api")
+//	ring.Attach(logger)
+//	...
+//	recentErrors := ring.Query(log.RingQuery{Level: log.LevelError, Since: time.Now().Add(-5 * time.Minute)})
+type RingBuffer struct {
+	sync.Mutex
+	entries []RingEntry
+	next    int
+	full    bool
+}
+
+// NewRingBuffer creates a ring buffer holding up to size entries.
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &RingBuffer{entries: make([]RingEntry, size)}
+}
+
+// Formatter wraps formatter so entries are recorded by the ring buffer then
+// formatted as usual.
+func (rb *RingBuffer) Formatter(name string, formatter Formatter) Formatter {
+	return &ringFormatter{ring: rb, name: name, formatter: formatter}
+}
+
+// Attach records entries logged by logger. Only loggers created by this
+// package can be attached.
+func (rb *RingBuffer) Attach(logger Logger) {
+	if l, ok := logger.(*DefaultLogger); ok {
+		l.SetFormatter(rb.Formatter(l.name, l.getFormatter()))
+	}
+}
+
+func (rb *RingBuffer) add(entry RingEntry) {
+	rb.Lock()
+	rb.entries[rb.next] = entry
+	rb.next = (rb.next + 1) % len(rb.entries)
+	if rb.next == 0 {
+		rb.full = true
+	}
+	rb.Unlock()
+}
+
+// Entries returns all entries oldest first.
+func (rb *RingBuffer) Entries() []RingEntry {
+	return rb.Query(RingQuery{})
+}
+
+// Query returns matching entries oldest first.
+func (rb *RingBuffer) Query(q RingQuery) []RingEntry {
+	rb.Lock()
+	defer rb.Unlock()
+	var ordered []RingEntry
+	if rb.full {
+		ordered = append(ordered, rb.entries[rb.next:]...)
+	}
+	ordered = append(ordered, rb.entries[:rb.next]...)
+
+	result := []RingEntry{}
+	for i := range ordered {
+		if q.matches(&ordered[i]) {
+			result = append(result, ordered[i])
+		}
+	}
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[len(result)-q.Limit:]
+	}
+	return result
+}
+
+// Reset removes all entries.
+func (rb *RingBuffer) Reset() {
+	rb.Lock()
+	rb.entries = make([]RingEntry, len(rb.entries))
+	rb.next = 0
+	rb.full = false
+	rb.Unlock()
+}
+
+type ringFormatter struct {
+	ring      *RingBuffer
+	name      string
+	formatter Formatter
+}
+
+func (rf *ringFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	rf.ring.add(RingEntry{
+		Time:   time.Now(),
+		Level:  level,
+		Name:   rf.name,
+		Msg:    msg,
+		Fields: argsToMap(args),
+	})
+	formatNamed(rf.formatter, writer, level, rf.name, msg, args)
+}
+
+// argsToMap converts key/value pairs to a map using the same keys as the
+// formatters for single and imbalanced args.
+func argsToMap(args []interface{}) map[string]interface{} {
+	lenArgs := len(args)
+	if lenArgs == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, lenArgs/2+1)
+	if lenArgs == 1 {
+		m[singleArgKey] = args[0]
+	} else if lenArgs%2 == 0 {
+		for i := 0; i < lenArgs; i += 2 {
+			if key, ok := args[i].(string); ok && key != "" {
+				m[key] = args[i+1]
+			} else {
+				m[badKeyAtIndex(i)] = args[i+1]
+			}
+		}
+	} else {
+		m[warnImbalancedKey] = args
+	}
+	return m
+}
@@ -0,0 +1,321 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the time format of rotated files, which sorts in time
+// order
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotateRetry is how long a writer whose rotation failed keeps writing to
+// the current file before retrying
+const rotateRetry = time.Minute
+
+// RotateOptions determines when a RotatingFileWriter rotates and which
+// rotated files it keeps.
+type RotateOptions struct {
+	// MaxSize rotates before a write would make the file larger than
+	// MaxSize bytes, 0 for no limit
+	MaxSize int64
+	// Daily rotates on the first write of a new day
+	Daily bool
+	// MaxBackups is the number of rotated files kept, 0 keeps every file
+	MaxBackups int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// RotatingFileWriter is a concurrent safe writer which appends entries to a
+// file and rotates it by size or daily. Rotated files are renamed with the
+// time of rotation, eg app-2024-05-17T15-04-05.000.log, and optionally
+// gzipped in the background.
+//
+// Pass a JSON or text formatter as in the example since the format selected
+// for a terminal colors entries.
+//
+// Example
+//
+//	rw, err := log.NewRotatingFileWriter("logs/app.log", log.RotateOptions{
+//		MaxSize:    100 << 20,
+//		MaxBackups: 7,
+//		Compress:   true,
+//	})
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer rw.Close()
+//	logger := log.NewLogger3(rw, "app", log.NewJSONFormatter("app"))
+type RotatingFileWriter struct {
+	path string
+	opts RotateOptions
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	// retryAt is when a failed rotation is retried
+	retryAt time.Time
+	health  sinkHealth
+
+	// compressing tracks background compression so Close can wait for it
+	compressing sync.WaitGroup
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// NewRotatingFileWriter creates a writer appending to path. The file is
+// opened immediately so configuration errors are reported up front.
+func NewRotatingFileWriter(path string, opts RotateOptions) (*RotatingFileWriter, error) {
+	rw := &RotatingFileWriter{path: path, opts: opts, now: time.Now}
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// open opens or creates the file. An existing file counts from the day it
+// was last written. The lock must be held.
+func (rw *RotatingFileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(rw.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(rw.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rw.file = file
+	rw.size = info.Size()
+	rw.opened = rw.now()
+	if rw.size > 0 {
+		rw.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (rw *RotatingFileWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file == nil {
+		return 0, ErrWriterClosed
+	}
+	if rw.due(int64(len(p))) && !rw.now().Before(rw.retryAt) {
+		if err := rw.rotate(); err != nil {
+			// keep writing to the current file rather than losing entries
+			rw.retryAt = rw.now().Add(rotateRetry)
+			InternalLog.Error("Could not rotate log file", "file", rw.path, "err", err)
+		}
+	}
+	n, err := rw.file.Write(p)
+	rw.size += int64(n)
+	return n, rw.health.record(err)
+}
+
+// Health reports whether the file is open and the last error.
+func (rw *RotatingFileWriter) Health() []SinkStatus {
+	rw.mu.Lock()
+	open := rw.file != nil
+	rw.mu.Unlock()
+	return []SinkStatus{rw.health.status("file "+rw.path, open, 0, 0)}
+}
+
+// due reports whether the file must be rotated before writing n bytes. An
+// entry larger than MaxSize is written to an empty file rather than lost.
+func (rw *RotatingFileWriter) due(n int64) bool {
+	if rw.size == 0 {
+		return false
+	}
+	if rw.opts.MaxSize > 0 && rw.size+n > rw.opts.MaxSize {
+		return true
+	}
+	if rw.opts.Daily {
+		y1, m1, d1 := rw.opened.Date()
+		y2, m2, d2 := rw.now().Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// Rotate rotates the file now, eg on a schedule or a signal.
+func (rw *RotatingFileWriter) Rotate() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file == nil {
+		return ErrWriterClosed
+	}
+	return rw.rotate()
+}
+
+// rotate renames the file, opens a new one and removes old backups. The
+// current file is closed once the new one is open so it is still written
+// if the rotation fails. The lock must be held.
+func (rw *RotatingFileWriter) rotate() error {
+	old := rw.file
+	backup := rw.backupName(rw.now())
+	err := os.Rename(rw.path, backup)
+	if os.IsNotExist(err) {
+		// removed or moved by a previous rotation which couldn't open the
+		// new file, there is nothing to back up
+		backup = ""
+	} else if err != nil {
+		return err
+	}
+	if err := rw.open(); err != nil {
+		// entries keep going to the renamed file
+		return err
+	}
+	if err := old.Close(); err != nil {
+		InternalLog.Error("Could not close rotated log file", "file", rw.path, "err", err)
+	}
+	if backup == "" {
+		return nil
+	}
+
+	if rw.opts.Compress {
+		rw.compressing.Add(1)
+		go func() {
+			defer rw.compressing.Done()
+			if err := compressFile(backup); err != nil {
+				InternalLog.Error("Could not compress rotated log file", "file", backup, "err", err)
+			}
+			rw.removeBackups()
+		}()
+		return nil
+	}
+	rw.removeBackups()
+	return nil
+}
+
+// backupName returns an unused name for a file rotated at t.
+func (rw *RotatingFileWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(rw.path)
+	for {
+		backup := strings.TrimSuffix(rw.path, ext) + "-" + t.Format(backupTimeFormat) + ext
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			if _, err := os.Stat(backup + ".gz"); os.IsNotExist(err) {
+				return backup
+			}
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// removeBackups removes the oldest rotated files beyond MaxBackups.
+func (rw *RotatingFileWriter) removeBackups() {
+	if rw.opts.MaxBackups <= 0 {
+		return
+	}
+	backups := rw.Backups()
+	for i := 0; i < len(backups)-rw.opts.MaxBackups; i++ {
+		if err := os.Remove(backups[i]); err != nil && !os.IsNotExist(err) {
+			InternalLog.Error("Could not remove rotated log file", "file", backups[i], "err", err)
+		}
+	}
+}
+
+// Backups returns the paths of the rotated files, oldest first.
+func (rw *RotatingFileWriter) Backups() []string {
+	ext := filepath.Ext(rw.path)
+	prefix := filepath.Base(strings.TrimSuffix(rw.path, ext)) + "-"
+	entries, err := ioutil.ReadDir(filepath.Dir(rw.path))
+	if err != nil {
+		return nil
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(rw.path), name))
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// compressFile gzips filename to filename.gz and removes filename.
+func compressFile(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(filename+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+	return os.Remove(filename)
+}
+
+// Reopen reopens the file at its path, eg after an external tool moved
+// it. If the file can't be opened, entries are still written to the old
+// one.
+func (rw *RotatingFileWriter) Reopen() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file == nil {
+		// nothing to reopen
+		return nil
+	}
+	old := rw.file
+	if err := rw.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+// Sync commits the file to stable storage.
+func (rw *RotatingFileWriter) Sync() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file == nil {
+		return nil
+	}
+	return rw.file.Sync()
+}
+
+// Close syncs and closes the file, waiting for rotated files to be
+// compressed. Later writes fail with ErrWriterClosed.
+func (rw *RotatingFileWriter) Close() error {
+	rw.mu.Lock()
+	var err error
+	if rw.file != nil {
+		err = rw.file.Sync()
+		if cerr := rw.file.Close(); err == nil {
+			err = cerr
+		}
+		rw.file = nil
+	}
+	rw.mu.Unlock()
+	rw.compressing.Wait()
+	return err
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"time"
+)
+
+// SelfTestResult is the delivery result of one self-test entry.
+type SelfTestResult struct {
+	Level int
+	Label string
+	// Enabled is whether the configuration logs entries at Level
+	Enabled bool
+	// Bytes is the number of bytes written to the sink
+	Bytes int
+	Err   error
+}
+
+// SelfTest writes an entry at every level through the writer and formatter
+// the configuration selects for the logger name, regardless of its level,
+// then flushes the writer, waiting up to FlushTimeout. Use it to check the
+// logging of a new deployment before it takes traffic. The error is that of
+// the flush.
+//
+// Example
+//
+//	results, err := log.SelfTest("api")
+//	for _, r := range results {
+//		if r.Err != nil {
+//			fmt.Println(r.Label, "failed:", r.Err)
+//		}
+//	}
+func SelfTest(name string) ([]SelfTestResult, error) {
+	name = sanitizeName(name)
+	writer := colorableStdout
+	configWriters.Lock()
+	if w := configWriter(name); w != nil {
+		writer = w
+	}
+	configWriters.Unlock()
+	formatter, err := createFormatter(name, cfg().logxiFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	var levels []int
+	for level := range LevelMap {
+		if level != LevelOff && level != LevelAll {
+			levels = append(levels, level)
+		}
+	}
+	sort.Ints(levels)
+
+	configured := getLogLevel(name)
+	results := make([]SelfTestResult, 0, len(levels))
+	var buf bytes.Buffer
+	for _, level := range levels {
+		buf.Reset()
+		formatEntry(formatter, &buf, level, name, "logxi self-test", []interface{}{"level", LevelMap[level]}, stackOptions{})
+		n, err := writeLevel(writer, level, buf.Bytes())
+		if err == nil && n < buf.Len() {
+			err = io.ErrShortWrite
+		}
+		results = append(results, SelfTestResult{
+			Level:   level,
+			Label:   LevelMap[level],
+			Enabled: configured != LevelOff && level <= configured,
+			Bytes:   n,
+			Err:     err,
+		})
+	}
+	return results, flushWriter(writer, time.Now().Add(FlushTimeout))
+}
+
+// writeLevel writes p logged at level, passing the level on to writers
+// which implement WriteLevel.
+func writeLevel(writer io.Writer, level int, p []byte) (int, error) {
+	if lw, ok := unwrapWriter(writer).(levelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return writer.Write(p)
+}
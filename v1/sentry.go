@@ -0,0 +1,343 @@
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SentryOptions configures a SentrySink.
+type SentryOptions struct {
+	// SampleRate is the fraction of entries sent, eg 0.25. Zero sends every
+	// entry.
+	SampleRate float64
+	// Environment and Release tag events, eg "prod" and a git revision
+	Environment string
+	Release     string
+	// QueueSize is the number of events waiting to be sent, 100 if zero.
+	// Events are dropped when the queue is full.
+	QueueSize int
+}
+
+// SentrySink forwards error and more severe entries to Sentry as events,
+// so errors alert without instrumenting call sites twice. The stack an
+// error recorded, eg with github.com/go-errors/errors, or else the entry's
+// call stack is the event's stacktrace and key-value pairs are its extra
+// context. Less severe entries are ignored.
+//
+// SentrySink is both the formatter and the writer of a sink. Events are
+// sent in the background, Flush waits for them, eg before exiting.
+//
+// Example
+//
+//	sentry, err := log.NewSentrySink(os.Getenv("SENTRY_DSN"), log.SentryOptions{SampleRate: 0.5})
+//	if err != nil {
+//		panic(err)
+//	}
+//	sink := log.NewMultiSink(
+//		log.Sink{Writer: log.NewConcurrentWriter(os.Stdout), Formatter: log.NewJSONFormatter("api")},
+//		log.Sink{Writer: sentry, Formatter: sentry, Level: log.LevelError},
+//	)
+//	logger := log.New("api", log.WithFormatter(sink))
+type SentrySink struct {
+	endpoint   string
+	auth       string
+	opts       SentryOptions
+	serverName string
+	client     *http.Client
+	jf         *JSONFormatter
+
+	queue   chan sentryEvent
+	pending sync.WaitGroup
+	dropped uint64
+	health  sinkHealth
+
+	// mu is held for reading while an event is queued so Close can't close
+	// the queue between the check of closed and the send
+	mu     sync.RWMutex
+	closed bool
+}
+
+// sentryEvent is a queued event and its ID, the first field of the event.
+type sentryEvent struct {
+	id   []byte
+	body []byte
+}
+
+const sentryEventPrefix = `{"event_id":"`
+
+// NewSentrySink creates a sink sending events to the project of dsn, eg
+// "https://key@o1.ingest.sentry.io/42".
+func NewSentrySink(dsn string, opts SentryOptions) (*SentrySink, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("logxi: invalid Sentry DSN: %v", err)
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndexByte(path, '/')
+	if u.User == nil || u.User.Username() == "" || u.Host == "" || i < 0 || path[i+1:] == "" {
+		return nil, errors.New("logxi: invalid Sentry DSN, expected scheme://key@host/project")
+	}
+	project := path[i+1:]
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	hostname, _ := os.Hostname()
+	ss := &SentrySink{
+		endpoint:   u.Scheme + "://" + u.Host + path[:i] + "/api/" + project + "/envelope/",
+		auth:       "Sentry sentry_version=7, sentry_client=logxi/" + Version + ", sentry_key=" + u.User.Username(),
+		opts:       opts,
+		serverName: hostname,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		jf:         NewJSONFormatter("sentry"),
+		queue:      make(chan sentryEvent, opts.QueueSize),
+	}
+	go ss.run()
+	return ss, nil
+}
+
+// sentryLevel returns the Sentry level of an error or more severe level.
+func sentryLevel(level int) string {
+	if level < LevelError {
+		return "fatal"
+	}
+	return "error"
+}
+
+// Format writes an event without the entry's call stack.
+func (ss *SentrySink) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	ss.format(writer, time.Now(), level, "", msg, args, nil)
+}
+
+// FormatEntry writes an event with the entry's call stack, if captured.
+func (ss *SentrySink) FormatEntry(writer io.Writer, entry *Entry) {
+	ss.format(writer, entry.Time, entry.Level, entry.Name, entry.Msg, entry.Fields, entry.Stack)
+}
+
+func (ss *SentrySink) format(writer io.Writer, t time.Time, level int, name string, msg string, args []interface{}, stack []Frame) {
+	if level > LevelError {
+		return
+	}
+	if ss.opts.SampleRate > 0 && mrand.Float64() >= ss.opts.SampleRate {
+		return
+	}
+	buf := pool.Get()
+	defer pool.Put(buf)
+	jf := ss.jf
+
+	var id [16]byte
+	rand.Read(id[:])
+	buf.WriteString(sentryEventPrefix)
+	buf.WriteString(hex.EncodeToString(id[:]))
+	buf.WriteString(`","timestamp":"`)
+	buf.WriteString(t.UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`","platform":"go","level":"`)
+	buf.WriteString(sentryLevel(level))
+	buf.WriteString(`","message":`)
+	jf.writeString(buf, stripANSI(msg))
+	if name != "" {
+		buf.WriteString(`,"logger":`)
+		jf.writeString(buf, name)
+	}
+	buf.WriteString(`,"server_name":`)
+	jf.writeString(buf, ss.serverName)
+	if ss.opts.Environment != "" {
+		buf.WriteString(`,"environment":`)
+		jf.writeString(buf, ss.opts.Environment)
+	}
+	if ss.opts.Release != "" {
+		buf.WriteString(`,"release":`)
+		jf.writeString(buf, ss.opts.Release)
+	}
+
+	if len(args) == 1 {
+		args = []interface{}{singleArgKey, args[0]}
+	}
+	if len(args)%2 != 0 {
+		args = []interface{}{warnImbalancedKey, fmt.Sprint(args)}
+	}
+	var err error
+	for i := 1; i < len(args) && err == nil; i += 2 {
+		err, _ = args[i].(error)
+	}
+
+	// the stack the error recorded is where it happened, the entry's stack
+	// is where it was logged
+	frames := sourceFrames(stack, true)
+	if err != nil {
+		if errFrames := errorFrames(err); len(errFrames) > 0 {
+			frames = errFrames
+		}
+	}
+	buf.WriteString(`,"exception":{"values":[{"type":`)
+	if err != nil {
+		jf.writeString(buf, fmt.Sprintf("%T", err))
+		buf.WriteString(`,"value":`)
+		jf.writeString(buf, stripANSI(err.Error()))
+	} else {
+		jf.writeString(buf, msg)
+		buf.WriteString(`,"value":`)
+		jf.writeString(buf, msg)
+	}
+	if len(frames) > 0 {
+		// Sentry lists the outermost frame first
+		buf.WriteString(`,"stacktrace":{"frames":[`)
+		for i := len(frames) - 1; i >= 0; i-- {
+			frame := frames[i]
+			if i < len(frames)-1 {
+				buf.WriteRune(',')
+			}
+			buf.WriteString(`{"function":`)
+			jf.writeString(buf, frame.method)
+			buf.WriteString(`,"abs_path":`)
+			jf.writeString(buf, frame.filename)
+			buf.WriteString(`,"lineno":`)
+			buf.WriteString(strconv.Itoa(frame.lineno))
+			buf.WriteRune('}')
+		}
+		buf.WriteString(`]}`)
+	}
+	buf.WriteString(`}]}`)
+
+	if len(args) > 0 {
+		buf.WriteString(`,"extra":{`)
+		for i := 0; i < len(args); i += 2 {
+			if i > 0 {
+				buf.WriteRune(',')
+			}
+			key, ok := args[i].(string)
+			if !ok || key == "" {
+				key = badKeyAtIndex(i)
+			}
+			jf.writeString(buf, key)
+			buf.WriteRune(':')
+			if e, ok := args[i+1].(error); ok {
+				jf.writeString(buf, stripANSI(e.Error()))
+			} else {
+				jf.appendValue(buf, args[i+1])
+			}
+		}
+		buf.WriteRune('}')
+	}
+	buf.WriteString("}\n")
+	buf.WriteTo(writer)
+}
+
+// Write queues an event written by Format or FormatEntry to be sent. The
+// event is dropped if the queue is full. Anything else is rejected.
+func (ss *SentrySink) Write(p []byte) (int, error) {
+	body := bytes.TrimSpace(p)
+	if len(body) < len(sentryEventPrefix)+32 || !bytes.HasPrefix(body, []byte(sentryEventPrefix)) {
+		return 0, errors.New("logxi: SentrySink writes events formatted by SentrySink")
+	}
+	body = append([]byte(nil), body...)
+	event := sentryEvent{id: body[len(sentryEventPrefix) : len(sentryEventPrefix)+32], body: body}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	if ss.closed {
+		return 0, ErrWriterClosed
+	}
+	ss.pending.Add(1)
+	select {
+	case ss.queue <- event:
+		return len(p), nil
+	default:
+		ss.pending.Done()
+		atomic.AddUint64(&ss.dropped, 1)
+		return 0, ss.health.record(errors.New("logxi: Sentry queue is full"))
+	}
+}
+
+func (ss *SentrySink) run() {
+	for event := range ss.queue {
+		if err := ss.health.record(ss.send(event)); err != nil {
+			atomic.AddUint64(&ss.dropped, 1)
+		}
+		ss.pending.Done()
+	}
+}
+
+// send posts an event as an envelope.
+func (ss *SentrySink) send(event sentryEvent) error {
+	var body bytes.Buffer
+	body.WriteString(sentryEventPrefix)
+	body.Write(event.id)
+	body.WriteString(`","sent_at":"`)
+	body.WriteString(time.Now().UTC().Format(time.RFC3339Nano))
+	body.WriteString("\"}\n")
+	fmt.Fprintf(&body, `{"type":"event","length":%d}`+"\n", len(event.body))
+	body.Write(event.body)
+	body.WriteRune('\n')
+
+	req, err := http.NewRequest("POST", ss.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", ss.auth)
+	resp, err := ss.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("logxi: Sentry responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Flush waits until queued events are sent.
+func (ss *SentrySink) Flush(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		ss.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return ErrFlushTimeout
+	}
+}
+
+// Close sends queued events and stops the sink. Later writes fail with
+// ErrWriterClosed.
+func (ss *SentrySink) Close() error {
+	ss.mu.Lock()
+	if !ss.closed {
+		ss.closed = true
+		close(ss.queue)
+	}
+	ss.mu.Unlock()
+	return ss.Flush(FlushTimeout)
+}
+
+// Health reports whether the sink is open, the number of queued events, the
+// events dropped and the last error.
+func (ss *SentrySink) Health() []SinkStatus {
+	ss.mu.RLock()
+	connected := !ss.closed
+	ss.mu.RUnlock()
+	return []SinkStatus{ss.health.status("sentry", connected, len(ss.queue), ss.Dropped())}
+}
+
+// Dropped returns the number of events dropped because the queue was full
+// or sending failed.
+func (ss *SentrySink) Dropped() uint64 {
+	return atomic.LoadUint64(&ss.dropped)
+}
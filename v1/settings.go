@@ -0,0 +1,122 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// settings are the settings derived from LOGXI, LOGXI_FORMAT, LOGXI_COLORS
+// and LOGXI_SHOW. They are never modified once stored. ProcessEnv replaces
+// them as a whole, so entries logged while the configuration is reloaded
+// see either the previous or the new settings, never a mix.
+type settings struct {
+	// logxiNameLevelMap maps log name patterns to levels
+	logxiNameLevelMap map[string]int
+
+	// logxiFormat is the formatter kind to create
+	logxiFormat string
+	// requestedFormat is the formatter kind selected by LOGXI_FORMAT, which
+	// may be registered after the environment is processed
+	requestedFormat string
+	timeFormat      string
+	// isLogfmt makes TextFormatter quote keys and values per logfmt
+	isLogfmt bool
+	// assignmentChar and separator override AssignmentChar and Separator
+	// for the LTSV and logfmt formats
+	assignmentChar string
+	separator      string
+	isPretty       bool
+	maxCol         int
+	contextLines   int
+	// callerLevel is the least severe level whose caller is captured.
+	// Traces always capture the caller.
+	callerLevel int
+	// stackLevel is the least severe level whose call stack is captured
+	stackLevel int
+	// logCaller adds KeyMap.Caller to entries of machine formats. It is set
+	// when the caller option is configured.
+	logCaller bool
+	// datadogMode adds Datadog's status and trace correlation fields to
+	// JSON entries, see the datadog option of LOGXI_FORMAT
+	datadogMode bool
+	// showUptime adds KeyMap.Uptime, the monotonic seconds since the
+	// process started, to entries
+	showUptime bool
+	// showSchema adds KeyMap.Version to entries
+	showSchema bool
+	// firstSeenLevel is the least severe level annotated, 0 when disabled
+	firstSeenLevel int
+	// lintMode is set by the lint option of LOGXI_FORMAT
+	lintMode bool
+	// nameWidth is the width logger names are abbreviated and padded to by
+	// HappyDevFormatter. 0 prints names as is.
+	nameWidth int
+	// floatFormat is the float format of formatters created afterwards
+	floatFormat FloatFormat
+	// shortPaths rewrites stack frame paths to module-relative paths
+	shortPaths bool
+	// expandValues prints maps, slices and structs as indented JSON below
+	// the entry in HappyDevFormatter
+	expandValues bool
+	// maxDepth is the maximum depth of expanded values
+	maxDepth int
+	// maxSize is the approximate maximum size in bytes of an expanded value
+	maxSize int
+
+	theme         *colorScheme
+	disableColors bool
+	// showFields are the fields HappyDevFormatter prints, nil for all, see
+	// LOGXI_SHOW
+	showFields map[string]bool
+
+	// config is the configuration last passed to ProcessEnv
+	config Configuration
+}
+
+// currentSettings holds the *settings in use
+var currentSettings = func() *atomic.Value {
+	v := &atomic.Value{}
+	v.Store(&settings{
+		maxCol:      defaultMaxCol,
+		callerLevel: LevelWarn,
+		stackLevel:  LevelError,
+		floatFormat: DefaultFloatFormat,
+		maxDepth:    defaultMaxDepth,
+		maxSize:     defaultMaxSize,
+	})
+	return v
+}()
+
+// settingsMutex serializes updates of the settings
+var settingsMutex sync.Mutex
+
+// cfg returns the settings in use. Callers reading several settings for
+// one entry should call it once.
+func cfg() *settings {
+	return currentSettings.Load().(*settings)
+}
+
+// updateSettings stores a copy of the settings modified by fn.
+func updateSettings(fn func(s *settings)) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	s := *cfg()
+	fn(&s)
+	currentSettings.Store(&s)
+}
+
+// assignment returns the assignment character between keys and values.
+func (s *settings) assignment() string {
+	if s.assignmentChar != "" {
+		return s.assignmentChar
+	}
+	return AssignmentChar
+}
+
+// pairSeparator returns the separator between key-value pairs.
+func (s *settings) pairSeparator() string {
+	if s.separator != "" {
+		return s.separator
+	}
+	return Separator
+}
@@ -0,0 +1,48 @@
+package log
+
+import "strings"
+
+// showAliases map the names of built-in fields to their keys
+var showAliases = map[string]*string{
+	"t":    &KeyMap.Time,
+	"time": &KeyMap.Time,
+	"l":    &KeyMap.Level,
+	"lvl":  &KeyMap.Level,
+	"n":    &KeyMap.Name,
+	"name": &KeyMap.Name,
+	"m":    &KeyMap.Message,
+	"msg":  &KeyMap.Message,
+}
+
+// ProcessLogxiShowEnv parses LOGXI_SHOW, a comma separated list of the
+// fields HappyDevFormatter prints, eg "t,l,m,err". Built-in fields are t
+// (time), l (level), n (name) and m (message). Hidden fields are counted
+// in a "+3 fields" suffix. Empty shows every field.
+func ProcessLogxiShowEnv(env string) {
+	updateSettings(func(s *settings) { s.applyShowEnv(env) })
+}
+
+// applyShowEnv applies LOGXI_SHOW to s
+func (s *settings) applyShowEnv(env string) {
+	if strings.TrimSpace(env) == "" {
+		s.showFields = nil
+		return
+	}
+	fields := map[string]bool{}
+	for _, name := range strings.Split(env, ",") {
+		name = strings.TrimSpace(name)
+		if key, ok := showAliases[name]; ok {
+			name = *key
+		}
+		if name != "" {
+			fields[name] = true
+		}
+	}
+	s.showFields = fields
+}
+
+// isShown determines if HappyDevFormatter prints the field key.
+func isShown(key string) bool {
+	showFields := cfg().showFields
+	return showFields == nil || showFields[key]
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var signalLevelOnce sync.Once
+
+// EnableSignalLevelControl lets operators change levels of a running
+// process without a restart. SIGUSR1 raises every logger to Debug and
+// SIGUSR2 restores the configured levels. Loggers already logging Debug or
+// Trace entries are not changed. It does nothing on Windows.
+//
+// Example
+//
+//	func main() {
+//		log.EnableSignalLevelControl()
+//		...
+//	}
+//
+//	kill -USR1 $(pidof yourapp)
+func EnableSignalLevelControl() {
+	if debugSignal == nil {
+		return
+	}
+	signalLevelOnce.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, debugSignal, restoreSignal)
+		go func() {
+			for sig := range c {
+				if sig == debugSignal {
+					raiseLevels(LevelDebug)
+				} else {
+					restoreLevels()
+				}
+			}
+		}()
+	})
+}
+
+// raiseLevels sets registered loggers which don't log level entries to
+// level. InternalLog is not changed.
+func raiseLevels(level int) {
+	loggers.Lock()
+	defer loggers.Unlock()
+	for name, logger := range loggers.loggers {
+		if name == "__logxi" {
+			continue
+		}
+		if l, ok := logger.(*DefaultLogger); ok && l.getLevel() >= level {
+			continue
+		}
+		logger.SetLevel(level)
+	}
+}
+
+// restoreLevels sets registered loggers to their configured level.
+func restoreLevels() {
+	loggers.Lock()
+	defer loggers.Unlock()
+	for name, logger := range loggers.loggers {
+		if name != "__logxi" {
+			logger.SetLevel(getLogLevel(name))
+		}
+	}
+}
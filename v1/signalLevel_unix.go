@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+var debugSignal os.Signal = syscall.SIGUSR1
+var restoreSignal os.Signal = syscall.SIGUSR2
+var reopenSignal os.Signal = syscall.SIGHUP
@@ -0,0 +1,10 @@
+package log
+
+import "os"
+
+// Windows has no user signals
+var debugSignal os.Signal
+var restoreSignal os.Signal
+
+// logrotate doesn't run on Windows
+var reopenSignal os.Signal
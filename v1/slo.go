@@ -0,0 +1,99 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SLOKey is the key of the annotation added to entries matching an SLORule.
+var SLOKey = "slo"
+
+// SLORule annotates matching entries so SLO tooling can compute burn rates
+// from the log stream.
+type SLORule struct {
+	// Name is a logger name pattern as used in LOGXI, eg "api*"
+	Name string
+	// Level matches entries of this level or more severe, eg LevelError
+	Level int
+	// Key optionally matches entries having this key. If Value is not empty
+	// the value must also match.
+	Key   string
+	Value string
+	// SLO is the annotation, eg "availability"
+	SLO string
+}
+
+func (r *SLORule) matches(name string, level int, args []interface{}) bool {
+	return level <= r.Level && matchName(r.Name, name) && matchesField(r.Key, r.Value, args)
+}
+
+var sloMutex sync.Mutex
+
+// sloRules holds []SLORule. It is empty until a rule is added, which may
+// be after entries are logged from init functions.
+var sloRules atomic.Value
+
+// AddSLORule adds a rule which annotates matching entries with
+// SLOKey=rule.SLO.
+//
+// Example
+//
+//	// slo=availability on 5xx errors logged by any api logger
+//	log.AddSLORule(log.SLORule{Name: "api*", Level: log.LevelError, Key: "status", Value: "500", SLO: "availability"})
+func AddSLORule(rule SLORule) {
+	sloMutex.Lock()
+	defer sloMutex.Unlock()
+	rules, _ := sloRules.Load().([]SLORule)
+	updated := make([]SLORule, len(rules), len(rules)+1)
+	copy(updated, rules)
+	sloRules.Store(append(updated, rule))
+}
+
+// ClearSLORules removes all SLO rules.
+func ClearSLORules() {
+	sloMutex.Lock()
+	defer sloMutex.Unlock()
+	sloRules.Store([]SLORule(nil))
+}
+
+// annotateSLO appends the SLO annotation to args if any rule matches.
+func annotateSLO(name string, level int, args []interface{}) []interface{} {
+	rules, _ := sloRules.Load().([]SLORule)
+	if len(rules) == 0 {
+		return args
+	}
+	var slos []string
+	for i := range rules {
+		if rules[i].matches(name, level, args) {
+			slos = appendUnique(slos, rules[i].SLO)
+		}
+	}
+	if len(slos) == 0 {
+		return args
+	}
+	return appendArgs(args, SLOKey, strings.Join(slos, ","))
+}
+
+// appendArgs appends key/value pairs to args without modifying the caller's
+// slice. A single arg is converted to a pair first.
+func appendArgs(args []interface{}, kv ...interface{}) []interface{} {
+	if len(args) == 1 {
+		args = []interface{}{singleArgKey, args[0]}
+	} else if len(args)%2 != 0 {
+		// imbalanced pairs are logged as is
+		return args
+	}
+	result := make([]interface{}, 0, len(args)+len(kv))
+	result = append(result, args...)
+	return append(result, kv...)
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
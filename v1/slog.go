@@ -0,0 +1,306 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// SlogHandler is a slog.Handler writing records to a logxi logger, so
+// libraries using log/slog share logxi's formatters, themes and LOGXI
+// levels. Groups are logged as field groups, see Group.
+//
+// Example
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler(log.New("api"))))
+type SlogHandler struct {
+	logger Logger
+	// attrs are the key-value pairs outside groups
+	attrs  []interface{}
+	groups []slogGroup
+}
+
+type slogGroup struct {
+	name  string
+	attrs []interface{}
+}
+
+// NewSlogHandler creates a handler writing to logger.
+func NewSlogHandler(logger Logger) slog.Handler {
+	return &SlogHandler{logger: logger}
+}
+
+// slogLevel returns the logxi level of a slog level. Levels above error
+// are logged as errors, not fatal entries, since slog doesn't exit.
+func slogLevel(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	case level >= slog.LevelDebug:
+		return LevelDebug
+	}
+	return LevelTrace
+}
+
+// Enabled reports whether the logger logs level.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	switch slogLevel(level) {
+	case LevelError:
+		return true
+	case LevelWarn:
+		return h.logger.IsWarn()
+	case LevelInfo:
+		return h.logger.IsInfo()
+	case LevelDebug:
+		return h.logger.IsDebug()
+	}
+	return h.logger.IsTrace()
+}
+
+// Handle logs a record with the handler's attributes and the fields of
+// context extractors, see AddContextExtractor.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var args []interface{}
+	r.Attrs(func(a slog.Attr) bool {
+		args = appendSlogAttr(args, a)
+		return true
+	})
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		groupArgs := append(append([]interface{}(nil), g.attrs...), args...)
+		args = nil
+		// empty groups are omitted
+		if len(groupArgs) > 0 {
+			args = []interface{}{Group(g.name, groupArgs...)}
+		}
+	}
+	args = append(append([]interface{}(nil), h.attrs...), args...)
+	if ctx != nil {
+		if fields := extractContext(ctx); len(fields) > 0 {
+			args = append(fields, args...)
+		}
+	}
+	h.logger.Log(slogLevel(r.Level), r.Message, args)
+	return nil
+}
+
+// WithAttrs returns a handler adding attrs to records.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var args []interface{}
+	for _, a := range attrs {
+		args = appendSlogAttr(args, a)
+	}
+	if len(args) == 0 {
+		return h
+	}
+	h2 := h.clone()
+	if n := len(h2.groups); n > 0 {
+		h2.groups[n-1].attrs = append(append([]interface{}(nil), h2.groups[n-1].attrs...), args...)
+	} else {
+		h2.attrs = append(append([]interface{}(nil), h2.attrs...), args...)
+	}
+	return h2
+}
+
+// WithGroup returns a handler nesting later attributes in a group.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := h.clone()
+	h2.groups = append(h2.groups, slogGroup{name: name})
+	return h2
+}
+
+func (h *SlogHandler) clone() *SlogHandler {
+	return &SlogHandler{
+		logger: h.logger,
+		attrs:  h.attrs,
+		groups: append([]slogGroup(nil), h.groups...),
+	}
+}
+
+// appendSlogAttr appends an attribute as a key-value pair. Groups become
+// field groups, or are inlined if their key is empty.
+func appendSlogAttr(args []interface{}, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return args
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		return append(args, a.Key, a.Value.Any())
+	}
+	var groupArgs []interface{}
+	for _, ga := range a.Value.Group() {
+		groupArgs = appendSlogAttr(groupArgs, ga)
+	}
+	if len(groupArgs) == 0 {
+		return args
+	}
+	if a.Key == "" {
+		return append(args, groupArgs...)
+	}
+	return append(args, Group(a.Key, groupArgs...))
+}
+
+// SlogLoggerKey is the attribute key of the logger name in records written
+// by loggers created with NewSlogLogger.
+var SlogLoggerKey = "logger"
+
+// slogLogger is a Logger writing records to a slog.Handler
+type slogLogger struct {
+	handler slog.Handler
+	name    string
+	level   int32
+}
+
+// NewSlogLogger creates a logger writing records to handler, eg to use
+// logxi's API in a program which configures log/slog. The level is taken
+// from LOGXI like other loggers and records have the name as
+// SlogLoggerKey.
+//
+// Example
+//
+//	logger := log.NewSlogLogger(slog.Default().Handler(), "api")
+func NewSlogLogger(handler slog.Handler, name string) Logger {
+	name = sanitizeName(name)
+	level := getLogLevel(name)
+	if level == LevelOff {
+		return NullLog
+	}
+	return &slogLogger{handler: handler.WithAttrs([]slog.Attr{slog.String(SlogLoggerKey, name)}), name: name, level: int32(level)}
+}
+
+// toSlogLevel returns the slog level of a logxi level.
+func toSlogLevel(level int) slog.Level {
+	switch {
+	case level <= LevelFatal:
+		return slog.LevelError + 4
+	case level == LevelError:
+		return slog.LevelError
+	case level == LevelWarn:
+		return slog.LevelWarn
+	case level == LevelNotice:
+		return slog.LevelInfo + 2
+	case level == LevelInfo:
+		return slog.LevelInfo
+	case level == LevelDebug:
+		return slog.LevelDebug
+	}
+	return slog.LevelDebug - 4
+}
+
+func (l *slogLogger) enabled(level int) bool {
+	return int(atomic.LoadInt32(&l.level)) >= level && l.handler.Enabled(context.Background(), toSlogLevel(level))
+}
+
+func (l *slogLogger) log(level int, msg string, args []interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	var pcs [1]uintptr
+	// skip runtime.Callers, log and the logging method
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), toSlogLevel(level), msg, pcs[0])
+	if len(args) == 1 {
+		args = []interface{}{singleArgKey, args[0]}
+	}
+	if len(args)%2 != 0 {
+		args = []interface{}{warnImbalancedKey, fmt.Sprint(args)}
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok || key == "" {
+			key = badKeyAtIndex(i)
+		}
+		r.AddAttrs(slog.Any(key, args[i+1]))
+	}
+	l.handler.Handle(context.Background(), r)
+}
+
+// errorOf returns the first error of args or msg as an error, like
+// DefaultLogger.Error.
+func errorOf(msg string, args []interface{}) error {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	return fmt.Errorf(msg)
+}
+
+func (l *slogLogger) Trace(msg string, args ...interface{}) {
+	l.log(LevelTrace, msg, args)
+}
+
+func (l *slogLogger) Debug(msg string, args ...interface{}) {
+	l.log(LevelDebug, msg, args)
+}
+
+func (l *slogLogger) Info(msg string, args ...interface{}) {
+	l.log(LevelInfo, msg, args)
+}
+
+func (l *slogLogger) Warn(msg string, args ...interface{}) error {
+	if !l.IsWarn() {
+		return nil
+	}
+	l.log(LevelWarn, msg, args)
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *slogLogger) Error(msg string, args ...interface{}) error {
+	l.log(LevelError, msg, args)
+	return errorOf(msg, args)
+}
+
+// Fatal logs a fatal entry, flushes every logger then panics.
+func (l *slogLogger) Fatal(msg string, args ...interface{}) {
+	l.log(LevelFatal, msg, args)
+	reportCrash(msg)
+	Flush(FlushTimeout)
+	panic("Exit due to fatal error: ")
+}
+
+func (l *slogLogger) Log(level int, msg string, args []interface{}) {
+	l.log(level, msg, args)
+}
+
+func (l *slogLogger) Name() string {
+	return l.name
+}
+
+func (l *slogLogger) SetLevel(level int) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+func (l *slogLogger) IsTrace() bool {
+	return l.enabled(LevelTrace)
+}
+
+func (l *slogLogger) IsDebug() bool {
+	return l.enabled(LevelDebug)
+}
+
+func (l *slogLogger) IsInfo() bool {
+	return l.enabled(LevelInfo)
+}
+
+func (l *slogLogger) IsWarn() bool {
+	return l.enabled(LevelWarn)
+}
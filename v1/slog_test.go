@@ -0,0 +1,82 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	assert := assert.New(t)
+	buf := &bytes.Buffer{}
+	logger := NewLogger3(buf, "slog", NewJSONFormatter("slog"))
+	logger.SetLevel(LevelInfo)
+
+	sl := slog.New(NewSlogHandler(logger)).With("service", "api")
+	sl.Debug("hidden")
+	sl.WithGroup("req").With("method", "GET").Info("served", "status", 200, slog.Group("user", "id", 42))
+	sl.WithGroup("empty").Warn("no fields")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !assert.Len(lines, 2) {
+		return
+	}
+	var obj map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &obj))
+	assert.Equal("served", obj[KeyMap.Message])
+	assert.Equal("INF", obj[KeyMap.Level])
+	assert.Equal("api", obj["service"])
+	req, _ := obj["req"].(map[string]interface{})
+	assert.Equal("GET", req["method"])
+	assert.Equal(float64(200), req["status"])
+	assert.Equal(map[string]interface{}{"id": float64(42)}, req["user"])
+
+	obj = nil
+	assert.NoError(json.Unmarshal([]byte(lines[1]), &obj))
+	assert.Equal("WRN", obj[KeyMap.Level])
+	assert.NotContains(obj, "empty")
+
+	assert.False(NewSlogHandler(logger).Enabled(context.Background(), slog.LevelDebug))
+	assert.True(NewSlogHandler(logger).Enabled(context.Background(), slog.LevelError))
+}
+
+func TestSlogLogger(t *testing.T) {
+	assert := assert.New(t)
+	buf := &bytes.Buffer{}
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug - 4, AddSource: true})
+	logger := NewSlogLogger(handler, "bridge")
+	logger.SetLevel(LevelDebug)
+
+	logger.Trace("hidden")
+	logger.Debug("debugging", "n", 1)
+	err := logger.Error("failed", "user", "ann")
+	assert.Equal("failed", err.Error())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !assert.Len(lines, 2) {
+		return
+	}
+	var obj map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &obj))
+	assert.Equal("DEBUG", obj["level"])
+	assert.Equal("debugging", obj["msg"])
+	assert.Equal("bridge", obj[SlogLoggerKey])
+	assert.Equal(float64(1), obj["n"])
+	source, _ := obj["source"].(map[string]interface{})
+	assert.Contains(source["file"], "slog_test.go")
+
+	obj = nil
+	assert.NoError(json.Unmarshal([]byte(lines[1]), &obj))
+	assert.Equal("ERROR", obj["level"])
+	assert.Equal("ann", obj["user"])
+	assert.True(logger.IsDebug())
+	assert.False(logger.IsTrace())
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"io"
+	"time"
+)
+
+// LevelSplitWriter writes entries at or more severe than a threshold level
+// to one writer and the rest to another, eg warnings and errors to stderr
+// and info and debug entries to stdout, so container platforms which treat
+// stderr as error output classify entries correctly. The writer is picked
+// per entry, so a single logger writes to both.
+type LevelSplitWriter struct {
+	stdout    io.Writer
+	stderr    io.Writer
+	threshold int
+}
+
+// NewLevelSplitWriter creates a writer which writes entries at threshold
+// or more severe to stderr and the rest to stdout. Writers which are not
+// concurrent safe must be wrapped with NewConcurrentWriter.
+func NewLevelSplitWriter(stdout, stderr io.Writer, threshold int) *LevelSplitWriter {
+	return &LevelSplitWriter{stdout: stdout, stderr: stderr, threshold: threshold}
+}
+
+// SetLevelWriters makes New and registered loggers writing to stdout split
+// their entries between stdout and stderr, see LevelSplitWriter.
+//
+// Example
+//
+//	log.SetLevelWriters(log.NewConcurrentWriter(os.Stdout), log.NewConcurrentWriter(os.Stderr), log.LevelWarn)
+func SetLevelWriters(stdout, stderr io.Writer, threshold int) {
+	setStdout(NewLevelSplitWriter(stdout, stderr, threshold))
+}
+
+// Write writes to stdout since the level is unknown.
+func (sw *LevelSplitWriter) Write(p []byte) (int, error) {
+	return sw.destination(sw.stdout).Write(p)
+}
+
+// destination returns writer, stripping colors if it isn't a terminal
+// while the other writer is.
+func (sw *LevelSplitWriter) destination(writer io.Writer) io.Writer {
+	if stripsColors(writer) {
+		return stripColors(writer)
+	}
+	return writer
+}
+
+// WriteLevel writes an entry logged at level to stderr if it is at least as
+// severe as the threshold, otherwise to stdout.
+func (sw *LevelSplitWriter) WriteLevel(level int, p []byte) (int, error) {
+	if level <= sw.threshold {
+		return sw.destination(sw.stderr).Write(p)
+	}
+	return sw.destination(sw.stdout).Write(p)
+}
+
+// Flush flushes and syncs both writers.
+func (sw *LevelSplitWriter) Flush(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	err := flushWriter(sw.stdout, deadline)
+	if serr := flushWriter(sw.stderr, deadline); err == nil {
+		err = serr
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package log
+
+import "runtime"
+
+// DefaultStackDepth is the maximum number of frames captured for a call
+// stack, excluding logxi frames.
+const DefaultStackDepth = 64
+
+// logxiFrames is the headroom for logxi frames, which are captured then
+// removed, when capturing a stack
+const logxiFrames = 32
+
+// StackCapturer captures call stacks. Implement it to use another
+// symbolizer, eg gopkg.in/stack.
+type StackCapturer interface {
+	// Capture returns up to depth frames of the calling goroutine, skipping
+	// skip frames above the caller of Capture.
+	Capture(skip, depth int) []Frame
+}
+
+// CallersCapturer is the default StackCapturer. It uses runtime.Callers
+// and expands inlined calls.
+type CallersCapturer struct{}
+
+// Capture captures the stack with runtime.Callers.
+func (CallersCapturer) Capture(skip, depth int) []Frame {
+	pcs := make([]uintptr, depth)
+	// skip runtime.Callers and Capture
+	pcs = pcs[:runtime.Callers(skip+2, pcs)]
+	frames := make([]Frame, 0, len(pcs))
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+var stackCapturer StackCapturer = CallersCapturer{}
+
+// SetStackCapturer sets the backend used to capture call stacks. A nil
+// capturer restores CallersCapturer. Set it at startup.
+func SetStackCapturer(sc StackCapturer) {
+	if sc == nil {
+		sc = CallersCapturer{}
+	}
+	stackCapturer = sc
+}
+
+// captureFrames returns up to depth frames of the current goroutine,
+// excluding logxi frames and frames rejected by the frame filter, after
+// skipping skip frames, eg those of a logging wrapper.
+func captureFrames(skip, depth int) []*frameInfo {
+	if depth <= 0 {
+		depth = DefaultStackDepth
+	}
+	frames := make([]*frameInfo, 0, depth)
+	filter := frameFilter
+	for _, frame := range stackCapturer.Capture(1, skip+depth+logxiFrames) {
+		if isLogxiCode(frame.File) || (filter != nil && !filter(frame)) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		frames = append(frames, &frameInfo{filename: frame.File, lineno: frame.Line, method: frame.Function})
+		if len(frames) == depth {
+			break
+		}
+	}
+	return frames
+}
+
+// callerFrames returns the frames of the current goroutine excluding logxi
+// frames. Unlike stackFrames, inlined calls are expanded so every frame has
+// the correct function.
+func callerFrames() []*frameInfo {
+	return captureFrames(0, DefaultStackDepth)
+}
+
+// callerFrame returns the first frame outside logxi which passes the frame
+// filter. It is much cheaper than capturing the whole stack.
+func callerFrame() (Frame, bool) {
+	frames := captureFrames(0, 1)
+	if len(frames) == 0 {
+		return Frame{}, false
+	}
+	return Frame{Function: frames[0].method, File: frames[0].filename, Line: frames[0].lineno}, true
+}
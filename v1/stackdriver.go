@@ -0,0 +1,132 @@
+package log
+
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+// FormatStackdriver uses StackdriverFormatter
+const FormatStackdriver = "stackdriver"
+
+// stackdriverSeverities are the Cloud Logging severities of levels
+var stackdriverSeverities = map[int]string{
+	LevelEmergency: "EMERGENCY",
+	LevelAlert:     "ALERT",
+	LevelFatal:     "CRITICAL",
+	LevelError:     "ERROR",
+	LevelWarn:      "WARNING",
+	LevelNotice:    "NOTICE",
+	LevelInfo:      "INFO",
+	LevelDebug:     "DEBUG",
+	LevelTrace:     "DEBUG",
+}
+
+// StackdriverFormatter formats entries as the structured JSON Google Cloud
+// Logging parses from stdout, eg on GKE or Cloud Run, so entries get their
+// severity instead of INFO. The caller is the source location and the
+// logger name the "logger" label. Key-value pairs are fields of the
+// entry's jsonPayload, except those promoted to labels with SetLabelKeys.
+// Select it with LOGXI_FORMAT=stackdriver.
+//
+// Example
+//
+//	sf := log.NewStackdriverFormatter("api")
+//	sf.SetLabelKeys("tenant")
+//	logger := log.NewLogger3(os.Stdout, "api", sf)
+type StackdriverFormatter struct {
+	name      string
+	labelKeys []string
+	jf        *JSONFormatter
+}
+
+// NewStackdriverFormatter creates a formatter for the logger name.
+func NewStackdriverFormatter(name string) *StackdriverFormatter {
+	return &StackdriverFormatter{name: name, jf: NewJSONFormatter(name)}
+}
+
+// SetLabelKeys sets the keys whose values are written as labels, which
+// Cloud Logging indexes, instead of payload fields.
+func (sf *StackdriverFormatter) SetLabelKeys(keys ...string) {
+	sf.labelKeys = keys
+}
+
+// Format writes an entry without its source location.
+func (sf *StackdriverFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	sf.format(writer, time.Now(), level, msg, args, nil)
+}
+
+// FormatEntry writes an entry with its source location, if captured.
+func (sf *StackdriverFormatter) FormatEntry(writer io.Writer, entry *Entry) {
+	sf.format(writer, entry.Time, entry.Level, entry.Msg, entry.Fields, entry.Caller)
+}
+
+func (sf *StackdriverFormatter) format(writer io.Writer, t time.Time, level int, msg string, args []interface{}, caller *Frame) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+	jf := sf.jf
+
+	buf.WriteString(`{"severity":"`)
+	if severity, ok := stackdriverSeverities[level]; ok {
+		buf.WriteString(severity)
+	} else {
+		buf.WriteString("DEFAULT")
+	}
+	buf.WriteString(`", "time":"`)
+	buf.WriteString(t.UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`", "message":`)
+	jf.writeString(buf, stripANSI(msg))
+	if caller != nil {
+		buf.WriteString(`, "logging.googleapis.com/sourceLocation":{"file":`)
+		jf.writeString(buf, caller.File)
+		buf.WriteString(`, "line":"`)
+		buf.WriteString(strconv.Itoa(caller.Line))
+		buf.WriteString(`", "function":`)
+		jf.writeString(buf, caller.Function)
+		buf.WriteRune('}')
+	}
+
+	if len(args) == 1 {
+		args = []interface{}{singleArgKey, args[0]}
+	}
+	if len(args)%2 != 0 {
+		args = []interface{}{warnImbalancedKey, syslogValue(args)}
+	}
+
+	buf.WriteString(`, "logging.googleapis.com/labels":{"logger":`)
+	jf.writeString(buf, sf.name)
+	isLabel := make([]bool, len(args))
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok || !containsString(sf.labelKeys, key) {
+			continue
+		}
+		isLabel[i] = true
+		// label values must be strings
+		buf.WriteString(`, `)
+		jf.writeString(buf, key)
+		buf.WriteRune(':')
+		jf.writeString(buf, syslogValue(args[i+1]))
+	}
+	buf.WriteRune('}')
+
+	for i := 0; i < len(args); i += 2 {
+		if isLabel[i] {
+			continue
+		}
+		key, ok := args[i].(string)
+		if !ok || key == "" {
+			key = badKeyAtIndex(i)
+		}
+		buf.WriteString(`, `)
+		jf.writeString(buf, key)
+		buf.WriteRune(':')
+		if err, ok := args[i+1].(error); ok {
+			jf.writeString(buf, stripANSI(err.Error()))
+		} else {
+			jf.appendValue(buf, args[i+1])
+		}
+	}
+	buf.WriteString("}\n")
+	buf.WriteTo(writer)
+}
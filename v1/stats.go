@@ -0,0 +1,236 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// statsResolution is the width of a stats bucket
+const statsResolution = 5 * time.Second
+
+// statsBuckets is the number of buckets kept per logger, which is the longest
+// window that can be queried
+const statsBuckets = 72
+
+// statsEnabled is 1 when entry volume is recorded
+var statsEnabled int32
+
+type statsBucket struct {
+	epoch   int64
+	entries int64
+	bytes   int64
+}
+
+type loggerStats struct {
+	sync.Mutex
+	buckets [statsBuckets]statsBucket
+}
+
+func (ls *loggerStats) record(now time.Time, bytes int64) {
+	epoch := now.UnixNano() / int64(statsResolution)
+	ls.Lock()
+	b := &ls.buckets[epoch%statsBuckets]
+	if b.epoch != epoch {
+		*b = statsBucket{epoch: epoch}
+	}
+	b.entries++
+	b.bytes += bytes
+	ls.Unlock()
+}
+
+func (ls *loggerStats) sum(now time.Time, window time.Duration) (entries, bytes int64) {
+	epoch := now.UnixNano() / int64(statsResolution)
+	oldest := epoch - int64(window/statsResolution)
+	ls.Lock()
+	defer ls.Unlock()
+	for _, b := range ls.buckets {
+		if b.epoch > oldest && b.epoch <= epoch {
+			entries += b.entries
+			bytes += b.bytes
+		}
+	}
+	return entries, bytes
+}
+
+type statsMap struct {
+	sync.RWMutex
+	loggers map[string]*loggerStats
+}
+
+var stats = &statsMap{
+	loggers: map[string]*loggerStats{},
+}
+
+func (sm *statsMap) get(name string) *loggerStats {
+	sm.RLock()
+	ls := sm.loggers[name]
+	sm.RUnlock()
+	if ls != nil {
+		return ls
+	}
+	sm.Lock()
+	defer sm.Unlock()
+	ls = sm.loggers[name]
+	if ls == nil {
+		ls = &loggerStats{}
+		sm.loggers[name] = ls
+	}
+	return ls
+}
+
+// countingWriter counts bytes written by formatters when stats are enabled
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.writer.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// EnableStats enables or disables recording the number of entries and bytes
+// written by each logger. Stats are kept for the last 6 minutes.
+func EnableStats(enable bool) {
+	if enable {
+		atomic.StoreInt32(&statsEnabled, 1)
+	} else {
+		atomic.StoreInt32(&statsEnabled, 0)
+	}
+}
+
+func isStatsEnabled() bool {
+	return atomic.LoadInt32(&statsEnabled) == 1
+}
+
+// LoggerStats is the volume logged by a named logger over a window.
+type LoggerStats struct {
+	Name          string  `json:"name"`
+	Entries       int64   `json:"entries"`
+	Bytes         int64   `json:"bytes"`
+	EntriesPerSec float64 `json:"entriesPerSec"`
+	BytesPerSec   float64 `json:"bytesPerSec"`
+}
+
+func collectStats(window time.Duration) []LoggerStats {
+	if window < statsResolution {
+		window = statsResolution
+	}
+	now := time.Now()
+	stats.RLock()
+	defer stats.RUnlock()
+	result := make([]LoggerStats, 0, len(stats.loggers))
+	for name, ls := range stats.loggers {
+		entries, bytes := ls.sum(now, window)
+		if entries == 0 {
+			continue
+		}
+		result = append(result, LoggerStats{
+			Name:          name,
+			Entries:       entries,
+			Bytes:         bytes,
+			EntriesPerSec: float64(entries) / window.Seconds(),
+			BytesPerSec:   float64(bytes) / window.Seconds(),
+		})
+	}
+	return result
+}
+
+func topN(result []LoggerStats, n int) []LoggerStats {
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// TopLoggers returns up to n loggers with the most entries over the last
+// window. The window is rounded to 5 seconds. EnableStats must be called
+// first.
+func TopLoggers(n int, window time.Duration) []LoggerStats {
+	result := collectStats(window)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Entries == result[j].Entries {
+			return result[i].Bytes > result[j].Bytes
+		}
+		return result[i].Entries > result[j].Entries
+	})
+	return topN(result, n)
+}
+
+// TopLoggersByBytes returns up to n loggers which wrote the most bytes over
+// the last window.
+func TopLoggersByBytes(n int, window time.Duration) []LoggerStats {
+	result := collectStats(window)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bytes == result[j].Bytes {
+			return result[i].Entries > result[j].Entries
+		}
+		return result[i].Bytes > result[j].Bytes
+	})
+	return topN(result, n)
+}
+
+// StatsHandler returns a handler which displays the top loggers by volume.
+// Mount it on an admin or debug mux. Sinks which fell back to stderr are
+// listed first and set the X-Logxi-Degraded header.
+//
+// Query parameters
+//
+//	n=20          number of loggers
+//	window=1m     window to sum over, max 6m
+//	sort=bytes    sort by bytes instead of entries
+//	format=json   respond with JSON
+func StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		n, err := strconv.Atoi(q.Get("n"))
+		if err != nil {
+			n = 20
+		}
+		window, err := time.ParseDuration(q.Get("window"))
+		if err != nil {
+			window = time.Minute
+		}
+
+		var top []LoggerStats
+		if q.Get("sort") == "bytes" {
+			top = TopLoggersByBytes(n, window)
+		} else {
+			top = TopLoggers(n, window)
+		}
+
+		if IsDegraded() {
+			w.Header().Set("X-Logxi-Degraded", "true")
+		}
+		if q.Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(top)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, d := range Degraded() {
+			fmt.Fprintf(w, "DEGRADED %s: %s, logging to stderr since %s\n\n", d.Sink, d.Err, d.Time.Format(time.RFC3339))
+		}
+		if !isStatsEnabled() {
+			fmt.Fprintln(w, "stats are disabled, see log.EnableStats")
+			return
+		}
+		fmt.Fprintf(w, "top loggers over %s\n\n", window)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tENTRIES\tBYTES\tENTRIES/S\tBYTES/S")
+		for _, ls := range top {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\n", ls.Name, ls.Entries, ls.Bytes, ls.EntriesPerSec, ls.BytesPerSec)
+		}
+		tw.Flush()
+	})
+}
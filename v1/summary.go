@@ -0,0 +1,163 @@
+package log
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Histogram is implemented by HDR histograms such as
+// github.com/HdrHistogram/hdrhistogram-go.
+type Histogram interface {
+	TotalCount() int64
+	Min() int64
+	Max() int64
+	// ValueAtQuantile returns the value at quantile q in [0, 100]
+	ValueAtQuantile(q float64) int64
+}
+
+// SummaryValue summarizes a latency distribution. Use Latencies or
+// HistogramSummary to create it.
+//
+// HappyDevFormatter prints percentiles followed by a sparkline of the
+// distribution. JSONFormatter logs an object with the count and the min,
+// p50, p95, p99 and max latencies in milliseconds.
+type SummaryValue struct {
+	Count int64
+	Min   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+
+	// counts for each spark bucket between Min and Max
+	buckets []int
+}
+
+// Latencies summarizes a slice of latency samples.
+//
+// Example
+//
+//	logger.Info("requests", "latency", log.Latencies(samples))
+func Latencies(samples []time.Duration) *SummaryValue {
+	sv := &SummaryValue{Count: int64(len(samples))}
+	if len(samples) == 0 {
+		return sv
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var quantile = func(q float64) time.Duration {
+		// nearest rank
+		rank := int(q/100*float64(len(sorted))+0.5) - 1
+		return sorted[maxInt(0, minInt(rank, len(sorted)-1))]
+	}
+	sv.Min = sorted[0]
+	sv.P50 = quantile(50)
+	sv.P95 = quantile(95)
+	sv.P99 = quantile(99)
+	sv.Max = sorted[len(sorted)-1]
+	sv.buckets = sparkBuckets(sorted)
+	return sv
+}
+
+// HistogramSummary summarizes an HDR histogram whose values are recorded in
+// unit, eg time.Microsecond.
+func HistogramSummary(h Histogram, unit time.Duration) *SummaryValue {
+	sv := &SummaryValue{Count: h.TotalCount()}
+	if sv.Count == 0 {
+		return sv
+	}
+	sv.Min = time.Duration(h.Min()) * unit
+	sv.P50 = time.Duration(h.ValueAtQuantile(50)) * unit
+	sv.P95 = time.Duration(h.ValueAtQuantile(95)) * unit
+	sv.P99 = time.Duration(h.ValueAtQuantile(99)) * unit
+	sv.Max = time.Duration(h.Max()) * unit
+
+	// approximate the distribution with evenly spaced quantiles
+	points := make([]time.Duration, 100)
+	for i := range points {
+		points[i] = time.Duration(h.ValueAtQuantile(float64(i)+0.5)) * unit
+	}
+	sv.buckets = sparkBuckets(points)
+	return sv
+}
+
+// sparkBuckets counts sorted samples into equal width buckets.
+func sparkBuckets(sorted []time.Duration) []int {
+	buckets := make([]int, len(sparks))
+	min := sorted[0]
+	width := sorted[len(sorted)-1] - min
+	for _, d := range sorted {
+		i := 0
+		if width > 0 {
+			i = int(int64(d-min) * int64(len(buckets)-1) / int64(width))
+		}
+		buckets[i]++
+	}
+	return buckets
+}
+
+// MarshalJSON marshals the summary with latencies in milliseconds.
+func (sv *SummaryValue) MarshalJSON() ([]byte, error) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+	buf.WriteString(`{"count":`)
+	buf.WriteString(strconv.FormatInt(sv.Count, 10))
+	var set = func(key string, d time.Duration) {
+		buf.WriteString(`,"`)
+		buf.WriteString(key)
+		buf.WriteString(`":`)
+		buf.WriteString(strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64))
+	}
+	set("min_ms", sv.Min)
+	set("p50_ms", sv.P50)
+	set("p95_ms", sv.P95)
+	set("p99_ms", sv.P99)
+	set("max_ms", sv.Max)
+	buf.WriteRune('}')
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+func (sv *SummaryValue) String() string {
+	if sv.Count == 0 {
+		return "n=0"
+	}
+	return "n=" + strconv.FormatInt(sv.Count, 10) +
+		" min=" + roundDuration(sv.Min).String() +
+		" p50=" + roundDuration(sv.P50).String() +
+		" p95=" + roundDuration(sv.P95).String() +
+		" p99=" + roundDuration(sv.P99).String() +
+		" max=" + roundDuration(sv.Max).String()
+}
+
+func (sv *SummaryValue) happyValue() (string, string) {
+	if len(sv.buckets) == 0 {
+		return sv.String(), ""
+	}
+	max := 0
+	for _, n := range sv.buckets {
+		max = maxInt(max, n)
+	}
+	spark := make([]rune, len(sv.buckets))
+	for i, n := range sv.buckets {
+		spark[i] = sparks[n*(len(sparks)-1)/max]
+	}
+	return sv.String() + " " + string(spark), ""
+}
+
+// roundDuration keeps about three significant digits.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	case d >= time.Microsecond:
+		return d.Round(10 * time.Nanosecond)
+	}
+	return d
+}
@@ -0,0 +1,309 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Facility is a syslog facility, eg FacilityLocal0.
+type Facility int
+
+// Syslog facilities commonly used by applications.
+const (
+	FacilityUser   Facility = 1
+	FacilityDaemon Facility = 3
+	FacilityLocal0 Facility = 16
+	FacilityLocal1 Facility = 17
+	FacilityLocal2 Facility = 18
+	FacilityLocal3 Facility = 19
+	FacilityLocal4 Facility = 20
+	FacilityLocal5 Facility = 21
+	FacilityLocal6 Facility = 22
+	FacilityLocal7 Facility = 23
+)
+
+// DefaultSDID is the structured data ID key-value pairs are written under.
+// 32473 is the private enterprise number reserved for documentation, set
+// your own with SetSDID.
+const DefaultSDID = "logxi@32473"
+
+// SyslogSeverity maps a logxi level to a syslog severity, 0 for emergencies
+// through 7 for debug and trace entries.
+func SyslogSeverity(level int) int {
+	switch {
+	case level < 0:
+		return 0
+	case level > 7:
+		return 7
+	}
+	return level
+}
+
+// SyslogFormatter formats entries as RFC 5424 syslog messages. Key-value
+// pairs are written as structured data, the logger name as MSGID.
+//
+// Example
+//
+//	sink, err := log.NewSyslogSink("udp", "syslog:514")
+//	if err != nil {
+//		panic(err)
+//	}
+//	logger := log.NewLogger3(sink, "api", log.NewSyslogFormatter("api", log.FacilityLocal0))
+type SyslogFormatter struct {
+	name     string
+	facility Facility
+	hostname string
+	appName  string
+	sdid     string
+}
+
+// NewSyslogFormatter creates a formatter for the logger name writing
+// messages with facility.
+func NewSyslogFormatter(name string, facility Facility) *SyslogFormatter {
+	hostname, _ := os.Hostname()
+	return &SyslogFormatter{
+		name:     name,
+		facility: facility,
+		hostname: syslogHeaderField(hostname, 255),
+		appName:  syslogHeaderField(filepath.Base(os.Args[0]), 48),
+		sdid:     DefaultSDID,
+	}
+}
+
+// SetSDID sets the structured data ID key-value pairs are written under,
+// eg "app@12345" with your private enterprise number.
+func (sf *SyslogFormatter) SetSDID(sdid string) {
+	sf.sdid = sdid
+}
+
+// syslogHeaderField returns s as a header field, "-" when empty, without
+// spaces and non-printable characters, truncated to max bytes.
+func syslogHeaderField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// sdName returns key as a structured data parameter name.
+func sdName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// sdValueEscaper escapes the characters RFC 5424 requires in parameter
+// values
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// Format writes an RFC 5424 message terminated by a newline, which
+// SyslogSink removes or uses for framing.
+func (sf *SyslogFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+
+	buf.WriteRune('<')
+	buf.WriteString(strconv.Itoa(int(sf.facility)*8 + SyslogSeverity(level)))
+	buf.WriteString(">1 ")
+	buf.WriteString(time.Now().Format("2006-01-02T15:04:05.000000Z07:00"))
+	buf.WriteRune(' ')
+	buf.WriteString(sf.hostname)
+	buf.WriteRune(' ')
+	buf.WriteString(sf.appName)
+	buf.WriteRune(' ')
+	buf.WriteString(pidStr)
+	buf.WriteRune(' ')
+	buf.WriteString(syslogHeaderField(sf.name, 32))
+	buf.WriteRune(' ')
+
+	if len(args) == 0 {
+		buf.WriteRune('-')
+	} else {
+		buf.WriteRune('[')
+		buf.WriteString(sf.sdid)
+		if len(args) == 1 {
+			args = []interface{}{singleArgKey, args[0]}
+		}
+		if len(args)%2 != 0 {
+			args = []interface{}{warnImbalancedKey, fmt.Sprint(args)}
+		}
+		for i := 0; i < len(args); i += 2 {
+			key, ok := args[i].(string)
+			if !ok || key == "" {
+				key = badKeyAtIndex(i)
+			}
+			buf.WriteRune(' ')
+			buf.WriteString(sdName(key))
+			buf.WriteString(`="`)
+			buf.WriteString(sdValueEscaper.Replace(syslogValue(args[i+1])))
+			buf.WriteRune('"')
+		}
+		buf.WriteRune(']')
+	}
+	if msg != "" {
+		buf.WriteRune(' ')
+		buf.WriteString(msg)
+	}
+	buf.WriteRune('\n')
+	buf.WriteTo(writer)
+}
+
+func syslogValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case error:
+		return stripANSI(v.Error())
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// syslogSockets are the local syslog sockets tried in order
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogSink is a concurrent safe writer sending messages to a syslog
+// daemon. Messages sent over TCP are framed by octet counting per RFC 6587,
+// over other transports each write is a message. A failed write reconnects
+// once before the error is returned.
+type SyslogSink struct {
+	network string
+	addr    string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	health sinkHealth
+}
+
+// NewSyslogSink connects to a syslog daemon. The network is "udp", "tcp",
+// "unixgram" or "unix", or empty for the local daemon's socket, eg
+// /dev/log.
+func NewSyslogSink(network, addr string) (*SyslogSink, error) {
+	ss := &SyslogSink{network: network, addr: addr}
+	if err := ss.connect(); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// connect dials the daemon. The lock must be held.
+func (ss *SyslogSink) connect() error {
+	if ss.conn != nil {
+		ss.conn.Close()
+		ss.conn = nil
+	}
+	if ss.network != "" {
+		conn, err := net.Dial(ss.network, ss.addr)
+		if err != nil {
+			return err
+		}
+		ss.conn = conn
+		return nil
+	}
+	for _, path := range syslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				ss.conn = conn
+				return nil
+			}
+		}
+	}
+	return errors.New("logxi: no local syslog socket found")
+}
+
+func (ss *SyslogSink) Write(p []byte) (int, error) {
+	n, err := ss.write(p)
+	ss.health.record(err)
+	return n, err
+}
+
+func (ss *SyslogSink) write(p []byte) (int, error) {
+	msg := p
+	if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[:len(msg)-1]
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.conn == nil {
+		if err := ss.connect(); err != nil {
+			return 0, err
+		}
+	}
+	if err := ss.send(msg); err != nil {
+		if err = ss.connect(); err != nil {
+			return 0, err
+		}
+		if err = ss.send(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// send writes a message framed for the transport. The lock must be held.
+func (ss *SyslogSink) send(msg []byte) error {
+	var err error
+	switch ss.conn.LocalAddr().Network() {
+	case "tcp", "tcp4", "tcp6":
+		_, err = fmt.Fprintf(ss.conn, "%d %s", len(msg), msg)
+	case "unix":
+		line := make([]byte, 0, len(msg)+1)
+		_, err = ss.conn.Write(append(append(line, msg...), '\n'))
+	default:
+		_, err = ss.conn.Write(msg)
+	}
+	return err
+}
+
+// Health reports whether the sink is connected and the last error.
+func (ss *SyslogSink) Health() []SinkStatus {
+	ss.mu.Lock()
+	connected := ss.conn != nil
+	ss.mu.Unlock()
+	name := "syslog"
+	if ss.network != "" {
+		name += " " + ss.network + "://" + ss.addr
+	}
+	return []SinkStatus{ss.health.status(name, connected, 0, 0)}
+}
+
+// Close closes the connection. Later writes reconnect.
+func (ss *SyslogSink) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.conn == nil {
+		return nil
+	}
+	err := ss.conn.Close()
+	ss.conn = nil
+	return err
+}
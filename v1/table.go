@@ -0,0 +1,159 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+// TableValue is tabular data. Use Table to create it.
+//
+// HappyDevFormatter prints an aligned table below the entry. JSONFormatter
+// logs an array of objects keyed by header.
+type TableValue struct {
+	headers []string
+	rows    [][]interface{}
+}
+
+// Table returns tabular data to be logged as a value.
+//
+// Example
+//
+//	logger.Info("workers", "table", log.Table(
+//		[]string{"id", "state"},
+//		[][]interface{}{{1, "idle"}, {2, "busy"}},
+//	))
+func Table(headers []string, rows [][]interface{}) *TableValue {
+	return &TableValue{headers: headers, rows: rows}
+}
+
+func (tv *TableValue) header(i int) string {
+	if i < len(tv.headers) {
+		return tv.headers[i]
+	}
+	return "col" + strconv.Itoa(i)
+}
+
+// MarshalJSON marshals the table as an array of objects.
+func (tv *TableValue) MarshalJSON() ([]byte, error) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+	buf.WriteRune('[')
+	for i, row := range tv.rows {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		buf.WriteRune('{')
+		for j, cell := range row {
+			if j > 0 {
+				buf.WriteRune(',')
+			}
+			k, err := json.Marshal(tv.header(j))
+			if err != nil {
+				return nil, err
+			}
+			v, err := json.Marshal(cell)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(k)
+			buf.WriteRune(':')
+			buf.Write(v)
+		}
+		buf.WriteRune('}')
+	}
+	buf.WriteRune(']')
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+func (tv *TableValue) String() string {
+	b, err := tv.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+func (tv *TableValue) happyValue() (string, string) {
+	inline := strconv.Itoa(len(tv.rows)) + " rows"
+	if len(tv.rows) == 1 {
+		inline = "1 row"
+	}
+
+	cols := len(tv.headers)
+	cells := make([][]string, len(tv.rows))
+	for i, row := range tv.rows {
+		cols = maxInt(cols, len(row))
+		cells[i] = make([]string, len(row))
+		for j, cell := range row {
+			cells[i][j] = tableCell(cell)
+		}
+	}
+	if cols == 0 {
+		return inline, ""
+	}
+
+	widths := make([]int, cols)
+	for j := 0; j < cols; j++ {
+		widths[j] = displayWidth(tv.header(j))
+	}
+	for _, row := range cells {
+		for j, cell := range row {
+			widths[j] = maxInt(widths[j], displayWidth(cell))
+		}
+	}
+
+	reset := ansi.Reset
+	if cfg().disableColors {
+		reset = ""
+	}
+	buf := pool.Get()
+	defer pool.Put(buf)
+
+	var writeRow = func(row []string, color string) {
+		for j := 0; j < cols; j++ {
+			var cell string
+			if j < len(row) {
+				cell = row[j]
+			}
+			if j > 0 {
+				buf.WriteString("  ")
+			}
+			buf.WriteString(color)
+			buf.WriteString(cell)
+			buf.WriteString(reset)
+			if j < cols-1 {
+				buf.WriteString(strings.Repeat(" ", widths[j]-displayWidth(cell)))
+			}
+		}
+		buf.WriteRune('\n')
+	}
+
+	headers := make([]string, cols)
+	rule := make([]string, cols)
+	for j := 0; j < cols; j++ {
+		headers[j] = tv.header(j)
+		rule[j] = strings.Repeat("-", widths[j])
+	}
+	writeRow(headers, cfg().theme.Key)
+	writeRow(rule, cfg().theme.Misc)
+	for _, row := range cells {
+		writeRow(row, cfg().theme.Value)
+	}
+	return inline, buf.String()
+}
+
+func tableCell(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.Replace(v, "\n", " ", -1)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", val)
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SinkError is the error of a single sink of a TeeWriter.
+type SinkError struct {
+	Sink string `json:"sink"`
+	Err  error  `json:"-"`
+	// Failures is the number of consecutive failed writes to the sink
+	Failures int `json:"failures"`
+}
+
+// MarshalJSON includes the error message.
+func (se SinkError) MarshalJSON() ([]byte, error) {
+	buf := pool.Get()
+	defer pool.Put(buf)
+	var jf JSONFormatter
+	buf.WriteString(`{"sink":`)
+	jf.writeString(buf, se.Sink)
+	buf.WriteString(`,"err":`)
+	jf.writeString(buf, se.Err.Error())
+	buf.WriteString(`,"failures":`)
+	buf.WriteString(strconv.Itoa(se.Failures))
+	buf.WriteRune('}')
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// TeeError is returned by TeeWriter when one or more sinks fail.
+type TeeError struct {
+	Errors []SinkError
+}
+
+func (te *TeeError) Error() string {
+	msgs := make([]string, len(te.Errors))
+	for i, se := range te.Errors {
+		msgs[i] = se.Sink + ": " + se.Err.Error()
+	}
+	return "tee write failed: " + strings.Join(msgs, "; ")
+}
+
+type teeSink struct {
+	name          string
+	writer        io.Writer
+	failures      int
+	lastErr       error
+	lastErrorTime time.Time
+}
+
+// TeeWriter is a concurrent safe writer which duplicates writes to multiple
+// named sinks. A failing sink does not prevent writes to the others. When
+// sinks fail, a single entry listing every failed sink along with its
+// consecutive failure count is logged to InternalLog and a *TeeError is
+// returned.
+type TeeWriter struct {
+	sync.Mutex
+	sinks []*teeSink
+}
+
+// NewTeeWriter creates a TeeWriter. Use Add to add sinks.
+func NewTeeWriter() *TeeWriter {
+	return &TeeWriter{}
+}
+
+// Add adds a named sink and returns the tee for chaining.
+func (tw *TeeWriter) Add(name string, writer io.Writer) *TeeWriter {
+	tw.Lock()
+	defer tw.Unlock()
+	tw.sinks = append(tw.sinks, &teeSink{name: name, writer: writer})
+	return tw
+}
+
+func (tw *TeeWriter) Write(p []byte) (int, error) {
+	tw.Lock()
+	var failed []SinkError
+	var recovered []string
+	for _, sink := range tw.sinks {
+		n, err := sink.writer.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			sink.failures++
+			sink.lastErr = err
+			sink.lastErrorTime = time.Now()
+			failed = append(failed, SinkError{Sink: sink.name, Err: err, Failures: sink.failures})
+		} else if sink.failures > 0 {
+			recovered = append(recovered, sink.name)
+			sink.failures = 0
+		}
+	}
+	tw.Unlock()
+
+	for _, name := range recovered {
+		InternalLog.Warn("Tee sink recovered", "sink", name)
+	}
+	if len(failed) > 0 {
+		InternalLog.Error("Tee sink writes failed", "sinks", failed)
+		return len(p), &TeeError{Errors: failed}
+	}
+	return len(p), nil
+}
+
+// Health reports the state of each sink. Sinks which report their own
+// health, eg a TimeoutWriter, are reported by name.
+func (tw *TeeWriter) Health() []SinkStatus {
+	tw.Lock()
+	defer tw.Unlock()
+	result := make([]SinkStatus, 0, len(tw.sinks))
+	for _, sink := range tw.sinks {
+		if hr, ok := unwrapWriter(sink.writer).(HealthReporter); ok {
+			for _, ss := range hr.Health() {
+				ss.Name = sink.name
+				if sink.failures > ss.Failures {
+					ss.Failures = sink.failures
+				}
+				result = append(result, ss)
+			}
+			continue
+		}
+		ss := SinkStatus{
+			Name:          sink.name,
+			Connected:     sink.failures == 0,
+			Failures:      sink.failures,
+			LastErrorTime: sink.lastErrorTime,
+		}
+		if sink.lastErr != nil {
+			ss.LastError = sink.lastErr.Error()
+		}
+		result = append(result, ss)
+	}
+	return result
+}
+
+// Flush flushes every sink which queues writes, waiting up to timeout.
+func (tw *TeeWriter) Flush(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	tw.Lock()
+	defer tw.Unlock()
+	var firstErr error
+	for _, sink := range tw.sinks {
+		if f, ok := unwrapWriter(sink.writer).(flusher); ok {
+			if err := f.Flush(time.Until(deadline)); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Sync syncs every sink which supports it, eg *os.File.
+func (tw *TeeWriter) Sync() error {
+	tw.Lock()
+	defer tw.Unlock()
+	var firstErr error
+	for _, sink := range tw.sinks {
+		w := unwrapWriter(sink.writer)
+		if w == os.Stdout || w == os.Stderr {
+			continue
+		}
+		if s, ok := w.(syncer); ok {
+			if err := s.Sync(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,112 @@
+package log
+
+import (
+	"io"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+// stderrTerminal is whether stderr is a terminal, isTerminal is stdout's
+var stderrTerminal bool
+
+// colorableConsole is the console writer colorable creates on Windows,
+// which is a terminal if stdout is
+var colorableConsole io.Writer
+
+// fder is implemented by writers to file descriptors, eg *os.File
+type fder interface {
+	Fd() uintptr
+}
+
+// IsTerminal reports whether writer writes to a terminal. Writers wrapped
+// with NewConcurrentWriter are unwrapped, other writers are terminals if
+// they have an Fd method returning a terminal's descriptor, as *os.File
+// does. A LevelSplitWriter is a terminal if either of its writers is.
+func IsTerminal(writer io.Writer) bool {
+	terminal, _ := writerTerminal(writer)
+	return terminal
+}
+
+// terminals caches whether writers which are pointers are terminals
+var terminals sync.Map
+
+// writerTerminal reports whether writer is a terminal and whether that is
+// known. Whether writers without a descriptor, eg an AsyncWriter, write to
+// a terminal is unknown.
+func writerTerminal(writer io.Writer) (terminal bool, known bool) {
+	w := unwrapWriter(writer)
+	if w == nil {
+		return false, false
+	}
+	cacheable := isPointer(w)
+	if cacheable {
+		if cached, ok := terminals.Load(w); ok {
+			state := cached.([2]bool)
+			return state[0], state[1]
+		}
+	}
+	switch v := w.(type) {
+	case *LevelSplitWriter:
+		stdout, stdoutKnown := writerTerminal(v.stdout)
+		stderr, stderrKnown := writerTerminal(v.stderr)
+		terminal, known = stdout || stderr, stdoutKnown && stderrKnown || stdout || stderr
+	case fder:
+		fd := v.Fd()
+		terminal, known = isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd), true
+	}
+	if w == colorableConsole {
+		terminal, known = isTerminal, true
+	}
+	if cacheable {
+		terminals.Store(w, [2]bool{terminal, known})
+	}
+	return terminal, known
+}
+
+// writerFormat returns the format of loggers writing to writer: the format
+// of LOGXI_FORMAT if set, otherwise HappyDev for terminals and JSON when
+// writer is known not to be one. stdout's default applies to other writers.
+func writerFormat(writer io.Writer) string {
+	if cfg().requestedFormat != "" {
+		return cfg().logxiFormat
+	}
+	terminal, known := writerTerminal(writer)
+	if !known || terminal == isTerminal {
+		return cfg().logxiFormat
+	}
+	if terminal {
+		return FormatHappy
+	}
+	return FormatJSON
+}
+
+// stripsColors reports whether colors written to writer are stripped,
+// which they are if colors are enabled and writer is known not to be a
+// terminal, eg stdout piped to a file while stderr is a terminal.
+func stripsColors(writer io.Writer) bool {
+	if cfg().disableColors {
+		return false
+	}
+	if _, ok := unwrapWriter(writer).(*LevelSplitWriter); ok {
+		// the split writer strips colors per destination
+		return false
+	}
+	terminal, known := writerTerminal(writer)
+	return known && !terminal
+}
+
+// stripColors returns a writer removing ANSI escapes before writing to
+// writer.
+func stripColors(writer io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		s := stripANSI(string(p))
+		if len(s) == len(p) {
+			return writer.Write(p)
+		}
+		if _, err := io.WriteString(writer, s); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	})
+}
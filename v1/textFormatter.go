@@ -3,8 +3,10 @@ package log
 import (
 	"fmt"
 	"io"
-	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Formatter records log entries.
@@ -14,32 +16,39 @@ type Formatter interface {
 
 // TextFormatter is the default recorder used if one is unspecified when
 // creating a new Logger.
+//
+// With LOGXI_FORMAT=logfmt entries are strict logfmt: keys and values are
+// separated by "=", values containing spaces, quotes, "=" or control
+// characters are quoted and escaped, and call stacks are logged as a
+// quoted value so every entry is a single line.
 type TextFormatter struct {
-	name         string
+	// name is the logger name written by Format. Loggers pass their name
+	// per entry so a formatter can be shared.
+	name string
+	// nameLabel are the fields preceding the name
+	nameLabel    string
 	itoaLevelMap map[int]string
 	timeLabel    string
+	logfmt       bool
+	floats       FloatFormat
 }
 
 // NewTextFormatter returns a new instance of TextFormatter. SetName
 // must be called befored using it.
 func NewTextFormatter(name string) *TextFormatter {
-	timeLabel := KeyMap.Time + AssignmentChar
-	levelLabel := Separator + KeyMap.Level + AssignmentChar
-	messageLabel := Separator + KeyMap.Message + AssignmentChar
-	nameLabel := Separator + KeyMap.Name + AssignmentChar
-	pidLabel := Separator + KeyMap.PID + AssignmentChar
+	timeLabel := KeyMap.Time + cfg().assignment()
+	levelLabel := cfg().pairSeparator() + KeyMap.Level + cfg().assignment()
+	messageLabel := cfg().pairSeparator() + KeyMap.Message + cfg().assignment()
+	nameLabel := cfg().pairSeparator() + KeyMap.Name + cfg().assignment()
+	pidLabel := cfg().pairSeparator() + KeyMap.PID + cfg().assignment()
+	bootLabel := cfg().pairSeparator() + KeyMap.BootID + cfg().assignment()
+
+	nameLabel = pidLabel + pidStr + bootLabel + BootID + nameLabel
 
 	var buildKV = func(level string) string {
 		buf := pool.Get()
 		defer pool.Put(buf)
 
-		buf.WriteString(pidLabel)
-		buf.WriteString(pidStr)
-
-		//buf.WriteString(Separator)
-		buf.WriteString(nameLabel)
-		buf.WriteString(name)
-
 		//buf.WriteString(Separator)
 		buf.WriteString(levelLabel)
 		buf.WriteString(level)
@@ -49,37 +58,167 @@ func NewTextFormatter(name string) *TextFormatter {
 
 		return buf.String()
 	}
-	itoaLevelMap := map[int]string{
-		LevelDebug: buildKV(LevelMap[LevelDebug]),
-		LevelWarn:  buildKV(LevelMap[LevelWarn]),
-		LevelInfo:  buildKV(LevelMap[LevelInfo]),
-		LevelError: buildKV(LevelMap[LevelError]),
-		LevelFatal: buildKV(LevelMap[LevelFatal]),
+	itoaLevelMap := map[int]string{}
+	for level, label := range LevelMap {
+		itoaLevelMap[level] = buildKV(label)
 	}
-	return &TextFormatter{itoaLevelMap: itoaLevelMap, name: name, timeLabel: timeLabel}
+	return &TextFormatter{itoaLevelMap: itoaLevelMap, name: name, nameLabel: nameLabel, timeLabel: timeLabel, logfmt: cfg().isLogfmt, floats: cfg().floatFormat}
+}
+
+// logfmtValue quotes s if it is empty or contains characters which would
+// end a logfmt value.
+func logfmtValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	if !utf8.ValidString(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// logfmtKey replaces characters which can't be used in logfmt keys.
+func logfmtKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// SetFloatFormat sets how this formatter renders floats.
+func (tf *TextFormatter) SetFloatFormat(ff FloatFormat) {
+	tf.floats = ff
+}
+
+// valueString formats a value, floats per the float format.
+func (tf *TextFormatter) valueString(val interface{}) string {
+	switch f := val.(type) {
+	case float64:
+		return tf.floats.text(f, 64)
+	case float32:
+		return tf.floats.text(float64(f), 32)
+	case *Array:
+		return f.json(tf.floats)
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+func (tf *TextFormatter) setLogfmt(buf bufferWriter, key string, val interface{}) {
+	if err, ok := val.(error); ok {
+		if errs := unwrapErrors(err); errs != nil {
+			for i, e := range errs {
+				tf.setLogfmt(buf, key+"."+strconv.Itoa(i), stripANSI(e.Error()))
+			}
+		} else {
+			tf.setLogfmt(buf, key, stripANSI(err.Error()))
+		}
+		tf.setLogfmt(buf, KeyMap.Fingerprint, fingerprint(err, stackFrames(0, false)))
+		tf.setLogfmt(buf, KeyMap.CallStack, textStack())
+		for i, e := range unwrapErrors(err) {
+			if frames := errorFrames(e); len(frames) > 0 {
+				tf.setLogfmt(buf, key+"."+strconv.Itoa(i)+"."+KeyMap.CallStack, framesText(frames))
+			}
+		}
+		return
+	}
+	buf.WriteString(cfg().pairSeparator())
+	buf.WriteString(logfmtKey(key))
+	buf.WriteString(cfg().assignment())
+	buf.WriteString(logfmtValue(tf.valueString(val)))
 }
 
 func (tf *TextFormatter) set(buf bufferWriter, key string, val interface{}) {
-	buf.WriteString(Separator)
-	buf.WriteString(key)
-	buf.WriteString(AssignmentChar)
+	if g, ok := val.(*FieldGroup); ok {
+		g.each(func(k string, v interface{}) {
+			tf.set(buf, key+"."+k, v)
+		})
+		return
+	}
+	if tf.logfmt {
+		tf.setLogfmt(buf, key, val)
+		return
+	}
 	if err, ok := val.(error); ok {
-		buf.WriteString(err.Error())
+		errs := unwrapErrors(err)
+		if errs != nil {
+			for i, e := range errs {
+				tf.set(buf, key+"."+strconv.Itoa(i), stripANSI(e.Error()))
+			}
+		} else {
+			tf.set(buf, key, stripANSI(err.Error()))
+		}
+		buf.WriteString(cfg().pairSeparator())
+		buf.WriteString(KeyMap.Fingerprint)
+		buf.WriteString(cfg().assignment())
+		buf.WriteString(fingerprint(err, stackFrames(0, false)))
 		buf.WriteRune('\n')
-		buf.WriteString(string(debug.Stack()))
+		buf.WriteString(textStack())
+		// stacks recorded by the errors of a multi-error
+		for i, e := range errs {
+			if frames := errorFrames(e); len(frames) > 0 {
+				buf.WriteString(key + "." + strconv.Itoa(i))
+				buf.WriteString(cfg().assignment())
+				buf.WriteRune('\n')
+				buf.WriteString(framesText(frames))
+			}
+		}
 		return
 	}
-	buf.WriteString(fmt.Sprintf("%v", val))
+	buf.WriteString(cfg().pairSeparator())
+	buf.WriteString(key)
+	buf.WriteString(cfg().assignment())
+	buf.WriteString(tf.valueString(val))
 }
 
 // Format records a log entry.
 func (tf *TextFormatter) Format(writer io.Writer, level int, msg string, args []interface{}) {
+	tf.formatNamed(writer, level, tf.name, msg, args)
+}
+
+// formatNamed records a log entry of the logger name.
+func (tf *TextFormatter) formatNamed(writer io.Writer, level int, name string, msg string, args []interface{}) {
 	buf := pool.Get()
 	defer pool.Put(buf)
 	buf.WriteString(tf.timeLabel)
-	buf.WriteString(time.Now().Format(timeFormat))
+	if tf.logfmt {
+		buf.WriteString(logfmtValue(time.Now().Format(cfg().timeFormat)))
+	} else {
+		buf.WriteString(time.Now().Format(cfg().timeFormat))
+	}
+	if cfg().showSchema {
+		buf.WriteString(cfg().pairSeparator())
+		buf.WriteString(KeyMap.Version)
+		buf.WriteString(cfg().assignment())
+		buf.WriteString(strconv.Itoa(SchemaVersion))
+	}
+	if cfg().showUptime {
+		buf.WriteString(cfg().pairSeparator())
+		buf.WriteString(KeyMap.Uptime)
+		buf.WriteString(cfg().assignment())
+		buf.WriteString(uptime())
+	}
+	buf.WriteString(tf.nameLabel)
+	if tf.logfmt {
+		buf.WriteString(logfmtValue(name))
+	} else {
+		buf.WriteString(name)
+	}
 	buf.WriteString(tf.itoaLevelMap[level])
-	buf.WriteString(msg)
+	if tf.logfmt {
+		buf.WriteString(logfmtValue(msg))
+	} else {
+		buf.WriteString(msg)
+	}
+	if cfg().logCaller && capturesCaller(level) {
+		tf.set(buf, KeyMap.Caller, callerString())
+	}
 	var lenArgs = len(args)
 	if lenArgs > 0 {
 		if lenArgs == 1 {
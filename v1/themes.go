@@ -0,0 +1,14 @@
+package log
+
+// Themes are named LOGXI_COLORS values for the built-in color schemes. Run
+// `logxi themes` to preview them on the current terminal.
+var Themes = map[string]string{
+	// ansi is the default scheme on terminals with 16 colors
+	"ansi": "key=cyan+h,value,misc=blue,source=magenta,TRC,DBG,WRN=yellow,INF=green,ERR=red+h,added=green,removed=red",
+	// xterm256 is the default scheme when TERM is xterm-256color
+	"xterm256": "key=cyan+h,value,misc=blue,source=88,TRC,DBG,WRN=yellow,INF=green+h,ERR=red+h,message=magenta+h,added=green+h,removed=red+h",
+	// conemu is the default scheme for ConEmu on Windows
+	"conemu": "key=cyan+h,value,misc=blue+h,source=yellow,TRC,DBG,WRN=yellow+h,INF=green+h,ERR=red+h,added=green+h,removed=red+h",
+	// windows is the default scheme for the Windows console
+	"windows": "ERR=red,misc=cyan,key=cyan,added=green,removed=red",
+}
@@ -0,0 +1,235 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ContextWriter is implemented by writers which can abandon a write when a
+// context is done, eg network sinks.
+type ContextWriter interface {
+	WriteContext(ctx context.Context, p []byte) (int, error)
+}
+
+// WriteCanceledError is returned when a write is abandoned because its
+// context was canceled or its deadline passed. It is distinct from errors
+// returned by the underlying writer, eg network errors.
+type WriteCanceledError struct {
+	Err error
+}
+
+func (e *WriteCanceledError) Error() string {
+	return "logxi: write canceled: " + e.Err.Error()
+}
+
+// Unwrap returns the context error.
+func (e *WriteCanceledError) Unwrap() error {
+	return e.Err
+}
+
+// IsWriteCanceled determines if err is a WriteCanceledError.
+func IsWriteCanceled(err error) bool {
+	_, ok := err.(*WriteCanceledError)
+	return ok
+}
+
+type deadlineWriter interface {
+	io.Writer
+	SetWriteDeadline(t time.Time) error
+}
+
+// TimeoutWriter bounds every write to its writer by a timeout. Writers
+// implementing ContextWriter receive the context directly, connections
+// implementing SetWriteDeadline (eg net.Conn) get a deadline and any other
+// writer is abandoned when the deadline passes. Close cancels pending and
+// future writes so shutdown can never hang on a dead collector.
+type TimeoutWriter struct {
+	writer  io.Writer
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// busy is held while a write is in progress. An abandoned write keeps
+	// holding it until the writer returns.
+	busy chan struct{}
+
+	mu            sync.Mutex
+	failures      int
+	lastErr       error
+	lastErrorTime time.Time
+}
+
+// NewTimeoutWriter creates a writer which abandons writes to writer taking
+// longer than timeout.
+func NewTimeoutWriter(writer io.Writer, timeout time.Duration) *TimeoutWriter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TimeoutWriter{
+		writer:  writer,
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+		busy:    make(chan struct{}, 1),
+	}
+}
+
+// Write writes p with a context derived from the writer's timeout.
+func (tw *TimeoutWriter) Write(p []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(tw.ctx, tw.timeout)
+	defer cancel()
+	return tw.WriteContext(ctx, p)
+}
+
+// WriteContext writes p, abandoning the write when ctx or the writer is
+// done.
+func (tw *TimeoutWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	n, err := tw.writeContext(ctx, p)
+	tw.mu.Lock()
+	if err != nil {
+		tw.failures++
+		tw.lastErr = err
+		tw.lastErrorTime = time.Now()
+	} else {
+		tw.failures = 0
+	}
+	tw.mu.Unlock()
+	return n, err
+}
+
+func (tw *TimeoutWriter) writeContext(ctx context.Context, p []byte) (int, error) {
+	if err := tw.ctx.Err(); err != nil {
+		return 0, &WriteCanceledError{Err: err}
+	}
+	select {
+	case tw.busy <- struct{}{}:
+	case <-ctx.Done():
+		return 0, &WriteCanceledError{Err: ctx.Err()}
+	case <-tw.ctx.Done():
+		return 0, &WriteCanceledError{Err: tw.ctx.Err()}
+	}
+
+	if cw, ok := tw.writer.(ContextWriter); ok {
+		defer func() { <-tw.busy }()
+		ctx, cancel := mergeContext(ctx, tw.ctx)
+		defer cancel()
+		n, err := cw.WriteContext(ctx, p)
+		if err != nil && ctx.Err() != nil {
+			return n, &WriteCanceledError{Err: ctx.Err()}
+		}
+		return n, err
+	}
+
+	if dw, ok := tw.writer.(deadlineWriter); ok {
+		defer func() { <-tw.busy }()
+		return tw.writeDeadline(ctx, dw, p)
+	}
+
+	// abandon writers which can't be interrupted; the write keeps busy until
+	// it returns so a hung writer fails subsequent writes quickly
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	buf := append([]byte(nil), p...)
+	go func() {
+		n, err := tw.writer.Write(buf)
+		<-tw.busy
+		done <- result{n, err}
+	}()
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-ctx.Done():
+		return 0, &WriteCanceledError{Err: ctx.Err()}
+	case <-tw.ctx.Done():
+		return 0, &WriteCanceledError{Err: tw.ctx.Err()}
+	}
+}
+
+func (tw *TimeoutWriter) writeDeadline(ctx context.Context, dw deadlineWriter, p []byte) (int, error) {
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		dw.SetWriteDeadline(deadline)
+	}
+
+	// interrupt the write on cancel
+	stop := make(chan struct{})
+	interrupted := make(chan struct{})
+	go func() {
+		defer close(interrupted)
+		select {
+		case <-ctx.Done():
+		case <-tw.ctx.Done():
+		case <-stop:
+			return
+		}
+		dw.SetWriteDeadline(time.Unix(1, 0))
+	}()
+
+	n, err := dw.Write(p)
+	close(stop)
+	// join the interrupt so its deadline can't land after the reset
+	<-interrupted
+	dw.SetWriteDeadline(time.Time{})
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled), errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return n, &WriteCanceledError{Err: ctx.Err()}
+		case errors.Is(tw.ctx.Err(), context.Canceled):
+			return n, &WriteCanceledError{Err: tw.ctx.Err()}
+		case hasDeadline && !time.Now().Before(deadline):
+			// the connection's deadline may expire before ctx's timer fires
+			return n, &WriteCanceledError{Err: context.DeadlineExceeded}
+		}
+	}
+	return n, err
+}
+
+// Health reports whether the writer is open, whether a write is pending and
+// the last error, followed by the health of the sink if it reports its own.
+func (tw *TimeoutWriter) Health() []SinkStatus {
+	tw.mu.Lock()
+	ss := SinkStatus{
+		Name:          "timeout",
+		Connected:     tw.ctx.Err() == nil,
+		SpoolDepth:    len(tw.busy),
+		Failures:      tw.failures,
+		LastErrorTime: tw.lastErrorTime,
+	}
+	if tw.lastErr != nil {
+		ss.LastError = tw.lastErr.Error()
+	}
+	tw.mu.Unlock()
+	result := []SinkStatus{ss}
+	if hr, ok := unwrapWriter(tw.writer).(HealthReporter); ok {
+		result = append(result, hr.Health()...)
+	}
+	return result
+}
+
+// Close cancels pending and future writes. The underlying writer is closed
+// if it implements io.Closer.
+func (tw *TimeoutWriter) Close() error {
+	tw.cancel()
+	if closer, ok := tw.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// mergeContext returns a context which is done when either a or b is done.
+func mergeContext(a, b context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(a)
+	go func() {
+		select {
+		case <-b.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
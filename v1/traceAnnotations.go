@@ -0,0 +1,111 @@
+package log
+
+import (
+	"context"
+	"runtime/trace"
+	"sync/atomic"
+)
+
+// traceAnnotations is 1 when runtime/trace annotations are enabled
+var traceAnnotations int32
+
+// EnableTraceAnnotations annotates execution traces, see runtime/trace,
+// so go tool trace views line up with the logs. While a trace is being
+// recorded
+//
+//   - CanonicalHandler runs each request in a task
+//   - timings of a CanonicalLine, see Time, are regions of the task
+//   - entries of loggers returned by FromContext are logged to the task,
+//     categorized by level
+//
+// Example
+//
+//	log.EnableTraceAnnotations(true)
+//	http.Handle("/", log.CanonicalHandler(logger, mux))
+//
+//	curl -o trace.out 'localhost:6060/debug/pprof/trace?seconds=5'
+//	go tool trace trace.out
+func EnableTraceAnnotations(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&traceAnnotations, v)
+}
+
+// isTracing reports whether annotations are enabled and a trace is being
+// recorded.
+func isTracing() bool {
+	return atomic.LoadInt32(&traceAnnotations) == 1 && trace.IsEnabled()
+}
+
+// traceLogger logs the entries of the logger it wraps to the task of ctx.
+type traceLogger struct {
+	Logger
+	ctx context.Context
+}
+
+// annotate logs an entry to the trace if the logger logs level.
+func (tl *traceLogger) annotate(level int, msg string) {
+	enabled := true
+	switch level {
+	case LevelTrace:
+		enabled = tl.Logger.IsTrace()
+	case LevelDebug:
+		enabled = tl.Logger.IsDebug()
+	case LevelInfo, LevelNotice:
+		enabled = tl.Logger.IsInfo()
+	case LevelWarn:
+		enabled = tl.Logger.IsWarn()
+	}
+	if enabled && trace.IsEnabled() {
+		trace.Log(tl.ctx, LevelMap[level], msg)
+	}
+}
+
+// Name returns the name of the wrapped logger.
+func (tl *traceLogger) Name() string {
+	return loggerName(tl.Logger)
+}
+
+// Trace logs a trace entry.
+func (tl *traceLogger) Trace(msg string, args ...interface{}) {
+	tl.annotate(LevelTrace, msg)
+	tl.Logger.Trace(msg, args...)
+}
+
+// Debug logs a debug entry.
+func (tl *traceLogger) Debug(msg string, args ...interface{}) {
+	tl.annotate(LevelDebug, msg)
+	tl.Logger.Debug(msg, args...)
+}
+
+// Info logs an info entry.
+func (tl *traceLogger) Info(msg string, args ...interface{}) {
+	tl.annotate(LevelInfo, msg)
+	tl.Logger.Info(msg, args...)
+}
+
+// Warn logs a warn entry.
+func (tl *traceLogger) Warn(msg string, args ...interface{}) error {
+	tl.annotate(LevelWarn, msg)
+	return tl.Logger.Warn(msg, args...)
+}
+
+// Error logs an error entry.
+func (tl *traceLogger) Error(msg string, args ...interface{}) error {
+	tl.annotate(LevelError, msg)
+	return tl.Logger.Error(msg, args...)
+}
+
+// Fatal logs a fatal entry then panics.
+func (tl *traceLogger) Fatal(msg string, args ...interface{}) {
+	tl.annotate(LevelFatal, msg)
+	tl.Logger.Fatal(msg, args...)
+}
+
+// Log logs a leveled entry.
+func (tl *traceLogger) Log(level int, msg string, args []interface{}) {
+	tl.annotate(level, msg)
+	tl.Logger.Log(level, msg, args)
+}
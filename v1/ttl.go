@@ -0,0 +1,121 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLKey is the key of an entry's time to live, a time.Duration or a
+// duration string such as "24h". Retention-aware sinks may drop the entry
+// once it expires, so verbose debugging data can expire sooner than audit
+// data in the same stream.
+//
+// Example
+//
+//	logger.Debug("request body", log.TTLKey, 24*time.Hour, "body", body)
+const TTLKey = "ttl"
+
+// ExpiresKey is added to entries with a TTL as the time they expire, in
+// RFC 3339 format, so sinks needn't parse durations.
+const ExpiresKey = "expires_at"
+
+// FieldExpiresKey groups the expiry times of fields logged with Expiring,
+// by key.
+const FieldExpiresKey = "field_expires_at"
+
+// ExpiringValue is a field value with its own time to live, see Expiring.
+type ExpiringValue struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// Expiring marks a field value to expire after ttl, eg a payload kept for
+// debugging in an otherwise long-lived entry. The value is logged as is and
+// its expiry time is added to the FieldExpiresKey group.
+//
+// Example
+//
+//	logger.Info("order placed", "order", id, "payload", log.Expiring(payload, time.Hour))
+func Expiring(value interface{}, ttl time.Duration) ExpiringValue {
+	return ExpiringValue{Value: value, TTL: ttl}
+}
+
+var levelTTLs = struct {
+	sync.RWMutex
+	ttls map[int]time.Duration
+}{ttls: map[int]time.Duration{}}
+
+// SetLevelTTL sets the TTL of entries at level which don't have one, eg
+// log.SetLevelTTL(log.LevelDebug, 24*time.Hour). A zero ttl removes it.
+func SetLevelTTL(level int, ttl time.Duration) {
+	levelTTLs.Lock()
+	defer levelTTLs.Unlock()
+	if ttl <= 0 {
+		delete(levelTTLs.ttls, level)
+		return
+	}
+	levelTTLs.ttls[level] = ttl
+}
+
+func levelTTL(level int) time.Duration {
+	levelTTLs.RLock()
+	defer levelTTLs.RUnlock()
+	return levelTTLs.ttls[level]
+}
+
+// annotateTTL writes the TTL of an entry as a duration string followed by
+// ExpiresKey, and unwraps expiring values adding their expiry times.
+func annotateTTL(level int, args []interface{}) []interface{} {
+	if len(args)%2 != 0 {
+		return args
+	}
+	ttlIndex := -1
+	var fieldExpires []interface{}
+	now := time.Now()
+	for i := 0; i < len(args); i += 2 {
+		key, _ := args[i].(string)
+		if ev, ok := args[i+1].(ExpiringValue); ok {
+			if fieldExpires == nil {
+				// don't modify the caller's args
+				args = append([]interface{}(nil), args...)
+			}
+			args[i+1] = ev.Value
+			fieldExpires = append(fieldExpires, key, now.Add(ev.TTL).UTC().Format(time.RFC3339))
+			continue
+		}
+		if key == TTLKey {
+			ttlIndex = i
+		}
+	}
+
+	var ttl time.Duration
+	if ttlIndex >= 0 {
+		switch v := args[ttlIndex+1].(type) {
+		case time.Duration:
+			ttl = v
+		case string:
+			ttl, _ = time.ParseDuration(v)
+		}
+	} else {
+		ttl = levelTTL(level)
+	}
+
+	if ttl > 0 {
+		if ttlIndex >= 0 {
+			// the last ttl wins, eg over one bound with WithFields
+			args = append([]interface{}(nil), args...)
+			for i := 0; i < len(args); i += 2 {
+				if key, _ := args[i].(string); key == TTLKey {
+					args[i+1] = ttl.String()
+				}
+			}
+		} else {
+			args = appendArgs(args, TTLKey, ttl.String())
+		}
+		args = appendArgs(args, ExpiresKey, now.Add(ttl).UTC().Format(time.RFC3339))
+	}
+	if fieldExpires != nil {
+		args = appendArgs(args, FieldExpiresKey, Group(FieldExpiresKey, fieldExpires...))
+	}
+	return args
+}
@@ -2,9 +2,30 @@ package log
 
 import (
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 )
 
+// ansiPattern matches ANSI escape sequences such as color codes
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;?]*[ -/]*[@-~]")
+
+// isPointer determines if v is a pointer, which can be a map key by
+// identity. Other values of comparable types may hold a func, eg in an
+// interface field, which panics as a map key.
+func isPointer(v interface{}) bool {
+	return v != nil && reflect.ValueOf(v).Kind() == reflect.Ptr
+}
+
+// stripANSI removes ANSI escape sequences from s so they don't end up in
+// machine formats.
+func stripANSI(s string) string {
+	if strings.IndexByte(s, 0x1b) < 0 {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
 func expandTabs(s string, tabLen int) string {
 	if s == "" {
 		return s
@@ -14,7 +35,7 @@ func expandTabs(s string, tabLen int) string {
 	defer pool.Put(buf)
 	for _, part := range parts {
 		buf.WriteString(part)
-		buf.WriteString(strings.Repeat(" ", tabLen-len(part)%tabLen))
+		buf.WriteString(strings.Repeat(" ", tabLen-displayWidth(part)%tabLen))
 	}
 	return buf.String()
 }
@@ -2,3 +2,9 @@ package log
 
 // Version is the version of this package
 const Version = "1.0.0-pre"
+
+// SchemaVersion is the version of the output schema: the reserved keys and
+// the meaning of their values. It is bumped whenever field semantics
+// change so long-lived parsing pipelines can handle upgrades. Enable the
+// KeyMap.Version field with LOGXI_FORMAT=schema.
+const SchemaVersion = 1
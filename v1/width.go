@@ -0,0 +1,126 @@
+package log
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges are the East Asian wide and fullwidth ranges and the emoji
+// terminals print in two columns
+var wideRanges = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115f, 1}, // Hangul Jamo
+		{0x231a, 0x231b, 1},
+		{0x2329, 0x232a, 1},
+		{0x23e9, 0x23ec, 1},
+		{0x23f0, 0x23f0, 1},
+		{0x23f3, 0x23f3, 1},
+		{0x25fd, 0x25fe, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267f, 0x267f, 1},
+		{0x2693, 0x2693, 1},
+		{0x26a1, 0x26a1, 1},
+		{0x26aa, 0x26ab, 1},
+		{0x26bd, 0x26be, 1},
+		{0x26c4, 0x26c5, 1},
+		{0x26ce, 0x26ce, 1},
+		{0x26d4, 0x26d4, 1},
+		{0x26ea, 0x26ea, 1},
+		{0x26f2, 0x26f3, 1},
+		{0x26f5, 0x26f5, 1},
+		{0x26fa, 0x26fa, 1},
+		{0x26fd, 0x26fd, 1},
+		{0x2705, 0x2705, 1},
+		{0x270a, 0x270b, 1},
+		{0x2728, 0x2728, 1},
+		{0x274c, 0x274c, 1},
+		{0x274e, 0x274e, 1},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2795, 0x2797, 1},
+		{0x27b0, 0x27b0, 1},
+		{0x27bf, 0x27bf, 1},
+		{0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b50, 1},
+		{0x2b55, 0x2b55, 1},
+		{0x2e80, 0x303e, 1}, // CJK radicals and punctuation
+		{0x3041, 0x33ff, 1}, // kana, Bopomofo and CJK compatibility
+		{0x3400, 0x4dbf, 1}, // CJK extension A
+		{0x4e00, 0x9fff, 1}, // CJK unified ideographs
+		{0xa000, 0xa4cf, 1}, // Yi
+		{0xa960, 0xa97f, 1},
+		{0xac00, 0xd7a3, 1}, // Hangul syllables
+		{0xf900, 0xfaff, 1}, // CJK compatibility ideographs
+		{0xfe10, 0xfe19, 1},
+		{0xfe30, 0xfe6f, 1},
+		{0xff00, 0xff60, 1}, // fullwidth forms
+		{0xffe0, 0xffe6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16fe0, 0x16fe4, 1},
+		{0x17000, 0x18cff, 1}, // Tangut
+		{0x1b000, 0x1b2ff, 1}, // kana supplement
+		{0x1f004, 0x1f004, 1},
+		{0x1f0cf, 0x1f0cf, 1},
+		{0x1f18e, 0x1f18e, 1},
+		{0x1f191, 0x1f19a, 1},
+		{0x1f200, 0x1f2ff, 1},
+		{0x1f300, 0x1f64f, 1}, // pictographs and emoticons
+		{0x1f680, 0x1f6ff, 1}, // transport and map symbols
+		{0x1f7e0, 0x1f7eb, 1},
+		{0x1f900, 0x1f9ff, 1}, // supplemental pictographs
+		{0x1fa70, 0x1faff, 1},
+		{0x20000, 0x3fffd, 1}, // CJK extensions B and later
+	},
+}
+
+// runeWidth returns the number of columns a terminal prints r in: 0 for
+// combining marks and format characters such as the zero width joiner, 2
+// for wide characters and emoji, otherwise 1.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x300:
+		if r < 0x20 || r >= 0x7f && r < 0xa0 {
+			return 0
+		}
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case unicode.Is(wideRanges, r):
+		return 2
+	}
+	return 1
+}
+
+// displayWidth returns the number of columns a terminal prints s in. ANSI
+// escapes must be removed first, see stripANSI.
+func displayWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			// not ASCII
+			for _, r := range s[i:] {
+				width += runeWidth(r)
+			}
+			return width
+		}
+		if s[i] >= 0x20 && s[i] != 0x7f {
+			width++
+		}
+	}
+	return width
+}
+
+// truncateWidth returns the longest prefix of s which is at most width
+// columns wide.
+func truncateWidth(s string, width int) string {
+	w := 0
+	for i, r := range s {
+		w += runeWidth(r)
+		if w > width {
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML parses the subset of YAML used by configuration files: nested
+// maps of scalars indented with spaces, and comments. Lists, anchors and
+// multi-line scalars are not supported.
+func parseYAML(b []byte) (map[string]interface{}, error) {
+	type level struct {
+		indent int
+		m      map[string]interface{}
+	}
+	root := map[string]interface{}{}
+	stack := []level{{indent: -1, m: root}}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		content := strings.TrimLeft(line, " ")
+		if content == "" || content[0] == '#' || content == "---" {
+			continue
+		}
+		lineno := i + 1
+		if content[0] == '\t' {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", lineno)
+		}
+		if content[0] == '-' {
+			return nil, fmt.Errorf("line %d: lists are not supported", lineno)
+		}
+		indent := len(line) - len(content)
+
+		key, rest, err := splitYAMLKey(content)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		for indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1].m
+
+		value := strings.TrimSpace(rest)
+		if value == "" {
+			m := map[string]interface{}{}
+			parent[key] = m
+			stack = append(stack, level{indent: indent, m: m})
+			continue
+		}
+		s, err := yamlScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		parent[key] = s
+	}
+	return root, nil
+}
+
+// splitYAMLKey splits "key: value" into the key and the rest of the line.
+func splitYAMLKey(content string) (key string, rest string, err error) {
+	if content[0] == '"' || content[0] == '\'' {
+		end := strings.IndexByte(content[1:], content[0])
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated key")
+		}
+		key = content[1 : end+1]
+		content = content[end+2:]
+		if !strings.HasPrefix(content, ":") {
+			return "", "", fmt.Errorf("expected ':' after key")
+		}
+		return key, content[1:], nil
+	}
+	colon := strings.Index(content, ":")
+	if colon < 0 {
+		return "", "", fmt.Errorf("expected key: value")
+	}
+	return strings.TrimSpace(content[:colon]), content[colon+1:], nil
+}
+
+// yamlScalar unquotes a scalar and strips trailing comments.
+func yamlScalar(value string) (string, error) {
+	switch value[0] {
+	case '"':
+		end := strings.LastIndexByte(value, '"')
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strconv.Unquote(value[:end+1])
+	case '\'':
+		end := strings.LastIndexByte(value, '\'')
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strings.Replace(value[1:end], "''", "'", -1), nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}